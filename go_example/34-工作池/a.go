@@ -1,4 +1,5 @@
 // 在这个例子中，我们将看到如何使用 Go 协程和通道实现一个工作池 。
+// 支持任意任务类型、取消、背压和动态调整 worker 数的工作池见 go_web/8-web基础/pool。
 
 package main
 
@@ -1,4 +1,5 @@
 // 速率限制(英) 是一个重要的控制服务资源利用和质量的途径。Go 通过 Go 协程、通道和打点器优美的支持了速率限制。
+// 按客户端限流的 HTTP 中间件（令牌桶、滑动窗口日志、固定窗口）见 go_web/8-web基础/ratelimit。
 
 package main
 
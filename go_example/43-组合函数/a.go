@@ -1,78 +1,48 @@
 // 我们经常需要程序在数据集上执行操作，比如选择满足给定条件的所有项，或者将所有的项通过一个自定义函数映射到一个新的集合上。
 
-// 在某些语言中，会习惯使用泛型。Go 不支持泛型，在 Go 中，当你的程序或者数据类型需要时，通常是通过组合的方式来提供操作函数。
+// Go 1.18 开始支持泛型，同一个组合函数可以用于任意元素类型的切片，不需要为每种类型各写一份。
+// 这些函数放在 go_web/8-web基础/collections 包中，这里演示它们的用法。
+// 除了下面用到的，collections 中还有 Zip、Uniq、iter.Seq 惰性版本和并行版本等。
 
-// 这是一些 strings 切片的组合函数示例。你可以使用这些例子来构建自己的函数。注意有时候，直接使用内联组合操作代码会更清晰，而不是创建并调用一个帮助函数。
+// 注意有时候，直接使用内联组合操作代码会更清晰，而不是创建并调用一个帮助函数。
 
 package main
 
 import (
 	"fmt"
+	"slices"
 	"strings"
-)
-
-func Index(vs []string, t string) int { // 返回目标字符串 t 出现的的第一个索引位置，或者在没有匹配值时返回 -1。
-	for i, v := range vs {
-		if v == t {
-			return i
-		}
-	}
-	return -1
-}
-
-func Include(vs []string, t string) bool { // 如果目标字符串 t 在这个切片中则返回 true。
-	return Index(vs, t) >= 0
-}
-
-func Any(vs []string, f func(string) bool) bool { // 如果这些切片中的字符串有一个满足条件 f 则返回true。
-	for _, v := range vs {
-		if f(v) {
-			return true
-		}
-	}
-	return false
-}
-
-func All(vs []string, f func(string) bool) bool { // 如果切片中的所有字符串都满足条件 f 则返回 true。
-	for _, v := range vs {
-		if !f(v) {
-			return false
-		}
-	}
-	return true
-}
-
-func Filter(vs []string, f func(string) bool) []string { // 返回一个包含所有切片中满足条件 f 的字符串的新切片。
-	vsf := make([]string, 0)
-	for _, v := range vs {
-		if f(v) {
-			vsf = append(vsf, v)
-		}
-	}
-	return vsf
-}
 
-func Map(vs []string, f func(string) string) []string { // 返回一个对原始切片中所有字符串执行函数 f 后的新切片。
-	vsm := make([]string, len(vs))
-	for i, v := range vs {
-		vsm[i] = f(v)
-	}
-	return vsm
-}
+	"example.com/web/collections"
+)
 
 func main() {
 	var strs = []string{"peach", "apple", "pear", "plum"}
-	fmt.Println(Index(strs, "pear"))
-	fmt.Println(Include(strs, "grape"))
-	fmt.Println(Any(strs, func(v string) bool {
+	fmt.Println(collections.Index(strs, "pear"))            // 目标值第一次出现的位置，没有时返回 -1
+	fmt.Println(collections.Include(strs, "grape"))         // 目标值是否在切片中
+	fmt.Println(collections.Any(strs, func(v string) bool { // 是否有一个元素满足条件
 		return strings.HasPrefix(v, "p")
 	}))
-	fmt.Println(All(strs, func(v string) bool {
+	fmt.Println(collections.All(strs, func(v string) bool { // 是否所有元素都满足条件
 		return strings.HasPrefix(v, "p")
 	}))
-	fmt.Println(Filter(strs, func(v string) bool {
+	fmt.Println(collections.Filter(strs, func(v string) bool { // 满足条件的元素组成的新切片
 		return strings.Contains(v, "e")
 	}))
 
-	fmt.Println(Map(strs, strings.ToUpper))
+	fmt.Println(collections.Map(strs, strings.ToUpper)) // 对每个元素执行函数后的新切片
+
+	// 同样的函数也可以用于其他类型，类型参数由编译器根据参数推断
+	fmt.Println(collections.Index([]int{3, 1, 4}, 4))
+	fmt.Println(collections.Map(strs, func(v string) int { return len(v) }))
+
+	// 更多的组合函数：汇总、分组、分块、排序
+	fmt.Println(collections.Reduce(strs, 0, func(n int, v string) int { return n + len(v) }))
+	fmt.Println(collections.GroupBy(strs, func(v string) string { return v[:1] }))
+	fmt.Println(collections.Chunk(strs, 3))
+	fmt.Println(collections.SortBy(strs, func(v string) int { return len(v) }))
+
+	// Seq 版本按需计算，找到两个结果后就不再继续
+	long := collections.FilterSeq(slices.Values(strs), func(v string) bool { return len(v) > 4 })
+	fmt.Println(slices.Collect(collections.TakeSeq(long, 2)))
 }
@@ -0,0 +1,59 @@
+// 有时候我们希望 Go 程序能够智能的处理 Unix 信号。例如，我们希望当服务器接收到一个 SIGTERM 信号时能够优雅退出，
+// 或者一个命令行工具在接收到一个 SIGINT 信号时停止处理输入信息。这里我们将看看如何使用通道来处理信号。
+// go_web/8-web基础/shutdown 包里的优雅退出就是用的这里的方法，这个例子的后半部分直接使用了它。
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"example.com/web/shutdown"
+)
+
+func main() {
+	// Go 通过向一个通道发送 os.Signal 值来进行信号通知。我们将创建一个通道来接收这些通知（同时还创建一个用于在程序可以结束时进行通知的通道）。
+	// 注意这个通道要带缓冲，否则信号到达时如果没有人在接收就会被丢掉。
+	sigs := make(chan os.Signal, 1)
+	done := make(chan bool, 1)
+
+	// signal.Notify 注册这个给定的通道用于接收特定信号。
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	// 这个 Go 协程执行一个阻塞的信号接收操作。当它得到一个值时，它将打印这个值，然后通知程序可以退出。
+	go func() {
+		sig := <-sigs
+		fmt.Println()
+		fmt.Println(sig)
+		done <- true
+	}()
+
+	// 程序将在这里进行等待，直到它得到了期望的信号（也就是上面的 Go 协程发送的 done 值）然后退出。
+	fmt.Println("awaiting signal")
+	<-done
+	fmt.Println("exiting")
+
+	// 服务器的优雅退出不需要每次都自己写：go_web/8-web基础 的 shutdown 包把上面的做法封装成了 shutdown.Run。
+	// 它用 signal.NotifyContext 等待 SIGINT/SIGTERM，收到后不再接受新连接，在期限内等正在处理的请求完成，
+	// 再按顺序执行清理函数，最后返回退出码。这个目录的 go.mod 用 replace 指向本地的 go_web/8-web基础。
+	srv := &http.Server{Addr: ":8081", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Second) // 模拟一个耗时的请求，在这期间按 Ctrl+C 请求也会正常完成
+		fmt.Fprintln(w, "hello")
+	})}
+
+	fmt.Println("server on :8081, press Ctrl+C to stop")
+	code := shutdown.Run(srv, 5*time.Second, func(ctx context.Context) error {
+		fmt.Println("server stopped, cleaning up")
+		return nil
+	})
+	fmt.Println("exit code", code)
+	os.Exit(code) // 退出码的约定见 63-退出
+
+	// 运行这个程序后它将一直等待信号。使用 Ctrl+C（终端显示为 ^C），我们可以发送一个 SIGINT 信号，
+	// 这会使程序打印 interrupt 然后退出；之后的服务器部分再按一次 Ctrl+C 就会优雅关闭。
+}
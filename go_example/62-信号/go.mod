@@ -0,0 +1,11 @@
+module example.com/example/signal
+
+go 1.25.0
+
+require example.com/web v0.0.0
+
+// 使用 go_web/8-web基础 中的 shutdown 包，它依赖的 example.com/database 也要指向本地目录
+replace (
+	example.com/database => ../../go_web/9-数据库
+	example.com/web => ../../go_web/8-web基础
+)
@@ -0,0 +1,76 @@
+// 使用 os.Exit 来立即进行带给定状态的退出。
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"example.com/web/shutdown"
+)
+
+func main() {
+	// 当使用 os.Exit 时 defer 将不会被执行，所以这里的 fmt.Println 将永远不会被调用。
+	defer fmt.Println("!")
+
+	// 注意，不像例如 C 语言，Go 不使用在 main 中返回一个整数来指明退出状态。如果你想以非零状态退出，那么你就要使用 os.Exit。
+	// 为了让 defer 能正常执行，常见的写法是把真正的逻辑放进一个返回退出码的函数里，main 只负责调用 os.Exit。
+	// go_web/8-web基础 中的服务器就是这样做的：shutdown.Run 返回退出码，main 调用 os.Exit(code)。
+	os.Exit(run())
+}
+
+// 退出码的约定：0 表示成功，非零表示各种失败，调用方（例如 shell 脚本）可以根据它判断发生了什么。
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+func run() int {
+	defer fmt.Println("cleanup") // 这个 defer 在 run 返回时执行，早于 os.Exit
+
+	if len(os.Args) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: a [name]")
+		return exitUsage
+	}
+	if len(os.Args) == 2 && os.Args[1] == "fail" {
+		fmt.Fprintln(os.Stderr, "something went wrong")
+		return exitError
+	}
+	fmt.Println("ok")
+
+	// 退出前有多个清理步骤时，可以用 go_web/8-web基础 的 shutdown.RunHooks 依次执行（这个目录的 go.mod 用 replace 指向它）：
+	// 每个步骤各有自己的期限，一个失败不影响后面的步骤，返回的错误包含所有失败。
+	// 清理失败时用单独的退出码，调用方能区分"工作没做完"和"工作做完了但没清理干净"。
+	err := shutdown.RunHooks(time.Second,
+		func(ctx context.Context) error {
+			fmt.Println("flush")
+			return nil
+		},
+		func(ctx context.Context) error {
+			if len(os.Args) == 2 && os.Args[1] == "dirty" {
+				return errors.New("close: temp file is busy")
+			}
+			fmt.Println("close")
+			return nil
+		},
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return shutdown.ExitHook
+	}
+	return exitOK
+
+	// 如果你使用 go run 来运行 a.go，退出状态将会被 go 获取并打印。
+	// 使用编译并执行一个二进制文件的方式，你可以在终端中查看退出状态：
+	// $ go build a.go
+	// $ ./a fail
+	// $ echo $?
+	// 1
+	// $ ./a dirty
+	// $ echo $?
+	// 3
+}
@@ -0,0 +1,279 @@
+// auth 包负责认证：用户名密码登录后签发 JWT（HS256），服务之间的调用使用 API key。
+//
+// 请求可以用两种方式携带凭据：
+//
+//	Authorization: Bearer <访问令牌>
+//	X-API-Key: <key>
+//
+// Middleware 会拒绝没有凭据的写请求（POST、PUT、PATCH、DELETE），读请求可以匿名访问。
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"example.com/web/middleware"
+	"example.com/web/store"
+)
+
+// APIKeyHeader 是携带 API key 的请求头
+const APIKeyHeader = "X-API-Key"
+
+var (
+	ErrNoCredentials      = errors.New("请求没有携带凭据")
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	ErrInvalidToken       = errors.New("令牌无效")
+	ErrTokenExpired       = errors.New("令牌已过期")
+	ErrTokenRevoked       = errors.New("令牌已作废")
+	ErrInvalidAPIKey      = errors.New("API key 无效")
+)
+
+// 认证方式
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api_key"
+)
+
+// Principal 是通过认证的调用方
+type Principal struct {
+	Subject string // 用户名或 API key 的名字
+	UserID  int    // 登录用户对应的用户 ID，API key 为 0
+	Method  string // MethodJWT 或 MethodAPIKey
+	Role    string // API key 的角色；登录用户的角色保存在用户数据中，这里为空
+}
+
+type principalKey struct{}
+
+// WithPrincipal 把调用方放进 context
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext 返回 context 中的调用方，匿名请求返回 false
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Account 是可以登录的账号
+type Account struct {
+	Username string
+	UserID   int
+	hash     []byte // 密码的 bcrypt 哈希，内存中不保存密码本身
+}
+
+// Accounts 是所有账号，按用户名索引
+type Accounts map[string]Account
+
+// ParseAccounts 解析 "用户名:密码[:用户ID],..." 格式的账号列表。
+// 密码可以是明文，也可以是 HashPassword（或 htpasswd -nbB）生成的 bcrypt 哈希，
+// 后者以 $2a$、$2b$ 或 $2y$ 开头，这样配置和环境变量中就不需要出现明文密码。
+func ParseAccounts(s string) (Accounts, error) {
+	accounts := make(Accounts)
+	for _, item := range splitList(s) {
+		parts := strings.Split(item, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("账号 %q 的格式应为 用户名:密码[:用户ID]", parts[0])
+		}
+		a := Account{Username: parts[0]}
+		if _, err := bcrypt.Cost([]byte(parts[1])); err == nil {
+			a.hash = []byte(parts[1])
+		} else if isBcrypt(parts[1]) {
+			return nil, fmt.Errorf("账号 %q 的密码哈希不合法: %w", parts[0], err)
+		} else if a.hash, err = HashPassword(parts[1]); err != nil {
+			return nil, fmt.Errorf("账号 %q: %w", parts[0], err)
+		}
+		if len(parts) == 3 {
+			id, err := strconv.Atoi(parts[2])
+			if err != nil || id <= 0 {
+				return nil, fmt.Errorf("账号 %q 的用户 ID 不合法", item)
+			}
+			a.UserID = id
+		}
+		accounts[a.Username] = a
+	}
+	return accounts, nil
+}
+
+// HashPassword 用 bcrypt 计算密码的哈希，结果可以代替明文写在账号列表中
+func HashPassword(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+}
+
+func isBcrypt(s string) bool {
+	return strings.HasPrefix(s, "$2a$") || strings.HasPrefix(s, "$2b$") || strings.HasPrefix(s, "$2y$")
+}
+
+// dummyHash 用于用户名不存在时的比较，和真实账号的比较耗时相同
+var dummyHash = sync.OnceValue(func() []byte {
+	h, _ := HashPassword("dummy")
+	return h
+})
+
+// Check 校验用户名和密码。用户名不存在时也做一次比较，让响应时间不泄露账号是否存在。
+func (as Accounts) Check(username, password string) (Account, bool) {
+	a, ok := as[username]
+	hash := a.hash
+	if !ok {
+		hash = dummyHash()
+	}
+	match := bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+	return a, ok && match
+}
+
+// APIKey 是一个 API key 的名字和角色
+type APIKey struct {
+	Name string
+	Role string
+}
+
+// APIKeys 按 key 的 SHA-256 索引，内存中不保存 key 本身
+type APIKeys map[string]APIKey
+
+// ParseAPIKeys 解析 "名字:key[:角色],..." 格式的 API key 列表，没有写角色时为 editor
+func ParseAPIKeys(s string) (APIKeys, error) {
+	keys := make(APIKeys)
+	for _, item := range splitList(s) {
+		parts := strings.Split(item, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || len(parts[1]) < 16 {
+			return nil, fmt.Errorf("API key %q 的格式应为 名字:key[:角色]，key 至少 16 个字符", parts[0])
+		}
+		k := APIKey{Name: parts[0], Role: store.RoleEditor}
+		if len(parts) == 3 {
+			if !store.ValidRole(parts[2]) {
+				return nil, fmt.Errorf("API key %q 的角色 %q 不存在", parts[0], parts[2])
+			}
+			k.Role = parts[2]
+		}
+		keys[hashKey(parts[1])] = k
+	}
+	return keys, nil
+}
+
+// Lookup 查找 key
+func (ks APIKeys) Lookup(key string) (APIKey, bool) {
+	k, ok := ks[hashKey(key)]
+	return k, ok
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Authenticator 汇总了所有认证方式
+type Authenticator struct {
+	Tokens   *Tokens
+	Accounts Accounts
+	APIKeys  APIKeys
+
+	// Denylist 记录用过的刷新令牌，为 nil 时刷新令牌在过期之前可以重复使用
+	Denylist Denylist
+}
+
+// Login 校验用户名密码并签发令牌
+func (a *Authenticator) Login(username, password string) (TokenPair, error) {
+	account, ok := a.Accounts.Check(username, password)
+	if !ok {
+		return TokenPair{}, ErrInvalidCredentials
+	}
+	return a.Tokens.Issue(account.Username, account.UserID)
+}
+
+// Refresh 用刷新令牌换取一对新的令牌，账号被删除后不能再刷新。
+// 设置了 Denylist 时旧的刷新令牌同时作废，再次使用返回 ErrTokenRevoked。
+func (a *Authenticator) Refresh(refreshToken string) (TokenPair, error) {
+	c, err := a.Tokens.Verify(refreshToken, UseRefresh)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	account, ok := a.Accounts[c.Subject]
+	if !ok {
+		return TokenPair{}, ErrInvalidToken
+	}
+	if a.Denylist != nil && !a.Denylist.Revoke(c.ID, time.Unix(c.ExpiresAt, 0)) {
+		return TokenPair{}, ErrTokenRevoked
+	}
+	return a.Tokens.Issue(account.Username, account.UserID)
+}
+
+// Authenticate 从请求头中识别调用方，没有携带凭据时返回 ErrNoCredentials
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		k, ok := a.APIKeys.Lookup(key)
+		if !ok {
+			return Principal{}, ErrInvalidAPIKey
+		}
+		return Principal{Subject: k.Name, Method: MethodAPIKey, Role: k.Role}, nil
+	}
+
+	h := r.Header.Get("Authorization")
+	if h == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	scheme, token, ok := strings.Cut(h, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return Principal{}, ErrInvalidToken
+	}
+	c, err := a.Tokens.Verify(strings.TrimSpace(token), UseAccess)
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{Subject: c.Subject, UserID: c.UserID, Method: MethodJWT}, nil
+}
+
+// Middleware 识别每个请求的调用方并放进 context。
+// 携带了错误凭据的请求一律拒绝；没有凭据的写请求也被拒绝，public 中的路径（例如 /login）除外。
+// 拒绝时调用 onError 输出响应，err 是上面定义的错误之一。
+func Middleware(a *Authenticator, onError func(w http.ResponseWriter, r *http.Request, err error), public ...string) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			isPublic := false
+			for _, p := range public {
+				if r.URL.Path == p {
+					isPublic = true
+				}
+			}
+
+			p, err := a.Authenticate(r)
+			switch {
+			case err == nil:
+				next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+			case isPublic, errors.Is(err, ErrNoCredentials) && safeMethod(r.Method):
+				next.ServeHTTP(w, r)
+			default:
+				challenge := `Bearer realm="users"`
+				if !errors.Is(err, ErrNoCredentials) && !errors.Is(err, ErrInvalidAPIKey) {
+					challenge += `, error="invalid_token"`
+				}
+				w.Header().Set("WWW-Authenticate", challenge)
+				onError(w, r, err)
+			}
+		})
+	}
+}
+
+// 读请求不修改数据，可以匿名访问
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
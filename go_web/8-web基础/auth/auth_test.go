@@ -0,0 +1,39 @@
+package auth
+
+import "testing"
+
+func TestParseAccounts(t *testing.T) {
+	hash, err := HashPassword("secret456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts, err := ParseAccounts("admin:admin123:1, ops:" + string(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		username, password string
+		ok                 bool
+	}{
+		{"admin", "admin123", true},
+		{"admin", "admin124", false},
+		{"ops", "secret456", true},
+		{"ops", string(hash), false}, // 哈希本身不是密码
+		{"nobody", "admin123", false},
+	}
+	for _, tt := range tests {
+		if _, ok := accounts.Check(tt.username, tt.password); ok != tt.ok {
+			t.Errorf("Check(%q, %q) = %v，应该是 %v", tt.username, tt.password, ok, tt.ok)
+		}
+	}
+	if a := accounts["admin"]; a.UserID != 1 || string(a.hash) == "admin123" {
+		t.Fatalf("admin 账号解析错误: %+v", a)
+	}
+
+	for _, s := range []string{"admin", "admin:", ":x", "admin:x:abc", "admin:$2a$10$broken"} {
+		if _, err := ParseAccounts(s); err == nil {
+			t.Errorf("ParseAccounts(%q) 应该返回错误", s)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Denylist 记录已经作废的令牌 ID (jti)。刷新令牌换取新令牌后就被加入，每个刷新令牌只能使用一次，
+// 被窃取的刷新令牌在合法用户刷新之后就不能再用了。
+type Denylist interface {
+	// Revoke 把 jti 加入名单，保存到令牌过期的 expires 为止；jti 已经在名单中时返回 false。
+	// 检查和加入必须是原子的，否则同一个刷新令牌的两个并发请求都能换到新令牌。
+	Revoke(jti string, expires time.Time) bool
+}
+
+// MemoryDenylist 把名单保存在内存中，只在当前进程内有效，重启后清空。
+// 令牌过期之后本来就不能使用，过期的 jti 在名单变大时清理掉。
+type MemoryDenylist struct {
+	mu      sync.Mutex
+	ids     map[string]time.Time
+	sweepAt int // 名单达到这个大小时清理一次
+	now     func() time.Time
+}
+
+// NewMemoryDenylist 创建空的名单
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{ids: make(map[string]time.Time), sweepAt: 1024, now: time.Now}
+}
+
+func (d *MemoryDenylist) Revoke(jti string, expires time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	if exp, ok := d.ids[jti]; ok && now.Before(exp) {
+		return false
+	}
+	if len(d.ids) >= d.sweepAt {
+		for id, exp := range d.ids {
+			if !now.Before(exp) {
+				delete(d.ids, id)
+			}
+		}
+		d.sweepAt = max(2*len(d.ids), 1024) // 清理的开销均摊到每次 Revoke
+	}
+	d.ids[jti] = expires
+	return true
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 令牌的用途，访问令牌用来调用接口，刷新令牌只能用来换取新的令牌
+const (
+	UseAccess  = "access"
+	UseRefresh = "refresh"
+)
+
+// Claims 是 JWT 的载荷
+type Claims struct {
+	Subject   string `json:"sub"`           // 用户名或 API key 的名字
+	UserID    int    `json:"uid,omitempty"` // 对应的用户 ID，没有对应用户时为 0
+	Use       string `json:"use"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti"`
+}
+
+// TokenPair 是 /login 的响应
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"` // 总是 Bearer
+	ExpiresIn    int64  `json:"expires_in"` // 访问令牌的有效秒数
+}
+
+// Tokens 签发和校验 HS256 的 JWT。
+// 令牌是无状态的，服务器不保存，所以访问令牌在过期之前无法撤销，有效期应该短一些；
+// 刷新令牌由 Authenticator.Denylist 保证只能使用一次。
+type Tokens struct {
+	secret     []byte
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	now        func() time.Time
+}
+
+// jwtHeader 是固定的头部，只支持 HS256
+var jwtHeader = b64.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+var b64 = base64.RawURLEncoding
+
+// NewTokens 创建签发器，secret 至少 32 字节。
+func NewTokens(secret []byte, accessTTL, refreshTTL time.Duration) (*Tokens, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("JWT 密钥至少需要 32 字节")
+	}
+	return &Tokens{secret: secret, AccessTTL: accessTTL, RefreshTTL: refreshTTL, now: time.Now}, nil
+}
+
+// Issue 为 subject 签发一对访问令牌和刷新令牌
+func (t *Tokens) Issue(subject string, userID int) (TokenPair, error) {
+	now := t.now()
+	access, err := t.sign(Claims{Subject: subject, UserID: userID, Use: UseAccess,
+		IssuedAt: now.Unix(), ExpiresAt: now.Add(t.AccessTTL).Unix(), ID: newID()})
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refresh, err := t.sign(Claims{Subject: subject, UserID: userID, Use: UseRefresh,
+		IssuedAt: now.Unix(), ExpiresAt: now.Add(t.RefreshTTL).Unix(), ID: newID()})
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(t.AccessTTL / time.Second),
+	}, nil
+}
+
+// Verify 检查签名、用途和有效期，返回令牌的载荷
+func (t *Tokens) Verify(token, use string) (Claims, error) {
+	header, rest, ok1 := strings.Cut(token, ".")
+	payload, sig, ok2 := strings.Cut(rest, ".")
+	if !ok1 || !ok2 {
+		return Claims{}, ErrInvalidToken
+	}
+	// 头部必须和签发时完全一样，这样 alg=none 之类的令牌不会被接受
+	if header != jwtHeader {
+		return Claims{}, ErrInvalidToken
+	}
+	got, err := b64.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, t.mac(header+"."+payload)) {
+		return Claims{}, ErrInvalidToken
+	}
+
+	data, err := b64.DecodeString(payload)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	var c Claims
+	if err := json.Unmarshal(data, &c); err != nil || c.Use != use || c.Subject == "" {
+		return Claims{}, ErrInvalidToken
+	}
+	if t.now().Unix() >= c.ExpiresAt {
+		return Claims{}, ErrTokenExpired
+	}
+	return c, nil
+}
+
+func (t *Tokens) sign(c Claims) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	signing := jwtHeader + "." + b64.EncodeToString(data)
+	return signing + "." + b64.EncodeToString(t.mac(signing)), nil
+}
+
+func (t *Tokens) mac(s string) []byte {
+	h := hmac.New(sha256.New, t.secret)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,168 @@
+// authz 包负责授权：认证（auth 包）确定了调用方是谁，这里决定调用方能做什么。
+//
+// 每个角色有一组权限，Policy 把路由和方法映射到需要的权限：
+//
+//	POST   /users       users:create
+//	PATCH  /users/{id}  users:update，修改自己时 users:update:self 就够了
+//	DELETE /users/{id}  users:delete
+//
+// 缺少权限时返回 *DeniedError，其中记录了缺少的权限，服务器据此返回 403。
+package authz
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"example.com/web/auth"
+	"example.com/web/middleware"
+	"example.com/web/store"
+)
+
+// Permission 是一项操作的权限，格式为 资源:操作
+type Permission string
+
+const (
+	UsersCreate     Permission = "users:create"
+	UsersUpdate     Permission = "users:update"      // 修改任意用户
+	UsersUpdateSelf Permission = "users:update:self" // 只能修改自己
+	UsersDelete     Permission = "users:delete"
+	UsersAssignRole Permission = "users:assign_role" // 创建或修改用户时指定角色
+)
+
+// 每个角色拥有的权限。查看用户不需要权限，匿名请求也可以。
+var rolePermissions = map[string][]Permission{
+	store.RoleAdmin:  {UsersCreate, UsersUpdate, UsersUpdateSelf, UsersDelete, UsersAssignRole},
+	store.RoleEditor: {UsersCreate, UsersUpdate, UsersUpdateSelf},
+	store.RoleViewer: {UsersUpdateSelf},
+}
+
+// Has 判断角色是否拥有权限，未知的角色没有任何权限
+func Has(role string, p Permission) bool {
+	return slices.Contains(rolePermissions[role], p)
+}
+
+// Subject 是要授权的调用方：认证得到的身份，加上它的角色
+type Subject struct {
+	auth.Principal
+	Role string // 匿名请求为空
+}
+
+// Can 判断调用方是否拥有权限
+func (s Subject) Can(p Permission) bool {
+	return Has(s.Role, p)
+}
+
+// DeniedError 表示调用方缺少权限
+type DeniedError struct {
+	Permission Permission
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("缺少权限 %s", e.Permission)
+}
+
+// Rule 是一条路由需要的权限
+type Rule struct {
+	Method     string
+	Pattern    string     // 和注册路由时一样的完整路径，例如 /users/{id}
+	Permission Permission // 需要的权限，为空表示不需要权限
+
+	// Self 不为空时，请求的资源属于调用方本人（由 Owner 判断）的情况下，拥有 Self 也可以
+	Self  Permission
+	Owner func(r *http.Request, s Subject) bool
+}
+
+// PathUser 返回一个 Owner 函数：路径参数 name 是调用方自己的用户 ID 时，资源属于调用方
+func PathUser(name string) func(r *http.Request, s Subject) bool {
+	return func(r *http.Request, s Subject) bool {
+		id, err := strconv.Atoi(r.PathValue(name))
+		return err == nil && s.UserID != 0 && id == s.UserID
+	}
+}
+
+// Policy 是所有路由的授权规则，没有规则的路由不需要权限
+type Policy struct {
+	rules map[string]Rule // "方法 路径" -> 规则
+}
+
+// NewPolicy 创建授权策略，同一个方法和路径只能有一条规则
+func NewPolicy(rules ...Rule) *Policy {
+	p := &Policy{rules: make(map[string]Rule, len(rules))}
+	for _, rule := range rules {
+		key := rule.Method + " " + rule.Pattern
+		if _, dup := p.rules[key]; dup {
+			panic("authz: 重复的规则 " + key)
+		}
+		p.rules[key] = rule
+	}
+	return p
+}
+
+// Rule 返回方法和路径对应的规则，用于生成文档等，没有规则时 ok 为 false
+func (p *Policy) Rule(method, pattern string) (rule Rule, ok bool) {
+	rule, ok = p.rules[method+" "+pattern]
+	return rule, ok
+}
+
+// Check 检查调用方能否执行请求，r.Pattern 需要已经由路由设置好。
+// HEAD 请求按 GET 的规则检查，和路由的处理方式一致。
+func (p *Policy) Check(r *http.Request, s Subject) error {
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	rule, ok := p.rules[method+" "+r.Pattern]
+	if !ok || rule.Permission == "" || s.Can(rule.Permission) {
+		return nil
+	}
+	if rule.Self != "" && rule.Owner != nil && s.Can(rule.Self) && rule.Owner(r, s) {
+		return nil
+	}
+	return &DeniedError{Permission: rule.Permission}
+}
+
+type subjectKey struct{}
+
+// FromContext 返回 Middleware 放进 context 的调用方。
+// 没有经过 Middleware（没有启用授权）时 ok 为 false。
+func FromContext(ctx context.Context) (Subject, bool) {
+	s, ok := ctx.Value(subjectKey{}).(Subject)
+	return s, ok
+}
+
+// Require 在处理函数中检查路由规则之外的权限，例如请求体中修改了角色。
+// 没有启用授权时总是通过。
+func Require(ctx context.Context, p Permission) error {
+	s, ok := FromContext(ctx)
+	if !ok || s.Can(p) {
+		return nil
+	}
+	return &DeniedError{Permission: p}
+}
+
+// Middleware 按 policy 检查每个请求，需要用 router.Use 注册，这样才能拿到匹配的路由。
+// role 返回调用方的角色，例如登录用户的角色从用户数据中读取；出错时和拒绝时都调用 onError。
+func Middleware(policy *Policy, role func(ctx context.Context, p auth.Principal) (string, error),
+	onError func(w http.ResponseWriter, r *http.Request, err error)) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var s Subject
+			if p, ok := auth.FromContext(r.Context()); ok {
+				s.Principal = p
+				var err error
+				if s.Role, err = role(r.Context(), p); err != nil {
+					onError(w, r, err)
+					return
+				}
+			}
+			if err := policy.Check(r, s); err != nil {
+				onError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), subjectKey{}, s)))
+		})
+	}
+}
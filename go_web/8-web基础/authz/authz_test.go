@@ -0,0 +1,179 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/web/auth"
+	"example.com/web/store"
+)
+
+// request 模拟路由匹配之后的请求：r.Pattern 和路径参数都已经设置好
+func request(method, pattern, id string) *http.Request {
+	r := httptest.NewRequest(method, "/", nil)
+	r.Pattern = pattern
+	if id != "" {
+		r.SetPathValue("id", id)
+	}
+	return r
+}
+
+func TestHas(t *testing.T) {
+	tests := []struct {
+		role string
+		p    Permission
+		want bool
+	}{
+		{store.RoleAdmin, UsersDelete, true},
+		{store.RoleAdmin, UsersAssignRole, true},
+		{store.RoleEditor, UsersUpdate, true},
+		{store.RoleEditor, UsersDelete, false},
+		{store.RoleViewer, UsersUpdateSelf, true},
+		{store.RoleViewer, UsersUpdate, false},
+		// 已删除的用户和匿名请求没有角色，未知的角色也没有任何权限
+		{"", UsersUpdateSelf, false},
+		{"root", UsersCreate, false},
+	}
+	for _, tt := range tests {
+		if got := Has(tt.role, tt.p); got != tt.want {
+			t.Errorf("Has(%q, %s) = %v", tt.role, tt.p, got)
+		}
+	}
+}
+
+func TestPathUser(t *testing.T) {
+	owner := PathUser("id")
+	tests := []struct {
+		id     string
+		userID int
+		want   bool
+	}{
+		{"7", 7, true},
+		{"8", 7, false},
+		{"007", 7, true},
+		{"abc", 7, false},
+		{"", 7, false},
+		// API key 和匿名请求没有用户 ID，不属于任何用户
+		{"0", 0, false},
+	}
+	for _, tt := range tests {
+		s := Subject{Principal: auth.Principal{UserID: tt.userID}}
+		if got := owner(request("PATCH", "/users/{id}", tt.id), s); got != tt.want {
+			t.Errorf("路径 id %q，调用方 %d: 得到 %v", tt.id, tt.userID, got)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	p := NewPolicy(
+		Rule{Method: "GET", Pattern: "/secret", Permission: UsersDelete},
+		Rule{Method: "POST", Pattern: "/users", Permission: UsersCreate},
+		Rule{Method: "PATCH", Pattern: "/users/{id}", Permission: UsersUpdate, Self: UsersUpdateSelf, Owner: PathUser("id")},
+		// 没有 Owner 时 Self 不起作用
+		Rule{Method: "PUT", Pattern: "/users/{id}", Permission: UsersUpdate, Self: UsersUpdateSelf},
+	)
+	user := func(id int, role string) Subject {
+		return Subject{Principal: auth.Principal{UserID: id, Method: auth.MethodJWT}, Role: role}
+	}
+	tests := []struct {
+		name    string
+		r       *http.Request
+		s       Subject
+		missing Permission // 为空表示允许
+	}{
+		{"没有规则", request("GET", "/users", ""), Subject{}, ""},
+		{"匿名", request("POST", "/users", ""), Subject{}, UsersCreate},
+		{"拥有权限", request("POST", "/users", ""), user(2, store.RoleEditor), ""},
+		{"HEAD 按 GET 检查", request("HEAD", "/secret", ""), user(2, store.RoleEditor), UsersDelete},
+		{"修改别人", request("PATCH", "/users/{id}", "1"), user(2, store.RoleEditor), ""},
+		{"viewer 修改自己", request("PATCH", "/users/{id}", "3"), user(3, store.RoleViewer), ""},
+		{"viewer 修改别人", request("PATCH", "/users/{id}", "1"), user(3, store.RoleViewer), UsersUpdate},
+		{"已删除的用户修改自己", request("PATCH", "/users/{id}", "3"), user(3, ""), UsersUpdate},
+		{"没有 Owner", request("PUT", "/users/{id}", "3"), user(3, store.RoleViewer), UsersUpdate},
+	}
+	for _, tt := range tests {
+		err := p.Check(tt.r, tt.s)
+		var denied *DeniedError
+		switch {
+		case tt.missing == "" && err != nil:
+			t.Errorf("%s: 应该允许，得到 %v", tt.name, err)
+		case tt.missing != "" && (!errors.As(err, &denied) || denied.Permission != tt.missing):
+			t.Errorf("%s: 应该缺少 %s，得到 %v", tt.name, tt.missing, err)
+		}
+	}
+}
+
+func TestNewPolicyDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("重复的规则应该 panic")
+		}
+	}()
+	NewPolicy(Rule{Method: "POST", Pattern: "/users"}, Rule{Method: "POST", Pattern: "/users"})
+}
+
+func TestMiddleware(t *testing.T) {
+	p := NewPolicy(Rule{Method: "POST", Pattern: "/users", Permission: UsersCreate})
+	roleErr := errors.New("读取用户失败")
+	role := func(ctx context.Context, p auth.Principal) (string, error) {
+		if p.UserID == 99 {
+			return "", roleErr
+		}
+		return p.Role, nil
+	}
+	var gotErr error
+	var gotSubject Subject
+	var requireErr error
+	h := Middleware(p, role, func(w http.ResponseWriter, r *http.Request, err error) {
+		gotErr = err
+		w.WriteHeader(http.StatusForbidden)
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSubject, _ = FromContext(r.Context())
+		requireErr = Require(r.Context(), UsersAssignRole)
+	}))
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		code      int
+		err       error
+		require   bool // 处理函数中 Require(UsersAssignRole) 是否通过
+	}{
+		{"匿名", nil, http.StatusForbidden, &DeniedError{UsersCreate}, false},
+		{"editor", &auth.Principal{Subject: "editor", Role: store.RoleEditor}, http.StatusOK, nil, false},
+		{"admin", &auth.Principal{Subject: "admin", Role: store.RoleAdmin}, http.StatusOK, nil, true},
+		{"读取角色出错", &auth.Principal{UserID: 99}, http.StatusForbidden, roleErr, false},
+	}
+	for _, tt := range tests {
+		gotErr, gotSubject, requireErr = nil, Subject{}, nil
+		r := request("POST", "/users", "")
+		if tt.principal != nil {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), *tt.principal))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != tt.code || (gotErr == nil) != (tt.err == nil) || gotErr != nil && gotErr.Error() != tt.err.Error() {
+			t.Errorf("%s: 返回 %d，错误 %v", tt.name, rec.Code, gotErr)
+			continue
+		}
+		if tt.code == http.StatusOK {
+			if gotSubject.Principal != *tt.principal || gotSubject.Role != tt.principal.Role {
+				t.Errorf("%s: 处理函数中的调用方是 %+v", tt.name, gotSubject)
+			}
+			if (requireErr == nil) != tt.require {
+				t.Errorf("%s: Require 返回 %v", tt.name, requireErr)
+			}
+		}
+	}
+
+	// 没有经过 Middleware 时 Require 总是通过
+	if _, ok := FromContext(context.Background()); ok {
+		t.Fatal("没有经过 Middleware 时 FromContext 返回了调用方")
+	}
+	if err := Require(context.Background(), UsersDelete); err != nil {
+		t.Fatalf("没有启用授权时 Require 返回 %v", err)
+	}
+}
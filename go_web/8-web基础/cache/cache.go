@@ -0,0 +1,34 @@
+// cache 包为用户存储加上一层 cache-aside 缓存：读的时候先查缓存，没有再读存储并写入缓存，
+// 写操作成功之后删除相关的缓存。缓存后端可以是进程内的 LRU（Memory）或者 Redis（Redis）。
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Backend 是缓存后端。值是已经编码好的字节，过期时间由后端负责。
+type Backend interface {
+	// Get 返回 key 的值，不存在或已过期时 ok 为 false
+	Get(ctx context.Context, key string) (val []byte, ok bool, err error)
+	// Set 写入 key，ttl 为 0 表示不过期
+	Set(ctx context.Context, key string, val []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Stats 是缓存的命中统计
+type Stats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Errors int64 `json:"errors"` // 后端出错的次数，出错时直接读存储
+	Shared int64 `json:"shared"` // 未命中时和其他请求共用一次存储读取的次数
+}
+
+// HitRate 返回命中率，没有请求时为 0
+func (s Stats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
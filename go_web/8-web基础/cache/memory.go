@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory 是进程内的 LRU 缓存：超过容量时淘汰最久没有访问的 key，过期的 key 在访问时删除。
+type Memory struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // 最近访问的在前面
+	items    map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key      string
+	val      []byte
+	expireAt time.Time // 零值表示不过期
+}
+
+// NewMemory 创建最多保存 capacity 个 key 的缓存，capacity <= 0 时使用 1000。
+func NewMemory(capacity int) *Memory {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &Memory{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*memoryEntry)
+	if !e.expireAt.IsZero() && !time.Now().Before(e.expireAt) {
+		m.remove(el)
+		return nil, false, nil
+	}
+	m.order.MoveToFront(el)
+	return e.val, true, nil
+}
+
+func (m *Memory) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	e := &memoryEntry{key: key, val: append([]byte(nil), val...)}
+	if ttl > 0 {
+		e.expireAt = time.Now().Add(ttl)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.items[key]; ok {
+		el.Value = e
+		m.order.MoveToFront(el)
+		return nil
+	}
+	m.items[key] = m.order.PushFront(e)
+	for m.order.Len() > m.capacity {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := m.items[key]; ok {
+			m.remove(el)
+		}
+	}
+	return nil
+}
+
+// Len 返回缓存中的 key 数量，包括已过期但还没被删除的
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+func (m *Memory) remove(el *list.Element) {
+	m.order.Remove(el)
+	delete(m.items, el.Value.(*memoryEntry).key)
+}
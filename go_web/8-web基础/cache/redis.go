@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"example.com/database"
+)
+
+// Redis 把缓存保存在 Redis 中，多个服务器进程可以共用，一个进程的写操作会让所有进程的缓存失效。
+type Redis struct {
+	r      *database.Redis
+	prefix string
+}
+
+// NewRedis 使用 9-数据库 中的 Redis 客户端，所有 key 加上 prefix，避免和其他数据冲突。
+// 客户端由调用方关闭，Store.Close 不会关闭它。
+func NewRedis(r *database.Redis, prefix string) *Redis {
+	return &Redis{r: r, prefix: prefix}
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	val, err := c.r.Get(ctx, c.prefix+key)
+	if errors.Is(err, database.ErrNil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return []byte(val), true, nil
+}
+
+func (c *Redis) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	return c.r.Set(ctx, c.prefix+key, string(val), ttl)
+}
+
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.prefix + key
+	}
+	_, err := c.r.Del(ctx, prefixed...)
+	return err
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+var errPanicked = errors.New("缓存加载数据时发生 panic")
+
+// group 合并同一个 key 的并发调用：第一个调用真正执行 fn，其余的等待并共用它的结果。
+// 缓存失效的瞬间大量请求同时未命中时，存储只会被读一次，避免缓存击穿。
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	wg  sync.WaitGroup
+	val any
+	err error
+}
+
+// do 执行 fn 并返回结果，shared 表示结果来自另一个调用
+func (g *group) do(key string, fn func() (any, error)) (val any, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err, true
+	}
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		if p := recover(); p != nil {
+			c.err = errPanicked // 等待的调用拿到错误，panic 继续交给第一个调用的调用方
+			defer panic(p)
+		}
+		g.mu.Lock()
+		if g.calls[key] == c { // forget 之后 key 可能已经属于新的调用
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.val, c.err = fn()
+	return c.val, c.err, false
+}
+
+// forget 让之后的调用不再等待正在执行的 fn，写操作之后调用，避免读到写之前开始的结果
+func (g *group) forget(key string) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+}
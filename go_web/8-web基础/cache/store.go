@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"example.com/web/store"
+)
+
+// 缓存中使用的 key：用户列表和单个用户
+const listKey = "users:list"
+
+func userKey(id int) string { return "users:" + strconv.Itoa(id) }
+
+// Store 包装一个 store.UserRepository，给 List 和 Get 加上缓存，本身也实现了 store.UserRepository。
+//
+// 缓存后端出错时不影响请求，直接读写底层存储，只把错误计入 Stats.Errors。
+type Store struct {
+	next    store.UserRepository
+	backend Backend
+	ttl     time.Duration
+
+	flight group
+	gen    atomic.Uint64 // 每次写操作加一，读到的数据在写之前就开始读时不写入缓存
+
+	hits, misses, errs, shared atomic.Int64
+}
+
+// NewStore 创建带缓存的存储，ttl 是缓存的最长有效期，也是多个进程之间不一致的最长时间。
+func NewStore(next store.UserRepository, backend Backend, ttl time.Duration) *Store {
+	return &Store{next: next, backend: backend, ttl: ttl}
+}
+
+// Stats 返回到目前为止的命中统计
+func (s *Store) Stats() Stats {
+	return Stats{
+		Hits:   s.hits.Load(),
+		Misses: s.misses.Load(),
+		Errors: s.errs.Load(),
+		Shared: s.shared.Load(),
+	}
+}
+
+func (s *Store) List(ctx context.Context) ([]store.User, error) {
+	users, err := cached(s, ctx, listKey, func(ctx context.Context) ([]store.User, error) {
+		return s.next.List(ctx)
+	})
+	return slices.Clone(users), err // 同时未命中的请求共用一个切片，返回副本
+}
+
+func (s *Store) Get(ctx context.Context, id int) (store.User, error) {
+	return cached(s, ctx, userKey(id), func(ctx context.Context) (store.User, error) {
+		return s.next.Get(ctx, id)
+	})
+}
+
+// 写操作先修改存储，成功后删除受影响的缓存，下一次读取时重新加载
+
+func (s *Store) Create(ctx context.Context, u store.User) (store.User, error) {
+	created, err := s.next.Create(ctx, u)
+	if err == nil {
+		s.invalidate(ctx, listKey)
+	}
+	return created, err
+}
+
+func (s *Store) Update(ctx context.Context, u store.User) (store.User, error) {
+	updated, err := s.next.Update(ctx, u)
+	if err == nil {
+		s.invalidate(ctx, listKey, userKey(u.ID))
+	}
+	return updated, err
+}
+
+func (s *Store) Delete(ctx context.Context, id int) error {
+	err := s.next.Delete(ctx, id)
+	if err == nil {
+		s.invalidate(ctx, listKey, userKey(id))
+	}
+	return err
+}
+
+// Close 关闭底层存储和缓存后端
+func (s *Store) Close() error {
+	var errs []error
+	if c, ok := s.next.(io.Closer); ok {
+		errs = append(errs, c.Close())
+	}
+	if c, ok := s.backend.(io.Closer); ok {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Store) invalidate(ctx context.Context, keys ...string) {
+	s.gen.Add(1) // 先增加 gen 再删除，见 cached 中写入缓存之后的检查
+	for _, key := range keys {
+		s.flight.forget(key)
+	}
+	if err := s.backend.Delete(ctx, keys...); err != nil {
+		s.errs.Add(1)
+		slog.WarnContext(ctx, "删除缓存失败", "keys", keys, "error", err)
+	}
+}
+
+// cached 是 cache-aside 的读流程：查缓存，未命中时用 single-flight 从存储加载，再写入缓存。
+// 存储返回的错误（包括 ErrNotFound）不缓存。
+func cached[T any](s *Store, ctx context.Context, key string, load func(context.Context) (T, error)) (T, error) {
+	var zero T
+	data, ok, err := s.backend.Get(ctx, key)
+	if err != nil {
+		s.errs.Add(1)
+		slog.WarnContext(ctx, "读取缓存失败", "key", key, "error", err)
+	}
+	if ok {
+		var v T
+		if err := json.Unmarshal(data, &v); err == nil {
+			s.hits.Add(1)
+			return v, nil
+		}
+		s.errs.Add(1) // 缓存中的数据损坏，当作未命中
+	}
+	s.misses.Add(1)
+
+	val, err, shared := s.flight.do(key, func() (any, error) {
+		gen := s.gen.Load()
+		// 多个请求共用这次加载，不能因为第一个请求被取消而让其他请求失败
+		loadCtx := context.WithoutCancel(ctx)
+		v, err := load(loadCtx)
+		if err != nil {
+			return nil, err
+		}
+		if s.gen.Load() == gen {
+			if data, err := json.Marshal(v); err == nil {
+				if err := s.backend.Set(loadCtx, key, data, s.ttl); err != nil {
+					s.errs.Add(1)
+					slog.WarnContext(ctx, "写入缓存失败", "key", key, "error", err)
+				}
+			}
+			// 检查 gen 和 Set 之间可能发生了写操作，它的 Delete 可能在 Set 之前执行，旧数据又被写了回去。
+			// 写操作先增加 gen 再删除：这里看到 gen 没变，说明那次 Delete 一定在 Set 之后；变了就自己再删一次。
+			if s.gen.Load() != gen {
+				if err := s.backend.Delete(loadCtx, key); err != nil {
+					s.errs.Add(1)
+					slog.WarnContext(ctx, "删除缓存失败", "keys", []string{key}, "error", err)
+				}
+			}
+		}
+		return v, nil
+	})
+	if shared {
+		s.shared.Add(1)
+	}
+	if err != nil {
+		return zero, err
+	}
+	return val.(T), nil
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/web/store"
+)
+
+// slowSet 在第一次 Set 开始时通知 started，等 release 关闭之后才真正写入
+type slowSet struct {
+	*Memory
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *slowSet) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	b.once.Do(func() {
+		close(b.started)
+		<-b.release
+	})
+	return b.Memory.Set(ctx, key, val, ttl)
+}
+
+// 加载完成、正在写入缓存时发生了写操作，旧数据不能留在缓存中
+func TestStoreWriteDuringFill(t *testing.T) {
+	ctx := context.Background()
+	backend := &slowSet{Memory: NewMemory(10), started: make(chan struct{}), release: make(chan struct{})}
+	s := NewStore(store.NewMemoryStore(store.User{ID: 1, Name: "张三", Age: 25, Role: store.RoleViewer}), backend, time.Minute)
+
+	got := make(chan error, 1)
+	go func() {
+		_, err := s.Get(ctx, 1)
+		got <- err
+	}()
+	<-backend.started
+
+	updated := make(chan error, 1)
+	go func() {
+		_, err := s.Update(ctx, store.User{ID: 1, Name: "张三", Age: 26, Role: store.RoleViewer})
+		updated <- err
+	}()
+	// Update 不等正在进行的 Set，它的 Delete 在 Set 之前执行
+	if err := <-updated; err != nil {
+		t.Fatal(err)
+	}
+	close(backend.release)
+	if err := <-got; err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := s.Get(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Age != 26 {
+		t.Fatalf("Update 之后读到的年龄是 %d，缓存中留下了旧数据", u.Age)
+	}
+}
@@ -1,145 +1,216 @@
+//go:build ignore
+
+// client.go 和 server.go 放在同一个目录下，各自有 main 函数，使用 go run client.go 单独运行。
+// 请求都通过 client 包发送，响应是有类型的结构体。需要在命令行中管理用户时使用 cmd/userctl。
+
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
+	"os"
 	"time"
+
+	"example.com/web/client"
+	"example.com/web/config"
 )
 
+// api 访问的服务器地址和超时来自配置（默认 http://localhost:8080），例如 go run client.go -server http://localhost:9090
+var api *client.Client
+
 // HTTP 客户端示例
 func main() {
+	// auth 一节是服务器的配置，客户端不校验其中的账号
+	cfg := config.MustLoad("client", append([]string{"-auth=false"}, os.Args[1:]...))
+	// 所有请求自动带上凭据：配置了 API key 就用 key，否则用用户名密码登录后拿到的令牌
+	opts := []client.Option{client.WithHTTPClient(&http.Client{
+		Transport: newTransport(cfg.Client),
+		Timeout:   cfg.Client.Timeout.D(),
+	})}
+	switch {
+	case cfg.Client.APIKey != "":
+		opts = append(opts, client.WithAPIKey(cfg.Client.APIKey))
+	case cfg.Client.Password == "":
+		log.Fatal("没有配置凭据：用 WEB_API_KEY 提供 API key，或者用 WEB_USERNAME 和 WEB_PASSWORD 提供用户名密码")
+	default:
+		opts = append(opts, client.WithLogin(cfg.Client.Username, cfg.Client.Password))
+	}
+	var err error
+	if api, err = client.New(cfg.Client.BaseURL, opts...); err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+
 	fmt.Println("=== Go HTTP 客户端示例 ===\n")
 
 	// 1. 健康检查
 	fmt.Println("1. 健康检查:")
-	healthCheck()
+	healthCheck(ctx)
 
 	// 2. 获取服务器时间
 	fmt.Println("\n2. 服务器时间:")
-	getServerTime()
+	getServerTime(ctx)
 
 	// 3. 获取所有用户
 	fmt.Println("\n3. 所有用户:")
-	getAllUsers()
+	getAllUsers(ctx)
 
 	// 4. 获取特定用户
 	fmt.Println("\n4. 用户详情:")
-	getUserByID(1)
-	getUserByID(999) // 不存在的用户
+	getUserByID(ctx, 1)
+	getUserByID(ctx, 999) // 不存在的用户
 
 	// 5. 创建新用户
 	fmt.Println("\n5. 创建新用户:")
-	createUser()
+	created := createUser(ctx)
+	createInvalidUser(ctx) // 服务端校验失败，返回字段错误
 
 	// 6. 再次获取所有用户查看结果
 	fmt.Println("\n6. 更新后的用户列表:")
-	getAllUsers()
+	getAllUsers(ctx)
+
+	// 7. 整体替换用户
+	fmt.Println("\n7. 替换用户:")
+	updateUser(ctx, 1)
+
+	// 8. 部分更新用户
+	fmt.Println("\n8. 部分更新用户:")
+	patchUser(ctx, 2)
+
+	// 9. 删除刚才创建的用户（默认账号 admin 对应用户 1，删除后就没有权限了）
+	fmt.Println("\n9. 删除用户:")
+	if created != 0 {
+		deleteUser(ctx, created)
+		getUserByID(ctx, created)
+	}
+}
+
+// newTransport 创建失败时自动重试的 Transport，重试、熔断和对冲请求都打印出来
+func newTransport(cfg config.ClientConfig) *client.Transport {
+	t := &client.Transport{
+		Retry:      client.RetryPolicy{MaxAttempts: cfg.Retries + 1},
+		HedgeDelay: cfg.Hedge.D(),
+		OnRetry: func(req *http.Request, attempt int, delay time.Duration, resp *http.Response, err error) {
+			if err == nil {
+				err = errors.New(resp.Status)
+			}
+			fmt.Printf("  [%s %s 第 %d 次失败: %v，%v 后重试]\n", req.Method, req.URL.Path, attempt, err, delay.Round(time.Millisecond))
+		},
+		OnHedge: func(req *http.Request) {
+			fmt.Printf("  [%s %s 响应太慢，再发一个请求]\n", req.Method, req.URL.Path)
+		},
+	}
+	if cfg.BreakerFailures > 0 {
+		t.Breaker = client.NewBreaker(cfg.BreakerFailures, cfg.BreakerCooldown.D())
+		t.Breaker.OnStateChange = func(from, to client.BreakerState) {
+			fmt.Printf("  [熔断器 %s -> %s]\n", from, to)
+		}
+	}
+	return t
 }
 
 // 健康检查
-func healthCheck() {
-	resp, err := http.Get("http://localhost:8080/health")
+func healthCheck(ctx context.Context) {
+	h, err := api.Health(ctx)
 	if err != nil {
 		fmt.Printf("请求失败: %v\n", err)
 		return
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	fmt.Printf("状态码: %d\n", resp.StatusCode)
-	fmt.Printf("响应: %s\n", string(body))
+	fmt.Printf("状态: %s, 服务: %s, 时间: %s\n", h.Status, h.Service, h.Timestamp.Format("2006-01-02 15:04:05"))
 }
 
 // 获取服务器时间
-func getServerTime() {
-	resp, err := http.Get("http://localhost:8080/time")
+func getServerTime(ctx context.Context) {
+	t, err := api.Time(ctx)
 	if err != nil {
 		fmt.Printf("请求失败: %v\n", err)
 		return
 	}
-	defer resp.Body.Close()
-
-	var result map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&result)
-
-	fmt.Printf("服务器时间: %s\n", result["datetime"])
-	fmt.Printf("时间戳: %.0f\n", result["timestamp"])
+	fmt.Printf("服务器时间: %s\n", t.Datetime)
+	fmt.Printf("时间戳: %d\n", t.Timestamp)
 }
 
-// 获取所有用户
-func getAllUsers() {
-	resp, err := http.Get("http://localhost:8080/users")
-	if err != nil {
-		fmt.Printf("请求失败: %v\n", err)
-		return
+// 获取所有用户：服务端分页返回，AllUsers 沿着 Link 头中的 rel="next" 逐页获取
+func getAllUsers(ctx context.Context) {
+	for user, err := range api.AllUsers(ctx, client.ListOptions{PageSize: 10}) {
+		if err != nil {
+			fmt.Printf("请求失败: %v\n", err)
+			return
+		}
+		printUser(user)
 	}
-	defer resp.Body.Close()
+}
 
-	var users []map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&users)
+func printUser(user client.User) {
+	fmt.Printf("ID: %d, 姓名: %s, 年龄: %d, 角色: %s\n", user.ID, user.Name, user.Age, user.Role)
+}
 
-	for _, user := range users {
-		fmt.Printf("ID: %.0f, 姓名: %s, 年龄: %.0f\n",
-			user["id"], user["name"], user["age"])
+// 根据ID获取用户
+func getUserByID(ctx context.Context, id int) {
+	user, err := api.GetUser(ctx, id)
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		fmt.Printf("用户 ID=%d 不存在\n", id)
+	case err != nil:
+		fmt.Printf("获取用户失败: %v\n", err)
+	default:
+		printUser(user)
 	}
 }
 
-// 根据ID获取用户
-func getUserByID(id int) {
-	url := fmt.Sprintf("http://localhost:8080/users/%d", id)
-	resp, err := http.Get(url)
+// 创建新用户，返回新用户的 ID，失败时返回 0
+func createUser(ctx context.Context) int {
+	user, err := api.CreateUser(ctx, client.User{Name: "王五", Age: 28})
 	if err != nil {
-		fmt.Printf("请求失败: %v\n", err)
-		return
+		fmt.Printf("创建用户失败: %v\n", err)
+		return 0
 	}
-	defer resp.Body.Close()
+	fmt.Printf("创建用户成功: ID=%d, 姓名=%s\n", user.ID, user.Name)
+	return user.ID
+}
 
-	if resp.StatusCode == http.StatusNotFound {
-		fmt.Printf("用户 ID=%d 不存在\n", id)
+// 整体替换用户 (PUT)
+func updateUser(ctx context.Context, id int) {
+	user, err := api.UpdateUser(ctx, id, client.User{Name: "张三丰", Age: 26})
+	if err != nil {
+		fmt.Printf("替换用户失败: %v\n", err)
 		return
 	}
-
-	var user map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&user)
-
-	fmt.Printf("ID: %.0f, 姓名: %s, 年龄: %.0f\n",
-		user["id"], user["name"], user["age"])
+	fmt.Printf("替换用户成功: ID=%d, 姓名=%s, 年龄=%d\n", user.ID, user.Name, user.Age)
 }
 
-// 创建新用户
-func createUser() {
-	newUser := map[string]interface{}{
-		"name": "王五",
-		"age":  28,
+// 部分更新用户 (PATCH)，只发送需要修改的字段
+func patchUser(ctx context.Context, id int) {
+	user, err := api.PatchUser(ctx, id, map[string]any{"age": 31})
+	if err != nil {
+		fmt.Printf("更新用户失败: %v\n", err)
+		return
 	}
+	fmt.Printf("更新用户成功: ID=%d, 姓名=%s, 年龄=%d\n", user.ID, user.Name, user.Age)
+}
 
-	jsonData, _ := json.Marshal(newUser)
-
-	// 创建带超时的客户端
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+// 删除用户 (DELETE)
+func deleteUser(ctx context.Context, id int) {
+	if err := api.DeleteUser(ctx, id); err != nil {
+		fmt.Printf("删除用户失败: %v\n", err)
+		return
 	}
+	fmt.Printf("删除用户 ID=%d 成功\n", id)
+}
 
-	resp, err := client.Post(
-		"http://localhost:8080/users",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
-	if err != nil {
+// 创建一个不合法的用户，演示如何读取服务端返回的字段错误
+func createInvalidUser(ctx context.Context) {
+	_, err := api.CreateUser(ctx, client.User{Name: "", Age: 200})
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) && errors.Is(err, client.ErrValidation) {
+		fmt.Printf("创建用户失败: %v\n", apiErr)
+		for _, fe := range apiErr.Errors {
+			fmt.Printf("  字段 %s: %s\n", fe.Field, fe.Message)
+		}
+	} else if err != nil {
 		fmt.Printf("创建用户失败: %v\n", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusCreated {
-		var createdUser map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&createdUser)
-		fmt.Printf("创建用户成功: ID=%.0f, 姓名=%s\n",
-			createdUser["id"], createdUser["name"])
-	} else {
-		fmt.Printf("创建用户失败，状态码: %d\n", resp.StatusCode)
 	}
 }
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 表示熔断器打开，请求没有发送
+var ErrCircuitOpen = errors.New("client: 熔断器打开，暂停发送请求")
+
+// BreakerState 是熔断器的状态
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // 正常发送请求
+	BreakerOpen                         // 连续失败太多次，所有请求直接返回 ErrCircuitOpen
+	BreakerHalfOpen                     // 冷却结束，只放过一个试探请求，成功就关闭，失败就重新打开
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Breaker 是熔断器：服务器连续失败时暂停发送请求，让它有时间恢复，也让调用方马上得到错误而不是等到超时。
+// 可以被多个 Go 协程同时使用。
+type Breaker struct {
+	failures int
+	cooldown time.Duration
+
+	// OnStateChange 在状态变化后调用，可以为 nil
+	OnStateChange func(from, to BreakerState)
+
+	mu          sync.Mutex
+	state       BreakerState
+	consecutive int       // 连续失败的次数
+	openedAt    time.Time // 最近一次打开的时间
+	probing     bool      // 半开状态下已经放过了试探请求
+}
+
+// NewBreaker 创建熔断器，连续 failures 次失败后打开，cooldown 之后进入半开状态。
+func NewBreaker(failures int, cooldown time.Duration) *Breaker {
+	return &Breaker{failures: max(failures, 1), cooldown: cooldown}
+}
+
+// State 返回当前状态
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow 判断现在能否发送请求，能发送时返回 nil，发送后必须用 Record 报告结果。
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	from := b.state
+	var err error
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			err = ErrCircuitOpen
+			break
+		}
+		b.state, b.probing = BreakerHalfOpen, true
+	case BreakerHalfOpen:
+		if b.probing {
+			err = ErrCircuitOpen
+		} else {
+			b.probing = true
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return err
+}
+
+// Record 报告一个请求的结果，ok 为 false 表示服务器出错或者没有响应。
+func (b *Breaker) Record(ok bool) {
+	b.mu.Lock()
+	from := b.state
+	switch {
+	case ok:
+		b.consecutive = 0
+		if b.state == BreakerHalfOpen {
+			b.state, b.probing = BreakerClosed, false
+		}
+	case b.state == BreakerHalfOpen:
+		b.state, b.probing, b.openedAt = BreakerOpen, false, time.Now()
+	case b.state == BreakerClosed:
+		if b.consecutive++; b.consecutive >= b.failures {
+			b.state, b.openedAt = BreakerOpen, time.Now()
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// abort 表示放过的请求被调用方取消了，不能说明服务器的状况，半开状态下再放过一个试探请求。
+func (b *Breaker) abort() {
+	b.mu.Lock()
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+	b.mu.Unlock()
+}
+
+// notify 在锁外调用回调，回调中可以调用 State
+func (b *Breaker) notify(from, to BreakerState) {
+	if from != to && b.OnStateChange != nil {
+		b.OnStateChange(from, to)
+	}
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	b := NewBreaker(3, cooldown)
+	var changes []string
+	b.OnStateChange = func(from, to BreakerState) {
+		changes = append(changes, from.String()+"->"+to.String()+"("+b.State().String()+")")
+	}
+	allow := func(want error) {
+		t.Helper()
+		if err := b.Allow(); !errors.Is(err, want) {
+			t.Fatalf("状态 %v 时 Allow 返回 %v，应该是 %v", b.State(), err, want)
+		}
+	}
+
+	// 只有连续的失败才计数
+	b.Record(false)
+	b.Record(false)
+	b.Record(true)
+	b.Record(false)
+	b.Record(false)
+	if b.State() != BreakerClosed {
+		t.Fatalf("成功之后只连续失败了 2 次，状态是 %v", b.State())
+	}
+	b.Record(false)
+	allow(ErrCircuitOpen)
+
+	// 冷却之后只放过一个试探请求，失败就重新打开
+	time.Sleep(cooldown)
+	allow(nil)
+	allow(ErrCircuitOpen)
+	b.Record(false)
+	allow(ErrCircuitOpen)
+
+	// 试探请求被取消时再放过一个，成功就关闭
+	time.Sleep(cooldown)
+	allow(nil)
+	b.abort()
+	allow(nil)
+	allow(ErrCircuitOpen)
+	b.Record(true)
+	allow(nil)
+	b.Record(false)
+	if b.State() != BreakerClosed {
+		t.Fatalf("关闭之后失败计数没有清零，状态是 %v", b.State())
+	}
+
+	want := []string{
+		"closed->open(open)",
+		"open->half-open(half-open)",
+		"half-open->open(open)",
+		"open->half-open(half-open)",
+		"half-open->closed(closed)",
+	}
+	if !slices.Equal(changes, want) {
+		t.Fatalf("状态变化是 %q，应该是 %q", changes, want)
+	}
+}
+
+func TestNewBreaker(t *testing.T) {
+	// failures 小于 1 时一次失败就打开
+	b := NewBreaker(0, time.Hour)
+	b.Record(false)
+	if b.State() != BreakerOpen || BreakerState(9).String() != "unknown" {
+		t.Fatalf("状态是 %v", b.State())
+	}
+}
@@ -0,0 +1,259 @@
+// client 包是 server.go 中用户 API 的 Go SDK：请求和响应都是有类型的结构体，
+// 错误是 *APIError，每个方法都接收 context。
+//
+//	c, err := client.New("http://localhost:8080", client.WithLogin("admin", "admin123"))
+//	u, err := c.CreateUser(ctx, client.User{Name: "王五", Age: 28})
+//	if errors.Is(err, client.ErrValidation) { ... }
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Client 访问一个用户 API 服务器，可以被多个 Go 协程同时使用。
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	userAgent  string
+
+	// 认证，最多设置一种
+	apiKey             string
+	username, password string
+
+	mu      sync.Mutex // 保护下面的令牌
+	access  string
+	refresh string
+}
+
+// Option 是 New 的可选设置
+type Option func(*Client)
+
+// WithHTTPClient 使用自己的 *http.Client，例如设置超时或者自定义的 Transport。
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithAPIKey 用 API key 认证
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithToken 直接使用已有的访问令牌，过期后不会自动刷新
+func WithToken(token string) Option {
+	return func(c *Client) { c.access = token }
+}
+
+// WithLogin 在第一次写请求前用用户名密码登录，访问令牌过期后自动刷新
+func WithLogin(username, password string) Option {
+	return func(c *Client) { c.username, c.password = username, password }
+}
+
+// WithUserAgent 设置 User-Agent 头
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// New 创建客户端，baseURL 是服务器地址，例如 http://localhost:8080。
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("client: 服务器地址 %q 不合法", baseURL)
+	}
+	c := &Client{baseURL: u, httpClient: http.DefaultClient, userAgent: "example.com/web/client"}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// BaseURL 返回服务器地址
+func (c *Client) BaseURL() string {
+	return c.baseURL.String()
+}
+
+// Tokens 是 /login 返回的令牌
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // 访问令牌的有效秒数
+}
+
+// Login 用用户名密码登录，之后的请求都带上得到的访问令牌
+func (c *Client) Login(ctx context.Context, username, password string) (Tokens, error) {
+	return c.exchange(ctx, "/login", map[string]string{"username": username, "password": password})
+}
+
+// Refresh 用刷新令牌换取新的令牌
+func (c *Client) Refresh(ctx context.Context) (Tokens, error) {
+	c.mu.Lock()
+	refresh := c.refresh
+	c.mu.Unlock()
+	if refresh == "" {
+		return Tokens{}, errors.New("client: 还没有登录")
+	}
+	return c.exchange(ctx, "/login/refresh", map[string]string{"refresh_token": refresh})
+}
+
+// exchange 调用登录接口换取令牌并保存。登录接口本身不需要认证，所以不经过 do。
+func (c *Client) exchange(ctx context.Context, path string, body any) (Tokens, error) {
+	data, _ := json.Marshal(body)
+	req, err := c.newRequest(ctx, http.MethodPost, path, "application/json", data)
+	if err != nil {
+		return Tokens{}, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Tokens{}, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return Tokens{}, err
+	}
+	var t Tokens
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return Tokens{}, fmt.Errorf("client: 解析 POST %s 的响应: %w", path, err)
+	}
+	c.mu.Lock()
+	c.access, c.refresh = t.AccessToken, t.RefreshToken
+	c.mu.Unlock()
+	return t, nil
+}
+
+// token 返回可用的访问令牌，stale 是刚被服务器拒绝的令牌。
+// 使用 WithLogin 时按需登录或刷新；没有登录信息时返回已有的令牌（可能为空）。
+func (c *Client) token(ctx context.Context, stale string) (string, error) {
+	c.mu.Lock()
+	access, refresh := c.access, c.refresh
+	c.mu.Unlock()
+	if access != "" && access != stale || c.username == "" {
+		return access, nil
+	}
+
+	if refresh != "" {
+		if t, err := c.Refresh(ctx); err == nil {
+			return t.AccessToken, nil
+		}
+	}
+	t, err := c.Login(ctx, c.username, c.password)
+	return t.AccessToken, err
+}
+
+// authorize 给请求加上认证头，返回使用的令牌。retry 表示 stale 刚被服务器拒绝。
+func (c *Client) authorize(ctx context.Context, req *http.Request, stale string, retry bool) (string, error) {
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+		return "", nil
+	}
+	var token string
+	var err error
+	if !retry && req.Method == http.MethodGet {
+		// 读请求可以匿名访问，还没有令牌时不为它登录
+		c.mu.Lock()
+		token = c.access
+		c.mu.Unlock()
+	} else if token, err = c.token(ctx, stale); err != nil {
+		return "", err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return token, nil
+}
+
+// do 发送请求并在 401 时刷新令牌重试一次，返回的响应已经检查过状态码。
+// body 不为 nil 时编码成 JSON，contentType 为空时使用 application/json。
+func (c *Client) do(ctx context.Context, method, path, contentType string, body any) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+		if contentType == "" {
+			contentType = "application/json"
+		}
+	}
+
+	var token string
+	for retry := false; ; retry = true {
+		req, err := c.newRequest(ctx, method, path, contentType, data)
+		if err != nil {
+			return nil, err
+		}
+		if token, err = c.authorize(ctx, req, token, retry); err != nil {
+			return nil, err
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && !retry && c.username != "" {
+			resp.Body.Close()
+			continue
+		}
+		if err := checkResponse(resp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// send 发送请求并把响应体解析到 out，out 为 nil 时丢弃响应体
+func (c *Client) send(ctx context.Context, method, path, contentType string, body, out any) error {
+	resp, err := c.do(ctx, method, path, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: 解析 %s %s 的响应: %w", method, path, err)
+	}
+	return nil
+}
+
+// newRequest 创建请求，path 可以带查询参数，也可以是服务器返回的完整地址（例如 Link 头中的下一页）。
+// 请求会带上令牌或 API key，所以地址必须和 baseURL 是同一个服务器，Link 头指向别处时返回错误。
+func (c *Client) newRequest(ctx context.Context, method, path, contentType string, data []byte) (*http.Request, error) {
+	var u *url.URL
+	var err error
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		u, err = url.Parse(path)
+	} else {
+		u, err = c.baseURL.Parse(c.baseURL.Path + path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != c.baseURL.Scheme || u.Host != c.baseURL.Host { // 也包括 //host/path 这样省略协议的地址
+		return nil, fmt.Errorf("client: %s 和服务器 %s 不是同一个地址，不发送认证信息", u.Redacted(), c.baseURL.Redacted())
+	}
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data) // bytes.Reader 可以重新读取，http.Client 在重定向和重试时会用到
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	return req, nil
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// 常见的错误，可以用 errors.Is 判断：
+//
+//	if errors.Is(err, client.ErrNotFound) { ... }
+var (
+	ErrNotFound     = errors.New("资源不存在")
+	ErrUnauthorized = errors.New("需要认证")
+	ErrForbidden    = errors.New("权限不足")
+	ErrValidation   = errors.New("请求数据校验失败")
+	ErrRateLimited  = errors.New("请求过于频繁")
+)
+
+// FieldError 是一个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// APIError 是服务器返回的 application/problem+json 错误 (RFC 7807)
+type APIError struct {
+	Type       string       `json:"type"`
+	Title      string       `json:"title"`
+	Status     int          `json:"status"`
+	Detail     string       `json:"detail"`
+	Instance   string       `json:"instance"`
+	Code       string       `json:"code"` // 机器可读的错误码，例如 user_not_found
+	Errors     []FieldError `json:"errors,omitempty"`
+	Permission string       `json:"permission,omitempty"` // 403 时缺少的权限
+
+	RetryAfter string `json:"-"` // 429 和 503 时的 Retry-After 头
+}
+
+func (e *APIError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("%s (%d %s)", e.Title, e.Status, e.Code)
+	}
+	return fmt.Sprintf("%s (%d %s): %s", e.Title, e.Status, e.Code, e.Detail)
+}
+
+// Is 按状态码匹配上面的常见错误
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Status == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.Status == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.Status == http.StatusForbidden
+	case ErrValidation:
+		return e.Status == http.StatusUnprocessableEntity
+	case ErrRateLimited:
+		return e.Status == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// checkResponse 在状态码不是 2xx 时把响应体解析成 *APIError
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	e := &APIError{Status: resp.StatusCode, Title: http.StatusText(resp.StatusCode)}
+	json.NewDecoder(resp.Body).Decode(e) // 响应体不是 problem+json 时只保留状态码
+	e.Status = resp.StatusCode
+	e.RetryAfter = resp.Header.Get("Retry-After")
+	return e
+}
@@ -0,0 +1,253 @@
+package client
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Transport 是带重试、熔断和对冲请求的 http.RoundTripper，通过 WithHTTPClient 使用：
+//
+//	t := &client.Transport{Retry: client.RetryPolicy{MaxAttempts: 3}, Breaker: client.NewBreaker(5, 10*time.Second)}
+//	c, err := client.New(addr, client.WithHTTPClient(&http.Client{Transport: t, Timeout: 10 * time.Second}))
+//
+// 它只依赖 net/http，也可以给其他 http.Client 使用。http.Client.Timeout 包括所有重试和等待的时间。
+type Transport struct {
+	Base http.RoundTripper // 真正发送请求的 Transport，为 nil 时使用 http.DefaultTransport
+
+	Retry   RetryPolicy
+	Breaker *Breaker // 为 nil 时不熔断
+
+	// HedgeDelay 大于 0 时，GET 和 HEAD 请求超过这个时间还没有响应就再发一个相同的请求，
+	// 使用先成功返回的那个，取消另一个。用多一点请求换更低的尾部延迟，适合偶尔很慢的服务器。
+	HedgeDelay time.Duration
+
+	// 回调都可以为 nil，会被多个 Go 协程同时调用。
+	// OnRetry 在等待重试前调用，attempt 是刚失败的第几次请求（从 1 开始），resp 和 err 是它的结果。
+	OnRetry func(req *http.Request, attempt int, delay time.Duration, resp *http.Response, err error)
+	// OnHedge 在发出对冲请求时调用
+	OnHedge func(req *http.Request)
+}
+
+// RetryPolicy 决定失败的请求重试几次、每次等多久。
+// 只重试幂等的请求：GET、HEAD、OPTIONS、TRACE、PUT、DELETE，以及带 Idempotency-Key 头的请求，
+// 失败是指没有收到响应，或者状态码是 429、502、503、504。
+type RetryPolicy struct {
+	MaxAttempts int           // 最多发送几次（包括第一次），小于 2 时不重试
+	BaseDelay   time.Duration // 第一次重试前最多等多久，之后每次翻倍，默认 100ms
+	MaxDelay    time.Duration // 每次最多等多久，默认 5s；服务器的 Retry-After 比它长时不再重试
+}
+
+// backoff 返回第 attempt 次失败后的等待时间：指数增长，在 [0, 上限] 中随机取值 (full jitter)，
+// 避免很多客户端在同一时刻一起重试。响应中有 Retry-After 时按服务器说的等，ok 为 false 表示等待太久，不再重试。
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) (d time.Duration, ok bool) {
+	base, maxDelay := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	if maxDelay <= 0 {
+		maxDelay = 5 * time.Second
+	}
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return d, d <= maxDelay
+		}
+	}
+	d = maxDelay
+	if shift := attempt - 1; shift < 32 && base<<shift < maxDelay {
+		d = base << shift
+	}
+	return rand.N(d + 1), true
+}
+
+// retryAfter 解析 Retry-After 头，可以是秒数，也可以是 HTTP 日期
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// idempotent 判断请求是否可以安全地重复发送，规则和 net/http 重试连接错误时一样
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, ok := req.Header["Idempotency-Key"]
+	return ok
+}
+
+// noBody 判断请求没有请求体
+func noBody(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody
+}
+
+// retryable 判断一次请求的结果是否值得重试
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		// 调用方取消或者超时，以及熔断器打开时，重试也没有用
+		return req.Context().Err() == nil && err != ErrCircuitOpen
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// 请求体只能读一次，重试需要 GetBody 重新获取（http.NewRequest 对 bytes.Reader 等会自动设置）
+	canRetry := t.Retry.MaxAttempts > 1 && idempotent(req) && (noBody(req) || req.GetBody != nil)
+	ctx := req.Context()
+
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 && !noBody(req) {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+		resp, err := t.attempt(r)
+		if !canRetry || attempt >= t.Retry.MaxAttempts || !retryable(req, resp, err) {
+			return resp, err
+		}
+		delay, ok := t.Retry.backoff(attempt, resp)
+		if deadline, has := ctx.Deadline(); !ok || has && time.Until(deadline) < delay {
+			return resp, err // 等不到重试的时候了，直接返回这次的结果
+		}
+		if t.OnRetry != nil {
+			t.OnRetry(req, attempt, delay, resp, err)
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // 读完响应体才能复用连接
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// attempt 发送一次请求，先经过熔断器
+func (t *Transport) attempt(req *http.Request) (*http.Response, error) {
+	if t.Breaker == nil {
+		return t.hedged(req)
+	}
+	if err := t.Breaker.Allow(); err != nil {
+		return nil, err
+	}
+	resp, err := t.hedged(req)
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		t.Breaker.abort()
+	default:
+		// 4xx 是请求本身的问题，说明服务器能正常处理请求
+		t.Breaker.Record(err == nil && resp.StatusCode < 500)
+	}
+	return resp, err
+}
+
+// hedged 发送请求，需要时在 HedgeDelay 后再发一个相同的请求，返回先成功的那个
+func (t *Transport) hedged(req *http.Request) (*http.Response, error) {
+	base := t.base()
+	if t.HedgeDelay <= 0 || (req.Method != http.MethodGet && req.Method != http.MethodHead) || !noBody(req) {
+		return base.RoundTrip(req)
+	}
+
+	type result struct {
+		i    int
+		resp *http.Response
+		err  error
+	}
+	results := make(chan result, 2) // 有缓冲，输掉的请求不会阻塞
+	var cancels []context.CancelFunc
+	send := func() {
+		ctx, cancel := context.WithCancel(req.Context())
+		i := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := base.RoundTrip(req.Clone(ctx))
+			results <- result{i, resp, err}
+		}()
+	}
+
+	send()
+	timer := time.NewTimer(t.HedgeDelay)
+	defer timer.Stop()
+	hedge := timer.C
+	pending := 1
+	var err error
+	for pending > 0 {
+		select {
+		case <-hedge:
+			hedge = nil
+			if t.OnHedge != nil {
+				t.OnHedge(req)
+			}
+			send()
+			pending++
+		case r := <-results:
+			pending--
+			if r.err != nil {
+				cancels[r.i]()
+				err = r.err
+				continue
+			}
+			// 取消还没返回的请求，它的响应到达后直接关闭
+			for i, cancel := range cancels {
+				if i != r.i {
+					cancel()
+				}
+			}
+			if pending > 0 {
+				go func() {
+					if other := <-results; other.resp != nil {
+						other.resp.Body.Close()
+					}
+				}()
+			}
+			// 响应体读完之前不能取消它的 context
+			r.resp.Body = &cancelBody{r.resp.Body, cancels[r.i]}
+			return r.resp, nil
+		}
+	}
+	return nil, err
+}
+
+// cancelBody 在响应体关闭时取消请求的 context
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,295 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// statusServer 按收到请求的顺序依次返回 statuses 中的状态码，之后都返回 200，
+// 同时记录每次收到的请求体
+type statusServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+}
+
+func newStatusServer(t *testing.T, statuses ...int) *statusServer {
+	s := &statusServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		n := len(s.bodies)
+		s.bodies = append(s.bodies, string(body))
+		s.mu.Unlock()
+		if n < len(s.statuses) {
+			if s.statuses[n] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "10")
+			}
+			w.WriteHeader(s.statuses[n])
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *statusServer) calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		idemKey  bool
+		body     string
+		statuses []int
+		calls    int
+		want     int
+	}{
+		{"GET 重试到成功", "GET", false, "", []int{503, 502}, 3, 200},
+		{"GET 次数用完", "GET", false, "", []int{503, 504, 503, 503}, 3, 503},
+		{"500 不重试", "GET", false, "", []int{500}, 1, 500},
+		{"404 不重试", "DELETE", false, "", []int{404}, 1, 404},
+		{"PUT 重试时重新发送请求体", "PUT", false, `{"age":1}`, []int{503}, 2, 200},
+		// 不幂等的请求从不重试
+		{"POST", "POST", false, `{"name":"王五"}`, []int{503}, 1, 503},
+		{"PATCH", "PATCH", false, `{"age":1}`, []int{502}, 1, 502},
+		{"带 Idempotency-Key 的 POST", "POST", true, `{"name":"王五"}`, []int{503}, 2, 200},
+		// Retry-After 比 MaxDelay 长，不再等待
+		{"Retry-After 太长", "GET", false, "", []int{429}, 1, 429},
+	}
+	for _, tt := range tests {
+		srv := newStatusServer(t, tt.statuses...)
+		var retries int
+		c := &http.Client{Transport: &Transport{
+			Retry:   RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second},
+			OnRetry: func(*http.Request, int, time.Duration, *http.Response, error) { retries++ },
+		}}
+		req, _ := http.NewRequest(tt.method, srv.URL, strings.NewReader(tt.body))
+		if tt.body == "" {
+			req, _ = http.NewRequest(tt.method, srv.URL, nil)
+		}
+		if tt.idemKey {
+			req.Header.Set("Idempotency-Key", "k1")
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want || srv.calls() != tt.calls || retries != tt.calls-1 {
+			t.Errorf("%s: 返回 %d，服务器收到 %d 个请求，重试 %d 次", tt.name, resp.StatusCode, srv.calls(), retries)
+		}
+		for i, body := range srv.bodies {
+			if body != tt.body {
+				t.Errorf("%s: 第 %d 个请求的请求体是 %q", tt.name, i, body)
+			}
+		}
+	}
+}
+
+// 连接在响应之前断开时，幂等的请求重试，POST 不重试：服务器可能已经处理了它
+func TestRetryConnectionError(t *testing.T) {
+	for _, tt := range []struct {
+		method string
+		calls  int32
+	}{{"GET", 2}, {"POST", 1}} {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+			}
+		}))
+		c := &http.Client{Transport: &Transport{
+			Base:  &http.Transport{DisableKeepAlives: true}, // 否则 net/http 自己会重试断开的空闲连接
+			Retry: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+		}}
+		req, _ := http.NewRequest(tt.method, srv.URL, nil)
+		resp, err := c.Do(req)
+		if tt.method == "GET" && err != nil || tt.method == "POST" && err == nil {
+			t.Errorf("%s: 返回 %v", tt.method, err)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if n := calls.Load(); n != tt.calls {
+			t.Errorf("%s: 服务器收到 %d 个请求，应该是 %d 个", tt.method, n, tt.calls)
+		}
+		srv.Close()
+	}
+}
+
+// 剩下的时间不够等到下次重试时，直接返回这次的结果
+func TestRetryDeadline(t *testing.T) {
+	srv := newStatusServer(t, 503, 503)
+	c := &http.Client{Transport: &Transport{Retry: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	start := time.Now()
+	resp, err := c.Do(req)
+	if err != nil {
+		// 随机的等待时间恰好很短时会重试一次，概率可以忽略
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 503 || time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("返回 %d，用了 %v", resp.StatusCode, time.Since(start))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for attempt, limit := range map[int]time.Duration{1: 10, 2: 20, 3: 40, 4: 50, 100: 50} {
+		limit *= time.Millisecond
+		var longest time.Duration
+		for range 1000 {
+			d, ok := p.backoff(attempt, nil)
+			if !ok || d < 0 || d > limit {
+				t.Fatalf("第 %d 次失败后等待 %v, %v，应该在 [0, %v] 中", attempt, d, ok, limit)
+			}
+			longest = max(longest, d)
+		}
+		// 随机取值 (full jitter)，应该能取到接近上限的值
+		if longest < limit*8/10 {
+			t.Errorf("第 %d 次失败后最长只等了 %v，上限是 %v", attempt, longest, limit)
+		}
+	}
+
+	tests := []struct {
+		retryAfter string
+		want       time.Duration
+		ok         bool
+	}{
+		{"0", 0, true},
+		{"1", time.Second, false}, // 比 MaxDelay 长
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+		{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), time.Hour, false},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{"Retry-After": {tt.retryAfter}}}
+		d, ok := p.backoff(1, resp)
+		if ok != tt.ok || (d-tt.want).Abs() > 2*time.Second { // HTTP 日期只精确到秒
+			t.Errorf("Retry-After: %s 时等待 %v, %v", tt.retryAfter, d, ok)
+		}
+	}
+	// 无法解析的 Retry-After 按指数退避处理
+	if d, ok := p.backoff(1, &http.Response{Header: http.Header{"Retry-After": {"soon"}}}); !ok || d > 10*time.Millisecond {
+		t.Errorf("Retry-After: soon 时等待 %v, %v", d, ok)
+	}
+}
+
+// slowFirst 的第一个请求等待 delay 或者被取消，之后的请求马上返回
+func slowFirst(t *testing.T, delay time.Duration) (srv *httptest.Server, calls *atomic.Int32, canceled chan struct{}) {
+	calls = new(atomic.Int32)
+	canceled = make(chan struct{})
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			close(canceled)
+		case <-time.After(delay):
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, calls, canceled
+}
+
+func TestHedge(t *testing.T) {
+	srv, calls, canceled := slowFirst(t, time.Minute)
+	var hedges atomic.Int32
+	c := &http.Client{Transport: &Transport{HedgeDelay: 10 * time.Millisecond, OnHedge: func(*http.Request) { hedges.Add(1) }}}
+
+	start := time.Now()
+	resp, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 2 || hedges.Load() != 1 {
+		t.Fatalf("返回 %d，服务器收到 %d 个请求，对冲 %d 次", resp.StatusCode, calls.Load(), hedges.Load())
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("对冲请求先返回，却用了 %v", d)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("较慢的请求没有被取消")
+	}
+}
+
+// 不幂等的请求从不对冲，带请求体的请求也不对冲
+func TestNoHedge(t *testing.T) {
+	for _, method := range []string{"POST", "PUT", "DELETE", "PATCH"} {
+		srv, calls, _ := slowFirst(t, 20*time.Millisecond)
+		c := &http.Client{Transport: &Transport{
+			HedgeDelay: time.Millisecond,
+			OnHedge:    func(*http.Request) { t.Errorf("%s 请求被对冲", method) },
+		}}
+		req, _ := http.NewRequest(method, srv.URL, strings.NewReader("{}"))
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted || calls.Load() != 1 {
+			t.Errorf("%s: 返回 %d，服务器收到 %d 个请求", method, resp.StatusCode, calls.Load())
+		}
+	}
+}
+
+func TestTransportBreaker(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusInternalServerError
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	b := NewBreaker(2, time.Hour)
+	c := &http.Client{Transport: &Transport{Breaker: b}}
+	get := func() error {
+		resp, err := c.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// 4xx 说明服务器正常，不算失败
+	status = http.StatusNotFound
+	for range 3 {
+		get()
+	}
+	status = http.StatusInternalServerError
+	get()
+	get()
+	if err := get(); !errors.Is(err, ErrCircuitOpen) || b.State() != BreakerOpen || calls.Load() != 5 {
+		t.Fatalf("两次 500 之后返回 %v，状态 %v，服务器收到 %d 个请求", err, b.State(), calls.Load())
+	}
+
+	// 调用方取消的请求不影响熔断器
+	b2 := NewBreaker(1, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	if _, err := (&http.Client{Transport: &Transport{Breaker: b2}}).Do(req); err == nil || b2.State() != BreakerClosed {
+		t.Fatalf("取消的请求返回 %v，状态 %v", err, b2.State())
+	}
+}
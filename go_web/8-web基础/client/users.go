@@ -0,0 +1,189 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/web/store"
+)
+
+// User 和服务器使用同一个结构体，字段的 JSON 名字不会不一致
+type User = store.User
+
+// ListOptions 是 ListUsers 的查询参数，零值表示不限制
+type ListOptions struct {
+	Page     int // 从 1 开始的页码，不能和 After 同时使用
+	PageSize int // 服务器默认 20，最多 100
+	After    int // 游标分页：只返回 ID 大于 After 的用户
+
+	Name           string // 名字包含的子串
+	MinAge, MaxAge *int
+	Sort           string // 例如 "age,-name"
+}
+
+func (o ListOptions) query() string {
+	v := url.Values{}
+	setInt := func(name string, n int) {
+		if n != 0 {
+			v.Set(name, strconv.Itoa(n))
+		}
+	}
+	setInt("page", o.Page)
+	setInt("page_size", o.PageSize)
+	setInt("after", o.After)
+	if o.Name != "" {
+		v.Set("name", o.Name)
+	}
+	if o.MinAge != nil {
+		v.Set("min_age", strconv.Itoa(*o.MinAge))
+	}
+	if o.MaxAge != nil {
+		v.Set("max_age", strconv.Itoa(*o.MaxAge))
+	}
+	if o.Sort != "" {
+		v.Set("sort", o.Sort)
+	}
+	if len(v) == 0 {
+		return ""
+	}
+	return "?" + v.Encode()
+}
+
+// Page 是 ListUsers 返回的一页用户
+type Page struct {
+	Users []User
+	Total int    // 满足过滤条件的用户总数，来自 X-Total-Count
+	Next  string // 下一页的地址，来自 Link 头，没有下一页时为空
+}
+
+// ListUsers 获取一页用户
+func (c *Client) ListUsers(ctx context.Context, opts ListOptions) (Page, error) {
+	return c.listPage(ctx, "/users"+opts.query())
+}
+
+func (c *Client) listPage(ctx context.Context, path string) (Page, error) {
+	resp, err := c.do(ctx, http.MethodGet, path, "", nil)
+	if err != nil {
+		return Page{}, err
+	}
+	defer resp.Body.Close()
+
+	var p Page
+	if err := json.NewDecoder(resp.Body).Decode(&p.Users); err != nil {
+		return Page{}, fmt.Errorf("client: 解析 GET %s 的响应: %w", path, err)
+	}
+	p.Total, _ = strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	p.Next = nextLink(resp.Header.Get("Link"))
+	return p, nil
+}
+
+// AllUsers 沿着 Link 头逐页获取，依次返回满足 opts 的所有用户。
+// 出错时返回一次错误后结束，提前 break 不会再请求后面的页。
+//
+//	for u, err := range c.AllUsers(ctx, client.ListOptions{PageSize: 100}) { ... }
+func (c *Client) AllUsers(ctx context.Context, opts ListOptions) iter.Seq2[User, error] {
+	return func(yield func(User, error) bool) {
+		path := "/users" + opts.query()
+		for path != "" {
+			p, err := c.listPage(ctx, path)
+			if err != nil {
+				yield(User{}, err)
+				return
+			}
+			for _, u := range p.Users {
+				if !yield(u, nil) {
+					return
+				}
+			}
+			path = p.Next
+		}
+	}
+}
+
+// nextLink 从 RFC 8288 格式的 Link 头中找出 rel="next" 的地址
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+		if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return target[1 : len(target)-1]
+			}
+		}
+	}
+	return ""
+}
+
+// GetUser 获取一个用户，不存在时返回的错误满足 errors.Is(err, ErrNotFound)
+func (c *Client) GetUser(ctx context.Context, id int) (User, error) {
+	var u User
+	err := c.send(ctx, http.MethodGet, userPath(id), "", nil, &u)
+	return u, err
+}
+
+// CreateUser 创建用户，返回带有服务器生成的 ID 的用户。u.ID 必须为 0，u.Role 为空时是 viewer。
+func (c *Client) CreateUser(ctx context.Context, u User) (User, error) {
+	var created User
+	err := c.send(ctx, http.MethodPost, "/users", "", u, &created)
+	return created, err
+}
+
+// UpdateUser 用 u 整体替换用户 id，u.Role 为空时保留原来的角色
+func (c *Client) UpdateUser(ctx context.Context, id int, u User) (User, error) {
+	var updated User
+	err := c.send(ctx, http.MethodPut, userPath(id), "", u, &updated)
+	return updated, err
+}
+
+// PatchUser 只修改 patch 中出现的字段 (JSON Merge Patch)，例如 map[string]any{"age": 30}
+func (c *Client) PatchUser(ctx context.Context, id int, patch map[string]any) (User, error) {
+	var updated User
+	err := c.send(ctx, http.MethodPatch, userPath(id), "application/merge-patch+json", patch, &updated)
+	return updated, err
+}
+
+// DeleteUser 删除用户
+func (c *Client) DeleteUser(ctx context.Context, id int) error {
+	return c.send(ctx, http.MethodDelete, userPath(id), "", nil, nil)
+}
+
+func userPath(id int) string {
+	return "/users/" + strconv.Itoa(id)
+}
+
+// Health 是 /health 的响应
+type Health struct {
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+	Service   string    `json:"service"`
+}
+
+// Health 检查服务器是否正常
+func (c *Client) Health(ctx context.Context) (Health, error) {
+	var h Health
+	err := c.send(ctx, http.MethodGet, "/health", "", nil, &h)
+	return h, err
+}
+
+// ServerTime 是 /time 的响应
+type ServerTime struct {
+	Timestamp int64  `json:"timestamp"`
+	Datetime  string `json:"datetime"`
+	Timezone  string `json:"timezone"`
+}
+
+// Time 获取服务器时间
+func (c *Client) Time(ctx context.Context) (ServerTime, error) {
+	var t ServerTime
+	err := c.send(ctx, http.MethodGet, "/time", "", nil, &t)
+	return t, err
+}
@@ -0,0 +1,49 @@
+// hash-password 从标准输入读取密码，输出 bcrypt 哈希，用来代替 WEB_AUTH_ACCOUNTS 中的明文密码：
+//
+//	go run ./cmd/hash-password                         交互输入密码
+//	echo -n admin123 | go run ./cmd/hash-password      从管道读取
+//	WEB_AUTH_ACCOUNTS='admin:$2a$10$...:1'             哈希中有 $，在 shell 中要用单引号
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"example.com/web/auth"
+)
+
+// 退出码，约定同 go_example/63-退出
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	if len(os.Args) > 1 {
+		fmt.Fprintln(os.Stderr, "用法: hash-password < 密码，密码不要写在命令行参数中")
+		return exitUsage
+	}
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "密码: ")
+	}
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		fmt.Fprintln(os.Stderr, "没有读到密码")
+		return exitUsage
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	fmt.Println(string(hash))
+	return exitOK
+}
@@ -0,0 +1,262 @@
+// loadgen 对 server.go 的用户 API 做压力测试，输出延迟分位数、吞吐量、错误统计和 HDR 延迟分布：
+//
+//	go run ./cmd/loadgen -c 50 -d 30s                           50 个并发，测最大吞吐量
+//	go run ./cmd/loadgen -rate 500 -c 100 -d 1m                 每秒 500 个请求，看这个压力下的延迟
+//	go run ./cmd/loadgen -mix list=1,get=8,create=1 -n 10000    指定请求组合和总请求数
+//	go run ./cmd/loadgen -json report.json                      同时把结果写成 JSON，- 表示标准输出
+//
+// 请求组合中有 list (GET /users)、get (GET /users/{id}) 和 create (POST /users)，
+// create 需要凭据（-api-key 或 -user），压测创建的用户默认在结束后删除。按 Ctrl+C 提前结束并输出结果。
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"example.com/web/client"
+	"example.com/web/loadtest"
+	"example.com/web/pool"
+)
+
+// 退出码，约定同 go_example/63-退出
+const (
+	exitOK     = 0
+	exitError  = 1
+	exitUsage  = 2
+	exitFailed = 3 // 失败的请求比例超过 -max-errors
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	server := fs.String("server", cmp.Or(os.Getenv("WEB_SERVER_URL"), "http://localhost:8080"), "服务器地址 (WEB_SERVER_URL)")
+	apiKey := fs.String("api-key", "", "API key，默认读取环境变量 WEB_API_KEY")
+	username := fs.String("user", os.Getenv("WEB_USERNAME"), "用户名，密码来自环境变量 WEB_PASSWORD")
+	mix := fs.String("mix", "list=6,get=3,create=1", "请求组合，名字=比重，可选 list、get、create")
+	rate := fs.Float64("rate", 0, "每秒发出的请求数，0 表示不限速，由 -c 个并发尽量多发")
+	concurrency := fs.Int("c", 10, "并发数")
+	duration := fs.Duration("d", 10*time.Second, "持续时间，0 表示只由 -n 决定")
+	requests := fs.Int("n", 0, "总请求数，0 表示只由 -d 决定")
+	timeout := fs.Duration("timeout", 5*time.Second, "单个请求的超时时间")
+	jsonOut := fs.String("json", "", "把结果写成 JSON 到这个文件，- 表示标准输出")
+	cleanup := fs.Bool("cleanup", true, "结束后删除压测创建的用户")
+	maxErrors := fs.Float64("max-errors", 100, "失败的请求超过这个百分比时以状态码 3 退出，可用于 CI")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "loadgen: 不接受位置参数 %q\n", fs.Args())
+		return exitUsage
+	}
+	weights, err := parseMix(*mix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loadgen:", err)
+		return exitUsage
+	}
+
+	// 默认的 Transport 对每个主机只保留 2 个空闲连接，并发高时会不停地新建连接，测出来的是建连的开销
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = *concurrency
+	opts := []client.Option{
+		client.WithHTTPClient(&http.Client{Transport: transport}),
+		client.WithUserAgent("example.com/web/cmd/loadgen"),
+	}
+	if key := cmp.Or(*apiKey, os.Getenv("WEB_API_KEY")); key != "" {
+		opts = append(opts, client.WithAPIKey(key))
+	} else if *username != "" {
+		opts = append(opts, client.WithLogin(*username, os.Getenv("WEB_PASSWORD")))
+	}
+	api, err := client.New(*server, opts...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loadgen:", err)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	t := &target{api: api}
+	if err := t.loadIDs(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "loadgen: 获取用户列表失败:", err)
+		return exitError
+	}
+	ops, err := t.ops(weights)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loadgen:", err)
+		return exitUsage
+	}
+
+	fmt.Fprintf(os.Stderr, "压测 %s，请求组合 %s，按 Ctrl+C 提前结束\n", api.BaseURL(), *mix)
+	report, err := loadtest.Run(ctx, loadtest.Options{
+		Ops:         ops,
+		Rate:        *rate,
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Requests:    *requests,
+		Timeout:     *timeout,
+		Classify:    classify,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loadgen:", err)
+		return exitUsage
+	}
+	stop() // 清理时 Ctrl+C 直接退出
+
+	if *cleanup {
+		t.cleanup(*concurrency)
+	}
+
+	// JSON 写到标准输出时，给人看的报告改写到标准错误，方便用管道处理 JSON
+	if *jsonOut == "-" {
+		report.WriteText(os.Stderr)
+	} else {
+		report.WriteText(os.Stdout)
+	}
+	if *jsonOut != "" {
+		if err := writeJSON(*jsonOut, report); err != nil {
+			fmt.Fprintln(os.Stderr, "loadgen: 写入 JSON 失败:", err)
+			return exitError
+		}
+	}
+	if report.Requests > 0 && float64(report.Failed)*100 > *maxErrors*float64(report.Requests) {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// parseMix 解析 list=6,get=3,create=1 形式的请求组合
+func parseMix(s string) (map[string]int, error) {
+	weights := make(map[string]int)
+	for _, part := range strings.Split(s, ",") {
+		name, w, ok := strings.Cut(strings.TrimSpace(part), "=")
+		n, err := strconv.Atoi(w)
+		if !ok || err != nil || n < 0 {
+			return nil, fmt.Errorf("请求组合 %q 的格式应为 名字=比重", part)
+		}
+		weights[name] = n
+	}
+	return weights, nil
+}
+
+// target 是被压测的服务器，记录已有的和压测中创建的用户
+type target struct {
+	api *client.Client
+
+	ids []int // 压测前已有的用户，get 从中随机选择
+
+	mu      sync.Mutex
+	created []int
+}
+
+// loadIDs 获取已有用户的 ID，没有用户时 get 请求都会返回 404
+func (t *target) loadIDs(ctx context.Context) error {
+	for u, err := range t.api.AllUsers(ctx, client.ListOptions{PageSize: 100}) {
+		if err != nil {
+			return err
+		}
+		t.ids = append(t.ids, u.ID)
+	}
+	if len(t.ids) == 0 {
+		t.ids = []int{1}
+	}
+	return nil
+}
+
+// ops 按比重生成请求组合，比重为 0 的请求不发送
+func (t *target) ops(weights map[string]int) ([]loadtest.Op, error) {
+	all := map[string]func(ctx context.Context) error{
+		"list": func(ctx context.Context) error {
+			_, err := t.api.ListUsers(ctx, client.ListOptions{})
+			return err
+		},
+		"get": func(ctx context.Context) error {
+			_, err := t.api.GetUser(ctx, t.ids[rand.N(len(t.ids))])
+			return err
+		},
+		"create": func(ctx context.Context) error {
+			u, err := t.api.CreateUser(ctx, client.User{Name: fmt.Sprintf("压测用户%d", rand.N(1000000)), Age: rand.N(100)})
+			if err == nil {
+				t.mu.Lock()
+				t.created = append(t.created, u.ID)
+				t.mu.Unlock()
+			}
+			return err
+		},
+	}
+	var ops []loadtest.Op
+	for name, w := range weights {
+		do, ok := all[name]
+		if !ok {
+			return nil, fmt.Errorf("未知的请求 %q，可选 list、get、create", name)
+		}
+		if w > 0 {
+			ops = append(ops, loadtest.Op{Name: name, Weight: w, Do: do})
+		}
+	}
+	if len(ops) == 0 {
+		return nil, errors.New("请求组合中至少要有一个比重大于 0 的请求")
+	}
+	return ops, nil
+}
+
+// cleanup 删除压测中创建的用户
+func (t *target) cleanup(workers int) {
+	if len(t.created) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "删除压测创建的 %d 个用户\n", len(t.created))
+	var failed atomic.Int64
+	pool.Map(context.Background(), t.created, workers, func(ctx context.Context, id int) (struct{}, error) {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := t.api.DeleteUser(ctx, id); err != nil && !errors.Is(err, client.ErrNotFound) {
+			failed.Add(1)
+		}
+		return struct{}{}, nil
+	})
+	if n := failed.Load(); n > 0 {
+		fmt.Fprintf(os.Stderr, "loadgen: %d 个用户删除失败\n", n)
+	}
+}
+
+// classify 把 API 错误按状态码和错误码归类，其他错误交给 loadtest.ClassifyError
+func classify(err error) string {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		return fmt.Sprintf("HTTP %d %s", apiErr.Status, apiErr.Code)
+	}
+	return loadtest.ClassifyError(err)
+}
+
+func writeJSON(path string, r *loadtest.Report) error {
+	if path == "-" {
+		return r.WriteJSON(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := r.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
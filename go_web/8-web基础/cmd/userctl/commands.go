@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"example.com/web/client"
+)
+
+// command 是一个子命令。setup 在 fs 上注册命令自己的标志，返回执行命令的函数，
+// 这样补全脚本也能通过 setup 拿到每个命令的标志。
+type command struct {
+	name     string
+	args     string // 位置参数的说明
+	summary  string
+	needsAPI bool
+	setup    func(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error
+}
+
+// commands 在 init 中赋值，completion 命令需要遍历它
+var commands []command
+
+func init() {
+	commands = []command{
+		{"list", "", "列出用户，默认只获取一页", true, listCmd},
+		{"get", "ID...", "查看用户", true, getCmd},
+		{"create", "", "创建用户", true, createCmd},
+		{"update", "ID", "修改用户，只修改给出的字段", true, updateCmd},
+		{"delete", "ID...", "删除用户", true, deleteCmd},
+		{"watch", "", "定时查询用户列表，输出新增、修改和删除的用户，按 Ctrl+C 结束", true, watchCmd},
+		{"health", "", "检查服务器是否正常", true, healthCmd},
+		{"completion", "bash|zsh|fish", "生成 shell 补全脚本，例如 source <(userctl completion bash)", false, completionCmd},
+	}
+}
+
+// filterFlags 注册 list 和 watch 共用的过滤标志
+func filterFlags(fs *flag.FlagSet) func() client.ListOptions {
+	name := fs.String("name", "", "只显示名字包含这个子串的用户")
+	minAge := fs.Int("min-age", -1, "最小年龄")
+	maxAge := fs.Int("max-age", -1, "最大年龄")
+	sort := fs.String("sort", "", "排序，例如 age,-name")
+	return func() client.ListOptions {
+		opts := client.ListOptions{Name: *name, Sort: *sort}
+		if *minAge >= 0 {
+			opts.MinAge = minAge
+		}
+		if *maxAge >= 0 {
+			opts.MaxAge = maxAge
+		}
+		return opts
+	}
+}
+
+func listCmd(fs *flag.FlagSet) func(context.Context, *env, []string) error {
+	filter := filterFlags(fs)
+	page := fs.Int("page", 0, "页码，从 1 开始")
+	pageSize := fs.Int("page-size", 0, "每页的用户数，服务器默认 20，最多 100")
+	all := fs.Bool("all", false, "获取所有页")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 0 {
+			return usagef("list 不接受位置参数")
+		}
+		opts := filter()
+		opts.PageSize = *pageSize
+		if *all {
+			var users []client.User
+			for u, err := range e.api.AllUsers(ctx, opts) {
+				if err != nil {
+					return err
+				}
+				users = append(users, u)
+			}
+			return e.out.users(users)
+		}
+
+		opts.Page = *page
+		p, err := e.api.ListUsers(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if err := e.out.users(p.Users); err != nil {
+			return err
+		}
+		if p.Next != "" {
+			fmt.Fprintf(e.stderr, "共 %d 个用户，使用 -page 翻页或者 -all 获取全部\n", p.Total)
+		}
+		return nil
+	}
+}
+
+// parseIDs 解析位置参数中的用户 ID
+func parseIDs(args []string) ([]int, error) {
+	if len(args) == 0 {
+		return nil, usagef("需要用户 ID")
+	}
+	ids := make([]int, len(args))
+	for i, a := range args {
+		id, err := strconv.Atoi(a)
+		if err != nil || id <= 0 {
+			return nil, usagef("用户 ID %q 必须是正整数", a)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func getCmd(fs *flag.FlagSet) func(context.Context, *env, []string) error {
+	return func(ctx context.Context, e *env, args []string) error {
+		ids, err := parseIDs(args)
+		if err != nil {
+			return err
+		}
+		users := make([]client.User, 0, len(ids))
+		for _, id := range ids {
+			u, err := e.api.GetUser(ctx, id)
+			if err != nil {
+				return err
+			}
+			users = append(users, u)
+		}
+		if len(users) == 1 {
+			return e.out.user(users[0])
+		}
+		return e.out.users(users)
+	}
+}
+
+func createCmd(fs *flag.FlagSet) func(context.Context, *env, []string) error {
+	name := fs.String("name", "", "名字（必填）")
+	age := fs.Int("age", 0, "年龄")
+	role := fs.String("role", "", "角色: admin、editor 或 viewer，默认 viewer")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 0 {
+			return usagef("create 不接受位置参数，用 -name 和 -age 指定用户")
+		}
+		u, err := e.api.CreateUser(ctx, client.User{Name: *name, Age: *age, Role: *role})
+		if err != nil {
+			return err
+		}
+		return e.out.user(u)
+	}
+}
+
+func updateCmd(fs *flag.FlagSet) func(context.Context, *env, []string) error {
+	fs.String("name", "", "新名字")
+	fs.Int("age", 0, "新年龄")
+	fs.String("role", "", "新角色: admin、editor 或 viewer")
+	return func(ctx context.Context, e *env, args []string) error {
+		ids, err := parseIDs(args)
+		if err != nil {
+			return err
+		}
+		if len(ids) != 1 {
+			return usagef("update 一次只能修改一个用户")
+		}
+
+		// 只发送命令行中给出的字段 (JSON Merge Patch)
+		patch := make(map[string]any)
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "name", "role":
+				patch[f.Name] = f.Value.String()
+			case "age":
+				patch[f.Name] = f.Value.(flag.Getter).Get()
+			}
+		})
+		if len(patch) == 0 {
+			return usagef("至少需要 -name、-age、-role 中的一个")
+		}
+		u, err := e.api.PatchUser(ctx, ids[0], patch)
+		if err != nil {
+			return err
+		}
+		return e.out.user(u)
+	}
+}
+
+func deleteCmd(fs *flag.FlagSet) func(context.Context, *env, []string) error {
+	return func(ctx context.Context, e *env, args []string) error {
+		ids, err := parseIDs(args)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := e.api.DeleteUser(ctx, id); err != nil {
+				return err
+			}
+			fmt.Fprintf(e.stderr, "已删除用户 %d\n", id)
+		}
+		return nil
+	}
+}
+
+func watchCmd(fs *flag.FlagSet) func(context.Context, *env, []string) error {
+	filter := filterFlags(fs)
+	interval := fs.Duration("interval", 2*time.Second, "查询间隔")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 0 {
+			return usagef("watch 不接受位置参数")
+		}
+		if *interval <= 0 {
+			return usagef("-interval 必须大于 0")
+		}
+		opts := filter()
+		opts.PageSize = 100
+
+		// 服务器没有推送变化的接口，只能定时获取整个列表，和上一次比较
+		var last map[int]client.User
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		for {
+			current, err := snapshot(ctx, e.api, opts)
+			switch {
+			case ctx.Err() != nil:
+				return nil // Ctrl+C
+			case err != nil && !transient(err):
+				return err
+			case err != nil:
+				fmt.Fprintf(e.stderr, "%s 查询失败，稍后重试: %v\n", time.Now().Format(time.TimeOnly), err)
+			default:
+				if err := emitChanges(e.out, last, current); err != nil {
+					return err
+				}
+				last = current
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// snapshot 获取所有满足条件的用户
+func snapshot(ctx context.Context, api *client.Client, opts client.ListOptions) (map[int]client.User, error) {
+	users := make(map[int]client.User)
+	for u, err := range api.AllUsers(ctx, opts) {
+		if err != nil {
+			return nil, err
+		}
+		users[u.ID] = u
+	}
+	return users, nil
+}
+
+// emitChanges 按 ID 顺序输出两次查询之间的变化，第一次查询时所有用户都是 ADDED
+func emitChanges(p *printer, last, current map[int]client.User) error {
+	ids := slices.Sorted(maps.Keys(current))
+	for id := range last {
+		if _, ok := current[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		before, existed := last[id]
+		after, exists := current[id]
+		var err error
+		switch {
+		case !existed:
+			err = p.event(event{"ADDED", after})
+		case !exists:
+			err = p.event(event{"DELETED", before})
+		case before != after:
+			err = p.event(event{"MODIFIED", after})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transient 判断 watch 中的错误是否可能自己恢复：服务器暂时不可用、网络错误等。
+// 认证失败、参数错误这类问题重试也没有用，直接退出。
+func transient(err error) bool {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status >= 500 || apiErr.Status == http.StatusTooManyRequests
+	}
+	return true
+}
+
+func healthCmd(fs *flag.FlagSet) func(context.Context, *env, []string) error {
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 0 {
+			return usagef("health 不接受位置参数")
+		}
+		h, err := e.api.Health(ctx)
+		if err != nil {
+			return err
+		}
+		return e.out.health(h)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// 补全时这些标志的值有固定的选项
+var flagValues = map[string][]string{
+	"o":    formats,
+	"role": {"admin", "editor", "viewer"},
+}
+
+var shells = []string{"bash", "zsh", "fish"}
+
+func completionCmd(fs *flag.FlagSet) func(context.Context, *env, []string) error {
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return usagef("需要 shell 名字: %s", strings.Join(shells, "、"))
+		}
+		switch args[0] {
+		case "bash":
+			writeBash(e.out.w, false)
+		case "zsh":
+			writeBash(e.out.w, true)
+		case "fish":
+			writeFish(e.out.w)
+		default:
+			return usagef("不支持的 shell %q，可选 %s", args[0], strings.Join(shells, "、"))
+		}
+		return nil
+	}
+}
+
+// flagSets 返回全局标志和每个命令的标志，命令的标志包括全局标志，和 run 中一样
+func flagSets() (global *flag.FlagSet, byCommand map[string]*flag.FlagSet) {
+	opts := defaultOptions()
+	global = flag.NewFlagSet("userctl", flag.ContinueOnError)
+	opts.register(global)
+	byCommand = make(map[string]*flag.FlagSet)
+	for _, c := range commands {
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		c.setup(fs)
+		opts.register(fs)
+		byCommand[c.name] = fs
+	}
+	return global, byCommand
+}
+
+// flagNames 返回 fs 中所有标志的名字，带上前面的 "-"；valueOnly 为 true 时只返回需要值的标志
+func flagNames(fs *flag.FlagSet, valueOnly bool) []string {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if !valueOnly || !isBoolFlag(f) {
+			names = append(names, "-"+f.Name)
+		}
+	})
+	return names
+}
+
+// writeBash 输出 bash 补全脚本；zsh 通过 bashcompinit 使用同一个脚本
+func writeBash(w io.Writer, zsh bool) {
+	global, byCommand := flagSets()
+	if zsh {
+		fmt.Fprintln(w, "#compdef userctl\n# userctl 的 zsh 补全，使用方法: source <(userctl completion zsh)")
+		fmt.Fprintln(w, "autoload -U +X bashcompinit && bashcompinit")
+	} else {
+		fmt.Fprintln(w, "# userctl 的 bash 补全，使用方法: source <(userctl completion bash)")
+	}
+
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+	fmt.Fprintf(w, `_userctl() {
+    local cur prev cmd i words
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+    # 找出子命令，跳过全局标志和它们的值
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case "${COMP_WORDS[i]}" in
+            %s) ((i++)) ;;
+            -*) ;;
+            *) cmd="${COMP_WORDS[i]}"; break ;;
+        esac
+    done
+
+    case "$prev" in
+`, strings.Join(flagNames(global, true), "|"))
+	for _, name := range slices.Sorted(maps.Keys(flagValues)) {
+		fmt.Fprintf(w, "        -%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", name, strings.Join(flagValues[name], " "))
+	}
+	fmt.Fprintf(w, `    esac
+
+    case "$cmd" in
+        "") words=%q ;;
+`, strings.Join(append(names, flagNames(global, false)...), " "))
+	for _, c := range commands {
+		words := flagNames(byCommand[c.name], false)
+		if c.name == "completion" {
+			words = append(words, shells...)
+		}
+		fmt.Fprintf(w, "        %s) words=%q ;;\n", c.name, strings.Join(words, " "))
+	}
+	fmt.Fprint(w, `    esac
+    COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+complete -F _userctl userctl
+`)
+}
+
+// writeFish 输出 fish 补全脚本，Go 的单横线长标志在 fish 中是 -o (old style) 选项
+func writeFish(w io.Writer) {
+	global, byCommand := flagSets()
+	fmt.Fprintln(w, "# userctl 的 fish 补全，使用方法: userctl completion fish | source")
+	fmt.Fprintln(w, "complete -c userctl -f")
+
+	isGlobal := make(map[string]bool)
+	fishFlag := func(cond string, f *flag.Flag) {
+		line := fmt.Sprintf("complete -c userctl%s -o %s -d %s", cond, f.Name, fishQuote(f.Usage))
+		if values, ok := flagValues[f.Name]; ok {
+			line += " -x -a " + fishQuote(strings.Join(values, " "))
+		} else if !isBoolFlag(f) {
+			line += " -r"
+		}
+		fmt.Fprintln(w, line)
+	}
+	global.VisitAll(func(f *flag.Flag) {
+		isGlobal[f.Name] = true
+		fishFlag("", f)
+	})
+	for _, c := range commands {
+		fmt.Fprintf(w, "complete -c userctl -n __fish_use_subcommand -a %s -d %s\n", c.name, fishQuote(c.summary))
+		cond := fmt.Sprintf(" -n '__fish_seen_subcommand_from %s'", c.name)
+		byCommand[c.name].VisitAll(func(f *flag.Flag) {
+			if !isGlobal[f.Name] {
+				fishFlag(cond, f)
+			}
+		})
+	}
+	fmt.Fprintf(w, "complete -c userctl -n '__fish_seen_subcommand_from completion' -a %s\n", fishQuote(strings.Join(shells, " ")))
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", `\'`) + "'"
+}
@@ -0,0 +1,266 @@
+// userctl 是用户服务的命令行工具，通过 client 包访问 server.go：
+//
+//	go run ./cmd/userctl list -sort -age              列出用户，-all 获取所有页
+//	go run ./cmd/userctl get 1 2                      查看用户
+//	go run ./cmd/userctl create -name 王五 -age 28     创建用户
+//	go run ./cmd/userctl update 3 -age 29             只修改给出的字段
+//	go run ./cmd/userctl delete 3                     删除用户
+//	go run ./cmd/userctl watch -interval 2s           定时查询，打印新增、修改和删除的用户
+//	go run ./cmd/userctl health                       健康检查
+//	go run ./cmd/userctl completion bash              生成 shell 补全脚本
+//
+// 全局标志写在子命令前后都可以，例如 userctl -o json list 或 userctl list -o yaml。
+// 输出格式有 table、json、yaml 和 csv。服务器地址和凭据也可以用环境变量提供，见 userctl -h。
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"time"
+
+	"example.com/web/client"
+)
+
+// 退出码，约定同 go_example/63-退出，API 错误按类别区分，方便脚本判断
+const (
+	exitOK       = 0
+	exitError    = 1 // 网络错误、服务器错误等
+	exitUsage    = 2 // 命令行参数错误
+	exitNotFound = 3 // 用户不存在
+	exitDenied   = 4 // 没有认证或者权限不足 (401、403)
+	exitInvalid  = 5 // 服务器拒绝了请求数据 (400、409、422 等)
+)
+
+// options 是所有子命令共用的全局标志
+type options struct {
+	server   string
+	token    string
+	apiKey   string
+	username string
+	password string
+	output   string
+	timeout  time.Duration
+	retries  int
+}
+
+// register 把全局标志注册到 fs，默认值是 o 中当前的值，所以子命令的 FlagSet 可以覆盖主命令中给出的值
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.server, "server", o.server, "服务器地址 (WEB_SERVER_URL)")
+	fs.StringVar(&o.token, "token", o.token, "访问令牌，来自 POST /login (WEB_TOKEN)")
+	fs.StringVar(&o.apiKey, "api-key", o.apiKey, "API key (WEB_API_KEY)")
+	fs.StringVar(&o.username, "user", o.username, "用户名，和密码一起用于登录 (WEB_USERNAME)")
+	fs.StringVar(&o.password, "password", o.password, "密码，建议用环境变量 WEB_PASSWORD 提供")
+	fs.StringVar(&o.output, "o", o.output, "输出格式: "+formatList)
+	fs.DurationVar(&o.timeout, "timeout", o.timeout, "每个命令的超时时间")
+	fs.IntVar(&o.retries, "retries", o.retries, "幂等请求失败后最多重试几次")
+}
+
+func defaultOptions() options {
+	return options{
+		server:   envOr("WEB_SERVER_URL", "http://localhost:8080"),
+		username: os.Getenv("WEB_USERNAME"),
+		output:   "table",
+		timeout:  30 * time.Second,
+		retries:  2,
+	}
+}
+
+func envOr(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+// newClient 按全局标志创建客户端，凭据的优先级是 -token、-api-key、-user。
+// 令牌、key 和密码没有给出标志时才读取环境变量，不作为标志的默认值，免得出现在 -h 的输出中。
+func (o *options) newClient() (*client.Client, error) {
+	token := cmp.Or(o.token, os.Getenv("WEB_TOKEN"))
+	apiKey := cmp.Or(o.apiKey, os.Getenv("WEB_API_KEY"))
+	password := cmp.Or(o.password, os.Getenv("WEB_PASSWORD"))
+
+	opts := []client.Option{client.WithHTTPClient(&http.Client{Transport: &client.Transport{
+		Retry: client.RetryPolicy{MaxAttempts: o.retries + 1},
+	}})}
+	switch {
+	case token != "":
+		opts = append(opts, client.WithToken(token))
+	case apiKey != "":
+		opts = append(opts, client.WithAPIKey(apiKey))
+	case o.username != "":
+		opts = append(opts, client.WithLogin(o.username, password))
+	}
+	return client.New(o.server, opts...)
+}
+
+// usageError 表示命令行参数错误，退出码是 exitUsage
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Sprintf(format, args...)}
+}
+
+// env 是子命令运行时需要的东西
+type env struct {
+	api    *client.Client
+	out    *printer
+	stderr io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	opts := defaultOptions()
+	fs := flag.NewFlagSet("userctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: userctl [全局标志] 命令 [参数]\n\n命令:")
+		for _, c := range commands {
+			fmt.Fprintf(fs.Output(), "  %-12s %s\n", c.name, c.summary)
+		}
+		fmt.Fprintln(fs.Output(), "\n全局标志:")
+		fs.PrintDefaults()
+		fmt.Fprintln(fs.Output(), "\n使用 userctl 命令 -h 查看命令的参数")
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	i := slices.IndexFunc(commands, func(c command) bool { return c.name == fs.Arg(0) })
+	if i < 0 {
+		fmt.Fprintf(stderr, "userctl: 未知命令 %q\n", fs.Arg(0))
+		fs.Usage()
+		return exitUsage
+	}
+	cmd := commands[i]
+
+	sub := flag.NewFlagSet("userctl "+cmd.name, flag.ContinueOnError)
+	sub.SetOutput(stderr)
+	action := cmd.setup(sub)
+	opts.register(sub)
+	sub.Usage = func() {
+		fmt.Fprintf(sub.Output(), "用法: userctl %s [标志] %s\n\n%s\n\n标志:\n", cmd.name, cmd.args, cmd.summary)
+		sub.PrintDefaults()
+	}
+	if err := sub.Parse(interleave(sub, fs.Args()[1:])); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	e := &env{out: &printer{w: stdout, format: opts.output}, stderr: stderr}
+	if !slices.Contains(formats, opts.output) {
+		fmt.Fprintf(stderr, "userctl: 不支持的输出格式 %q，可选 %s\n", opts.output, formatList)
+		return exitUsage
+	}
+	if cmd.needsAPI {
+		var err error
+		if e.api, err = opts.newClient(); err != nil {
+			fmt.Fprintln(stderr, "userctl:", err)
+			return exitUsage
+		}
+	}
+
+	// Ctrl+C 取消正在进行的请求；watch 以外的命令还有超时
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if cmd.name != "watch" && opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+
+	err := action(ctx, e, sub.Args())
+	if err == nil {
+		return exitOK
+	}
+	return report(stderr, err, sub)
+}
+
+// interleave 把标志移到位置参数前面，这样 userctl get 1 -o json 也能识别 -o。
+// flag 包遇到第一个位置参数就停止解析，"--" 之后的参数保持原样。
+func interleave(fs *flag.FlagSet, args []string) []string {
+	var flags, positional []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			positional = append(positional, args[i+1:]...)
+			i = len(args)
+		case len(a) > 1 && a[0] == '-' && !isNumber(a):
+			flags = append(flags, a)
+			// 不是 -name=value 形式、也不是布尔标志时，下一个参数是它的值
+			name := a[1:]
+			if name[0] == '-' {
+				name = name[1:]
+			}
+			if f := fs.Lookup(name); f != nil && !isBoolFlag(f) && i+1 < len(args) {
+				i++
+				flags = append(flags, args[i])
+			}
+		default:
+			positional = append(positional, a)
+		}
+	}
+	return append(append(flags, "--"), positional...)
+}
+
+func isNumber(s string) bool {
+	_, err := fmt.Sscanf(s, "%d", new(int))
+	return err == nil
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// report 打印错误并返回对应的退出码
+func report(stderr io.Writer, err error, fs *flag.FlagSet) int {
+	fmt.Fprintln(stderr, "userctl:", err)
+
+	var ue usageError
+	var apiErr *client.APIError
+	switch {
+	case errors.As(err, &ue):
+		fs.Usage()
+		return exitUsage
+	case errors.As(err, &apiErr):
+		for _, fe := range apiErr.Errors {
+			fmt.Fprintf(stderr, "  %s: %s\n", fe.Field, fe.Message)
+		}
+		switch {
+		case apiErr.Status == http.StatusNotFound:
+			return exitNotFound
+		case apiErr.Status == http.StatusUnauthorized:
+			fmt.Fprintln(stderr, "提示: 使用 -token、-api-key 或 -user 提供凭据")
+			return exitDenied
+		case apiErr.Status == http.StatusForbidden:
+			return exitDenied
+		case apiErr.Status >= 400 && apiErr.Status < 500 && apiErr.Status != http.StatusTooManyRequests:
+			return exitInvalid
+		}
+	}
+	return exitError
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"example.com/web/client"
+)
+
+// 支持的输出格式
+var (
+	formats    = []string{"table", "json", "yaml", "csv"}
+	formatList = strings.Join(formats, "、")
+)
+
+// printer 按 -o 指定的格式输出结果：json 和 yaml 直接编码结构体，table 和 csv 输出表头和各行。
+// 表格中名字放在最后一列，中文字符在终端里占两格，放在中间会让后面的列对不齐。
+type printer struct {
+	w      io.Writer
+	format string
+
+	eventHeader bool // watch 已经输出过表头
+}
+
+var userHeader = []string{"ID", "AGE", "ROLE", "NAME"}
+
+func userRow(u client.User) []string {
+	return []string{strconv.Itoa(u.ID), strconv.Itoa(u.Age), u.Role, u.Name}
+}
+
+// user 输出一个用户
+func (p *printer) user(u client.User) error {
+	return p.print(u, userHeader, [][]string{userRow(u)})
+}
+
+// users 输出一组用户，json 和 yaml 中是数组
+func (p *printer) users(users []client.User) error {
+	rows := make([][]string, len(users))
+	for i, u := range users {
+		rows[i] = userRow(u)
+	}
+	if users == nil {
+		users = []client.User{} // JSON 中输出 [] 而不是 null
+	}
+	return p.print(users, userHeader, rows)
+}
+
+// health 输出健康检查的结果
+func (p *printer) health(h client.Health) error {
+	return p.print(h, []string{"STATUS", "SERVICE", "TIMESTAMP"},
+		[][]string{{h.Status, h.Service, h.Timestamp.Format(time.RFC3339)}})
+}
+
+func (p *printer) print(v any, header []string, rows [][]string) error {
+	switch p.format {
+	case "json":
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		return encodeYAML(p.w, v)
+	case "csv":
+		return writeCSV(p.w, header, rows)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// event 是 watch 输出的一条变化
+type event struct {
+	Type string      `json:"type" yaml:"type"` // ADDED、MODIFIED 或 DELETED
+	User client.User `json:"user" yaml:"user"`
+}
+
+// event 输出 watch 发现的一条变化，每条单独输出，不等待后面的变化：
+// json 每行一个对象，yaml 每条一个文档，table 和 csv 只在第一条前输出表头。
+func (p *printer) event(e event) error {
+	row := append([]string{e.Type}, userRow(e.User)...)
+	header := append([]string{"EVENT"}, userHeader...)
+	first := !p.eventHeader
+	p.eventHeader = true
+
+	switch p.format {
+	case "json":
+		return json.NewEncoder(p.w).Encode(e)
+	case "yaml":
+		if !first {
+			fmt.Fprintln(p.w, "---")
+		}
+		return encodeYAML(p.w, e)
+	case "csv":
+		if !first {
+			header = nil
+		}
+		return writeCSV(p.w, header, [][]string{row})
+	}
+	// 表格逐行输出，不能用 tabwriter 对齐，使用固定宽度
+	const format = "%-9s %-6s %-4s %-7s %s\n"
+	if first {
+		fmt.Fprintf(p.w, format, toAny(header)...)
+	}
+	_, err := fmt.Fprintf(p.w, format, toAny(row)...)
+	return err
+}
+
+func toAny(s []string) []any {
+	out := make([]any, len(s))
+	for i, v := range s {
+		out[i] = v
+	}
+	return out
+}
+
+func encodeYAML(w io.Writer, v any) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// writeCSV 输出表头（为 nil 时不输出）和各行，表头使用小写
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if header != nil {
+		lower := make([]string, len(header))
+		for i, h := range header {
+			lower[i] = strings.ToLower(h)
+		}
+		cw.Write(lower)
+	}
+	cw.WriteAll(rows) // WriteAll 会 Flush
+	return cw.Error()
+}
@@ -0,0 +1,155 @@
+// collections 包提供适用于任意元素类型的切片组合函数，用法见 go_example/43-组合函数。
+//
+// 函数分三组：
+//
+//   - 这个文件中的函数直接处理切片，返回新的切片，不修改参数
+//   - seq.go 中以 Seq 结尾的函数处理 iter.Seq，按需计算，可以和 slices.Values、slices.Collect 组合
+//   - parallel.go 中以 Parallel 开头的函数把大切片分段，用多个 Go 协程同时处理
+//
+// 简单的情况下直接写 for 循环往往更清楚，这些函数适合在组合多个步骤时使用。
+package collections
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Index 返回 v 第一次出现的位置，没有时返回 -1。
+func Index[T comparable](s []T, v T) int {
+	for i, x := range s {
+		if x == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// Include 判断 s 中是否有 v。
+func Include[T comparable](s []T, v T) bool {
+	return Index(s, v) >= 0
+}
+
+// Any 判断是否有元素满足 f，空切片返回 false。
+func Any[T any](s []T, f func(T) bool) bool {
+	for _, x := range s {
+		if f(x) {
+			return true
+		}
+	}
+	return false
+}
+
+// All 判断是否所有元素都满足 f，空切片返回 true。
+func All[T any](s []T, f func(T) bool) bool {
+	for _, x := range s {
+		if !f(x) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter 返回满足 f 的元素。
+func Filter[T any](s []T, f func(T) bool) []T {
+	out := make([]T, 0)
+	for _, x := range s {
+		if f(x) {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// Map 返回对每个元素执行 f 的结果。
+func Map[T, U any](s []T, f func(T) U) []U {
+	out := make([]U, len(s))
+	for i, x := range s {
+		out[i] = f(x)
+	}
+	return out
+}
+
+// Reduce 从 init 开始，依次用 f 把每个元素合并进结果。
+func Reduce[T, A any](s []T, init A, f func(acc A, x T) A) A {
+	acc := init
+	for _, x := range s {
+		acc = f(acc, x)
+	}
+	return acc
+}
+
+// GroupBy 按 key 分组，每组中元素的顺序和 s 中一样。
+func GroupBy[T any, K comparable](s []T, key func(T) K) map[K][]T {
+	groups := make(map[K][]T)
+	for _, x := range s {
+		k := key(x)
+		groups[k] = append(groups[k], x)
+	}
+	return groups
+}
+
+// Partition 把元素分成满足 f 的和不满足 f 的两部分。
+func Partition[T any](s []T, f func(T) bool) (yes, no []T) {
+	yes, no = make([]T, 0), make([]T, 0)
+	for _, x := range s {
+		if f(x) {
+			yes = append(yes, x)
+		} else {
+			no = append(no, x)
+		}
+	}
+	return yes, no
+}
+
+// Chunk 把 s 切成每段 n 个元素，最后一段可能不满 n 个。n 小于 1 时 panic。
+// 每段是 s 的子切片，和 s 共用底层数组。
+func Chunk[T any](s []T, n int) [][]T {
+	if n < 1 {
+		panic("collections: Chunk 的 n 必须大于 0")
+	}
+	chunks := make([][]T, 0, (len(s)+n-1)/n)
+	for len(s) > 0 {
+		k := min(n, len(s))
+		chunks = append(chunks, s[:k:k])
+		s = s[k:]
+	}
+	return chunks
+}
+
+// Pair 是 Zip 的结果
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Zip 把两个切片对应位置的元素配成一对，长度以较短的为准。
+func Zip[A, B any](a []A, b []B) []Pair[A, B] {
+	n := min(len(a), len(b))
+	out := make([]Pair[A, B], n)
+	for i := range n {
+		out[i] = Pair[A, B]{a[i], b[i]}
+	}
+	return out
+}
+
+// Uniq 去掉重复的元素，保留每个值第一次出现的位置。
+func Uniq[T comparable](s []T) []T {
+	seen := make(map[T]struct{}, len(s))
+	out := make([]T, 0)
+	for _, x := range s {
+		if _, ok := seen[x]; !ok {
+			seen[x] = struct{}{}
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// SortBy 返回按 key 从小到大排好序的副本，key 相同的元素保持原来的顺序。
+func SortBy[T any, K cmp.Ordered](s []T, key func(T) K) []T {
+	out := slices.Clone(s)
+	slices.SortStableFunc(out, func(a, b T) int {
+		return cmp.Compare(key(a), key(b))
+	})
+	return out
+}
@@ -0,0 +1,219 @@
+package collections
+
+import (
+	"iter"
+	"maps"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func isEven(n int) bool { return n%2 == 0 }
+
+func TestSlices(t *testing.T) {
+	s := []int{3, 1, 4, 1, 5, 9, 2, 6}
+	orig := slices.Clone(s)
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"Index", Index(s, 1), 1},
+		{"Index 没有", Index(s, 7), -1},
+		{"Include", Include(s, 9), true},
+		{"Any", Any(s, func(n int) bool { return n > 8 }), true},
+		{"Any 空切片", Any(nil, isEven), false},
+		{"All", All(s, func(n int) bool { return n > 0 }), true},
+		{"All 空切片", All(nil, isEven), true},
+		{"Filter", Filter(s, isEven), []int{4, 2, 6}},
+		// 没有满足的元素时返回空切片而不是 nil，编码成 JSON 是 []
+		{"Filter 空", Filter(s, func(int) bool { return false }), []int{}},
+		{"Map", Map(s[:3], strconv.Itoa), []string{"3", "1", "4"}},
+		{"Reduce", Reduce(s, "", func(acc string, n int) string { return acc + strconv.Itoa(n) }), "31415926"},
+		{"GroupBy", GroupBy(s, isEven), map[bool][]int{true: {4, 2, 6}, false: {3, 1, 1, 5, 9}}},
+		{"Chunk", Chunk(s, 3), [][]int{{3, 1, 4}, {1, 5, 9}, {2, 6}}},
+		{"Chunk 空", Chunk([]int{}, 3), [][]int{}},
+		{"Zip", Zip(s[:2], []string{"a", "b", "c"}), []Pair[int, string]{{3, "a"}, {1, "b"}}},
+		{"Uniq", Uniq(s), []int{3, 1, 4, 5, 9, 2, 6}},
+		// key 相同的元素保持原来的顺序
+		{"SortBy", SortBy([]string{"bb", "a", "cc", "d"}, func(s string) int { return len(s) }), []string{"a", "d", "bb", "cc"}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: 得到 %v，应该是 %v", tt.name, tt.got, tt.want)
+		}
+	}
+	yes, no := Partition(s, isEven)
+	if !slices.Equal(yes, []int{4, 2, 6}) || !slices.Equal(no, []int{3, 1, 1, 5, 9}) {
+		t.Errorf("Partition: 得到 %v, %v", yes, no)
+	}
+	if !slices.Equal(s, orig) {
+		t.Fatalf("参数被修改成了 %v", s)
+	}
+
+	// Chunk 的每段不能 append 到下一段
+	chunks := Chunk(s, 3)
+	_ = append(chunks[0], 100)
+	if s[3] != 1 {
+		t.Fatal("append 到 Chunk 的第一段覆盖了第二段")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("n 为 0 时 Chunk 应该 panic")
+		}
+	}()
+	Chunk(s, 0)
+}
+
+// naturals 是无限的序列 0, 1, 2, ...，记录被取了几个元素
+func naturals(pulled *int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 0; ; i++ {
+			*pulled++
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func TestSeq(t *testing.T) {
+	// 按需计算：从无限序列中只取用到的部分
+	var pulled int
+	got := slices.Collect(TakeSeq(MapSeq(FilterSeq(naturals(&pulled), isEven), func(n int) int { return n * n }), 4))
+	if !slices.Equal(got, []int{0, 4, 16, 36}) || pulled != 7 {
+		t.Fatalf("得到 %v，从序列中取了 %d 个元素，应该是 7 个", got, pulled)
+	}
+
+	pulled = 0
+	var chunks [][]int
+	for c := range ChunkSeq(naturals(&pulled), 3) {
+		if chunks = append(chunks, c); len(chunks) == 2 {
+			break
+		}
+	}
+	if !reflect.DeepEqual(chunks, [][]int{{0, 1, 2}, {3, 4, 5}}) || pulled != 6 {
+		t.Fatalf("ChunkSeq 得到 %v，取了 %d 个元素", chunks, pulled)
+	}
+
+	s := slices.Values([]int{1, 2, 2, 3, 1, 4, 5})
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"TakeSeq 0", slices.Collect(TakeSeq(s, 0)), []int(nil)},
+		{"TakeSeq 超过长度", slices.Collect(TakeSeq(s, 100)), []int{1, 2, 2, 3, 1, 4, 5}},
+		{"ReduceSeq", ReduceSeq(s, 0, func(a, b int) int { return a + b }), 18},
+		{"ChunkSeq 最后一段不满", slices.Collect(ChunkSeq(s, 3)), [][]int{{1, 2, 2}, {3, 1, 4}, {5}}},
+		{"UniqSeq", slices.Collect(UniqSeq(s)), []int{1, 2, 3, 4, 5}},
+		{"UniqSeq 提前结束", slices.Collect(TakeSeq(UniqSeq(s), 2)), []int{1, 2}},
+		{"ZipSeq", maps.Collect(ZipSeq(slices.Values([]string{"a", "b", "c"}), s)), map[string]int{"a": 1, "b": 2, "c": 2}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: 得到 %v，应该是 %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	// ZipSeq 在较短的序列结束时结束，也能从无限序列中取值
+	pulled = 0
+	n := 0
+	for a, b := range ZipSeq(slices.Values([]string{"x", "y"}), naturals(&pulled)) {
+		if a != []string{"x", "y"}[n] || b != n {
+			t.Fatalf("ZipSeq 第 %d 对是 %s, %d", n, a, b)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("ZipSeq 得到 %d 对", n)
+	}
+}
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		n, workers int
+		want       int // 段数
+	}{
+		{0, 4, 0},
+		{10, 4, 1},                   // 太小，不值得并行
+		{minParallelChunk * 2, 8, 2}, // 每段至少 minParallelChunk 个元素
+		{minParallelChunk*100 + 1, 4, 4},
+	}
+	for _, tt := range tests {
+		segs := segments(tt.n, tt.workers)
+		if len(segs) != tt.want {
+			t.Errorf("segments(%d, %d) 有 %d 段，应该是 %d 段", tt.n, tt.workers, len(segs), tt.want)
+		}
+		// 各段首尾相接，覆盖 [0, n)
+		next := 0
+		for _, seg := range segs {
+			if seg[0] != next || seg[1] <= seg[0] {
+				t.Fatalf("segments(%d, %d) = %v", tt.n, tt.workers, segs)
+			}
+			next = seg[1]
+		}
+		if next != tt.n {
+			t.Fatalf("segments(%d, %d) = %v，没有覆盖所有元素", tt.n, tt.workers, segs)
+		}
+	}
+	if n := len(segments(minParallelChunk*1000, 0)); n != runtime.GOMAXPROCS(0) {
+		t.Errorf("workers 为 0 时分成 %d 段，应该是 GOMAXPROCS", n)
+	}
+}
+
+// Parallel 函数的结果和顺序执行完全一样，包括顺序
+func TestParallel(t *testing.T) {
+	s := make([]int, 100_000+7)
+	for i := range s {
+		s[i] = i
+	}
+	for _, workers := range []int{0, 1, 3, 16} {
+		var calls, maxRunning, running atomic.Int32
+		square := func(n int) int {
+			calls.Add(1)
+			r := running.Add(1)
+			if r > maxRunning.Load() {
+				maxRunning.Store(r)
+			}
+			if n%4096 == 0 {
+				runtime.Gosched() // 让别的协程有机会同时执行
+			}
+			running.Add(-1)
+			return n * n
+		}
+		if got := ParallelMap(s, workers, square); !slices.Equal(got, Map(s, square)) {
+			t.Errorf("workers %d: ParallelMap 的结果不对", workers)
+		}
+		if c := calls.Load(); c != int32(2*len(s)) {
+			t.Errorf("workers %d: f 被调用了 %d 次", workers, c)
+		}
+		if workers > 0 && maxRunning.Load() > int32(workers) {
+			t.Errorf("workers %d: 同时有 %d 个 f 在执行", workers, maxRunning.Load())
+		}
+
+		if got := ParallelFilter(s, workers, isEven); !slices.Equal(got, Filter(s, isEven)) {
+			t.Errorf("workers %d: ParallelFilter 的结果或顺序不对", workers)
+		}
+		// 合并不满足交换律，结果说明各段按顺序合并
+		digits := func(acc string, n int) string {
+			if n%10_000 == 0 {
+				acc += strconv.Itoa(n/10_000) + ","
+			}
+			return acc
+		}
+		concat := func(a, b string) string { return a + b }
+		if got := ParallelReduce(s, workers, "", digits, concat); got != "0,1,2,3,4,5,6,7,8,9,10," {
+			t.Errorf("workers %d: ParallelReduce 得到 %q", workers, got)
+		}
+	}
+	if got := ParallelFilter([]int{}, 4, isEven); got == nil || len(got) != 0 {
+		t.Errorf("空切片 ParallelFilter 得到 %#v", got)
+	}
+	if got := ParallelReduce(nil, 4, 7, func(a, b int) int { return a + b }, func(a, b int) int { return a + b }); got != 7 {
+		t.Errorf("空切片 ParallelReduce 得到 %d", got)
+	}
+}
@@ -0,0 +1,82 @@
+package collections
+
+import (
+	"runtime"
+	"sync"
+)
+
+// 每个 Go 协程至少处理这么多元素，切片太小时启动协程的开销比计算本身还大，直接顺序执行
+const minParallelChunk = 1024
+
+// segments 把 [0, n) 分成最多 workers 段，workers 小于 1 时使用 GOMAXPROCS
+func segments(n, workers int) [][2]int {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = max(min(workers, n/minParallelChunk), 1)
+	size := (n + workers - 1) / workers
+	segs := make([][2]int, 0, workers)
+	for lo := 0; lo < n; lo += size {
+		segs = append(segs, [2]int{lo, min(lo+size, n)})
+	}
+	return segs
+}
+
+// forEachSegment 用一个 Go 协程处理一段，全部处理完后返回
+func forEachSegment(segs [][2]int, fn func(i, lo, hi int)) {
+	if len(segs) == 1 {
+		fn(0, segs[0][0], segs[0][1])
+		return
+	}
+	var wg sync.WaitGroup
+	for i, seg := range segs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(i, seg[0], seg[1])
+		}()
+	}
+	wg.Wait()
+}
+
+// ParallelMap 和 Map 一样，但用最多 workers 个 Go 协程同时执行 f，f 需要可以并发调用。
+func ParallelMap[T, U any](s []T, workers int, f func(T) U) []U {
+	out := make([]U, len(s))
+	forEachSegment(segments(len(s), workers), func(_, lo, hi int) {
+		for i := lo; i < hi; i++ {
+			out[i] = f(s[i])
+		}
+	})
+	return out
+}
+
+// ParallelFilter 和 Filter 一样，结果的顺序也和 s 中一样。
+func ParallelFilter[T any](s []T, workers int, f func(T) bool) []T {
+	segs := segments(len(s), workers)
+	parts := make([][]T, len(segs))
+	forEachSegment(segs, func(i, lo, hi int) {
+		parts[i] = Filter(s[lo:hi], f)
+	})
+
+	out := make([]T, 0)
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+// ParallelReduce 把每一段从 init 开始用 f 合并，再用 combine 按顺序合并各段的结果。
+// init 会被每一段使用，需要是合并的单位元（例如求和时的 0）。
+func ParallelReduce[T, A any](s []T, workers int, init A, f func(acc A, x T) A, combine func(a, b A) A) A {
+	segs := segments(len(s), workers)
+	parts := make([]A, len(segs))
+	forEachSegment(segs, func(i, lo, hi int) {
+		parts[i] = Reduce(s[lo:hi], init, f)
+	})
+
+	acc := init
+	for _, part := range parts {
+		acc = combine(acc, part)
+	}
+	return acc
+}
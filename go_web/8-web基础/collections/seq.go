@@ -0,0 +1,110 @@
+package collections
+
+import "iter"
+
+// 这里的函数不会马上遍历 seq，而是返回一个新的 iter.Seq，在被遍历时才逐个计算，
+// 可以处理很大甚至无限的序列，只用到的部分才会被计算：
+//
+//	evens := FilterSeq(numbers, func(n int) bool { return n%2 == 0 })
+//	first10 := slices.Collect(TakeSeq(evens, 10))
+
+// FilterSeq 只保留满足 f 的元素。
+func FilterSeq[T any](seq iter.Seq[T], f func(T) bool) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for x := range seq {
+			if f(x) && !yield(x) {
+				return
+			}
+		}
+	}
+}
+
+// MapSeq 对每个元素执行 f。
+func MapSeq[T, U any](seq iter.Seq[T], f func(T) U) iter.Seq[U] {
+	return func(yield func(U) bool) {
+		for x := range seq {
+			if !yield(f(x)) {
+				return
+			}
+		}
+	}
+}
+
+// TakeSeq 只取前 n 个元素。
+func TakeSeq[T any](seq iter.Seq[T], n int) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		if n <= 0 {
+			return
+		}
+		i := 0
+		for x := range seq {
+			if !yield(x) {
+				return
+			}
+			if i++; i == n {
+				return
+			}
+		}
+	}
+}
+
+// ReduceSeq 遍历整个序列，从 init 开始用 f 合并每个元素。
+func ReduceSeq[T, A any](seq iter.Seq[T], init A, f func(acc A, x T) A) A {
+	acc := init
+	for x := range seq {
+		acc = f(acc, x)
+	}
+	return acc
+}
+
+// ChunkSeq 每 n 个元素分成一段，每段是新分配的切片。n 小于 1 时 panic。
+func ChunkSeq[T any](seq iter.Seq[T], n int) iter.Seq[[]T] {
+	if n < 1 {
+		panic("collections: ChunkSeq 的 n 必须大于 0")
+	}
+	return func(yield func([]T) bool) {
+		chunk := make([]T, 0, n)
+		for x := range seq {
+			chunk = append(chunk, x)
+			if len(chunk) == n {
+				if !yield(chunk) {
+					return
+				}
+				chunk = make([]T, 0, n)
+			}
+		}
+		if len(chunk) > 0 {
+			yield(chunk)
+		}
+	}
+}
+
+// UniqSeq 去掉重复的元素，需要记住所有出现过的值。
+func UniqSeq[T comparable](seq iter.Seq[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		seen := make(map[T]struct{})
+		for x := range seq {
+			if _, ok := seen[x]; ok {
+				continue
+			}
+			seen[x] = struct{}{}
+			if !yield(x) {
+				return
+			}
+		}
+	}
+}
+
+// ZipSeq 把两个序列对应位置的元素配成一对，任意一个结束时结束。
+func ZipSeq[A, B any](a iter.Seq[A], b iter.Seq[B]) iter.Seq2[A, B] {
+	return func(yield func(A, B) bool) {
+		nextB, stop := iter.Pull(b)
+		defer stop()
+		for x := range a {
+			y, ok := nextB()
+			if !ok || !yield(x, y) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,388 @@
+// config 包为服务器和客户端提供统一的配置，按以下顺序逐层覆盖（后面的优先）：
+//
+//  1. 默认值（Default）
+//  2. 配置文件：-config 标志或 WEB_CONFIG 环境变量指定，支持 .json、.yaml、.yml
+//  3. 环境变量（参考 59-环境变量），例如 WEB_ADDR=:9090
+//  4. 命令行标志（参考 58-命令行标志），例如 -addr :9090
+//
+// 每个字段的文件键、环境变量名和标志名都写在结构体标签里，合并后用 validate 包校验。
+// 加上 -print-config 会打印最终生效的配置然后退出，方便排查配置从哪里来。
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"example.com/web/validate"
+)
+
+// Config 是服务器和客户端共用的配置。
+type Config struct {
+	Server ServerConfig `json:"server" yaml:"server"`
+	Store  StoreConfig  `json:"store" yaml:"store"`
+	Cache  CacheConfig  `json:"cache" yaml:"cache"`
+	Redis  RedisConfig  `json:"redis" yaml:"redis"`
+	Auth   AuthConfig   `json:"auth" yaml:"auth"`
+	Limit  LimitConfig  `json:"rate_limit" yaml:"rate_limit"`
+	Log    LogConfig    `json:"log" yaml:"log"`
+	Client ClientConfig `json:"client" yaml:"client"`
+
+	PrintConfig bool `json:"-" yaml:"-" validate:"-"` // -print-config
+}
+
+type ServerConfig struct {
+	Addr            string   `json:"addr" yaml:"addr" env:"WEB_ADDR" flag:"addr" usage:"服务器监听地址" validate:"required"`
+	ShutdownTimeout Duration `json:"shutdown_timeout" yaml:"shutdown_timeout" env:"WEB_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"退出时等待处理中请求的最长时间" validate:"min=0"`
+}
+
+type StoreConfig struct {
+	Kind     string      `json:"kind" yaml:"kind" env:"WEB_STORE" flag:"store" usage:"用户存储类型: memory、stateful、file 或 mysql" validate:"oneof=memory stateful file mysql"`
+	DataFile string      `json:"data_file" yaml:"data_file" env:"WEB_DATA_FILE" flag:"data" usage:"file 存储使用的数据文件"`
+	MySQL    MySQLConfig `json:"mysql" yaml:"mysql"`
+}
+
+type MySQLConfig struct {
+	DSN             string   `json:"dsn" yaml:"dsn" env:"WEB_MYSQL_DSN" flag:"dsn" usage:"mysql 存储的 DSN，例如 user:password@tcp(127.0.0.1:3306)/test?parseTime=true"`
+	MaxOpenConns    int      `json:"max_open_conns" yaml:"max_open_conns" env:"WEB_MYSQL_MAX_OPEN_CONNS" flag:"mysql-max-open-conns" usage:"最大打开连接数" validate:"min=0"`
+	MaxIdleConns    int      `json:"max_idle_conns" yaml:"max_idle_conns" env:"WEB_MYSQL_MAX_IDLE_CONNS" flag:"mysql-max-idle-conns" usage:"最大空闲连接数" validate:"min=0"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime" yaml:"conn_max_lifetime" env:"WEB_MYSQL_CONN_MAX_LIFETIME" flag:"mysql-conn-max-lifetime" usage:"连接最大存活时间" validate:"min=0"`
+	QueryTimeout    Duration `json:"query_timeout" yaml:"query_timeout" env:"WEB_MYSQL_QUERY_TIMEOUT" flag:"mysql-query-timeout" usage:"每次数据库操作的超时时间" validate:"min=0"`
+}
+
+type CacheConfig struct {
+	Backend string   `json:"backend" yaml:"backend" env:"WEB_CACHE" flag:"cache" usage:"用户缓存: none、memory 或 redis" validate:"oneof=none memory redis"`
+	TTL     Duration `json:"ttl" yaml:"ttl" env:"WEB_CACHE_TTL" flag:"cache-ttl" usage:"缓存有效期" validate:"min=0"`
+	Size    int      `json:"size" yaml:"size" env:"WEB_CACHE_SIZE" flag:"cache-size" usage:"memory 缓存最多保存的条目数" validate:"min=1"`
+}
+
+type RedisConfig struct {
+	Addr     string `json:"addr" yaml:"addr" env:"WEB_REDIS_ADDR" flag:"redis-addr" usage:"Redis 地址，可以用 9-数据库 中的 cmd/redis-server 启动一个"`
+	Password string `json:"password" yaml:"password" env:"WEB_REDIS_PASSWORD" usage:"Redis 密码"`
+	DB       int    `json:"db" yaml:"db" env:"WEB_REDIS_DB" flag:"redis-db" usage:"Redis 数据库编号" validate:"min=0"`
+}
+
+type AuthConfig struct {
+	Enabled    bool     `json:"enabled" yaml:"enabled" env:"WEB_AUTH" flag:"auth" usage:"写请求是否需要认证"`
+	Secret     string   `json:"secret" yaml:"secret" env:"WEB_JWT_SECRET" usage:"JWT 签名密钥，至少 32 字节，为空时每次启动随机生成"`
+	AccessTTL  Duration `json:"access_ttl" yaml:"access_ttl" env:"WEB_ACCESS_TTL" flag:"access-ttl" usage:"访问令牌有效期" validate:"min=1"`
+	RefreshTTL Duration `json:"refresh_ttl" yaml:"refresh_ttl" env:"WEB_REFRESH_TTL" flag:"refresh-ttl" usage:"刷新令牌有效期" validate:"min=1"`
+	Accounts   string   `json:"accounts" yaml:"accounts" env:"WEB_AUTH_ACCOUNTS" usage:"可以登录的账号，格式为 用户名:密码[:用户ID],...，密码可以换成 cmd/hash-password 生成的 bcrypt 哈希"`
+	APIKeys    string   `json:"api_keys" yaml:"api_keys" env:"WEB_API_KEYS" usage:"服务之间调用使用的 API key，格式为 名字:key[:角色],...，角色默认为 editor"`
+}
+
+type LimitConfig struct {
+	Algorithm  string   `json:"algorithm" yaml:"algorithm" env:"WEB_RATE_LIMIT" flag:"rate-limit" usage:"限流算法: none、token_bucket、sliding_log 或 fixed_window" validate:"oneof=none token_bucket sliding_log fixed_window"`
+	Requests   int      `json:"requests" yaml:"requests" env:"WEB_RATE_LIMIT_REQUESTS" flag:"rate-limit-requests" usage:"每个客户端在一个窗口内允许的请求数" validate:"min=1"`
+	Window     Duration `json:"window" yaml:"window" env:"WEB_RATE_LIMIT_WINDOW" flag:"rate-limit-window" usage:"限流窗口" validate:"min=1"`
+	Burst      int      `json:"burst" yaml:"burst" env:"WEB_RATE_LIMIT_BURST" usage:"令牌桶允许的突发请求数，0 表示和 requests 相同" validate:"min=0"`
+	Store      string   `json:"store" yaml:"store" env:"WEB_RATE_LIMIT_STORE" flag:"rate-limit-store" usage:"限流状态保存在 memory 或 redis，redis 时多个服务器进程共用限额" validate:"oneof=memory redis"`
+	TrustProxy bool     `json:"trust_proxy" yaml:"trust_proxy" env:"WEB_TRUST_PROXY" flag:"trust-proxy" usage:"服务器在一层反向代理后面，按 X-Forwarded-For 识别客户端 IP"`
+}
+
+type LogConfig struct {
+	Level  string `json:"level" yaml:"level" env:"WEB_LOG_LEVEL" flag:"log-level" usage:"日志级别: debug、info、warn 或 error" validate:"oneof=debug info warn error"`
+	Format string `json:"format" yaml:"format" env:"WEB_LOG_FORMAT" flag:"log-format" usage:"日志格式: json 或 text" validate:"oneof=json text"`
+}
+
+type ClientConfig struct {
+	BaseURL  string   `json:"base_url" yaml:"base_url" env:"WEB_SERVER_URL" flag:"server" usage:"客户端访问的服务器地址" validate:"required"`
+	Timeout  Duration `json:"timeout" yaml:"timeout" env:"WEB_CLIENT_TIMEOUT" flag:"timeout" usage:"客户端请求超时时间" validate:"min=0"`
+	Username string   `json:"username" yaml:"username" env:"WEB_USERNAME" flag:"user" usage:"客户端登录使用的用户名"`
+	Password string   `json:"password" yaml:"password" env:"WEB_PASSWORD" usage:"客户端登录使用的密码"`
+	APIKey   string   `json:"api_key" yaml:"api_key" env:"WEB_API_KEY" usage:"设置后客户端用 API key 认证，不再登录"`
+
+	// 失败处理，见 client.Transport
+	Retries         int      `json:"retries" yaml:"retries" env:"WEB_CLIENT_RETRIES" flag:"retries" usage:"幂等请求失败后最多重试几次" validate:"min=0,max=10"`
+	Hedge           Duration `json:"hedge" yaml:"hedge" env:"WEB_CLIENT_HEDGE" flag:"hedge" usage:"GET 请求超过这个时间没有响应时再发一个，0 表示不发" validate:"min=0"`
+	BreakerFailures int      `json:"breaker_failures" yaml:"breaker_failures" env:"WEB_CLIENT_BREAKER_FAILURES" usage:"连续失败多少次后熔断，0 表示不熔断" validate:"min=0"`
+	BreakerCooldown Duration `json:"breaker_cooldown" yaml:"breaker_cooldown" env:"WEB_CLIENT_BREAKER_COOLDOWN" usage:"熔断后多久再试探服务器" validate:"min=0"`
+}
+
+// Default 返回默认配置，和最早写死在代码里的值一致。账号和密码没有默认值，开启认证时需要用 WEB_AUTH_ACCOUNTS 配置。
+func Default() Config {
+	return Config{
+		Server: ServerConfig{Addr: ":8080", ShutdownTimeout: Duration(10 * time.Second)},
+		Store: StoreConfig{Kind: "memory", DataFile: "users.json", MySQL: MySQLConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration(5 * time.Minute),
+			QueryTimeout:    Duration(3 * time.Second),
+		}},
+		Cache: CacheConfig{Backend: "none", TTL: Duration(30 * time.Second), Size: 1000},
+		Redis: RedisConfig{Addr: "127.0.0.1:6379"},
+		Auth: AuthConfig{
+			Enabled:    true,
+			AccessTTL:  Duration(15 * time.Minute),
+			RefreshTTL: Duration(7 * 24 * time.Hour),
+		},
+		Limit: LimitConfig{Algorithm: "none", Requests: 100, Window: Duration(time.Minute), Store: "memory"},
+		Log:   LogConfig{Level: "info", Format: "json"},
+		Client: ClientConfig{
+			BaseURL:  "http://localhost:8080",
+			Timeout:  Duration(10 * time.Second),
+			Username: "admin",
+
+			Retries:         3,
+			BreakerFailures: 5,
+			BreakerCooldown: Duration(10 * time.Second),
+		},
+	}
+}
+
+// Validate 检查配置是否合法，validate 标签之外还检查了字段之间的关系。
+func (c *Config) Validate() error {
+	var errs validate.Errors
+	if err := validate.Struct(c); err != nil {
+		errs = err.(validate.Errors)
+	}
+	if c.Store.Kind == "file" && c.Store.DataFile == "" {
+		errs = append(errs, validate.NewFieldError("store.data_file", "required", ""))
+	}
+	if c.Store.Kind == "mysql" && c.Store.MySQL.DSN == "" {
+		errs = append(errs, validate.NewFieldError("store.mysql.dsn", "required", ""))
+	}
+	// 没有内置账号：开启认证时必须配置账号，不能因为忘了配置就用一个公开的默认密码对外服务
+	if c.Auth.Enabled && c.Auth.Accounts == "" {
+		errs = append(errs, validate.NewFieldError("auth.accounts", "required", ""))
+	}
+	if c.Auth.Secret != "" && len(c.Auth.Secret) < 32 {
+		errs = append(errs, validate.NewLenError("auth.secret", "min", "32"))
+	}
+	if (c.Cache.Backend == "redis" || c.Limit.Algorithm != "none" && c.Limit.Store == "redis") && c.Redis.Addr == "" {
+		errs = append(errs, validate.NewFieldError("redis.addr", "required", ""))
+	}
+	if c.Client.BaseURL != "" {
+		u, err := url.Parse(c.Client.BaseURL)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, validate.NewFieldError("client.base_url", "url", ""))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("配置不合法: %w", errs)
+	}
+	return nil
+}
+
+// Load 从默认值、配置文件、环境变量和 args 中的命令行标志加载配置。name 是程序名，用于帮助信息。
+func Load(name string, args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("WEB_CONFIG"), "配置文件 (.json/.yaml/.yml)，也可以用 WEB_CONFIG 环境变量指定")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "打印最终生效的配置后退出")
+	// 先把标志解析到一份独立的配置里，等文件和环境变量处理完再覆盖上去
+	flagged := Default()
+	for _, f := range collect(reflect.ValueOf(&flagged).Elem()) {
+		if f.flag != "" {
+			fs.Var(f.value, f.flag, f.usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	byFlag := make(map[string]field)
+	for _, f := range collect(reflect.ValueOf(&cfg).Elem()) {
+		byFlag[f.flag] = f
+		if v, ok := os.LookupEnv(f.env); ok && f.env != "" {
+			if err := f.value.Set(v); err != nil {
+				return nil, fmt.Errorf("环境变量 %s=%q: %w", f.env, v, err)
+			}
+		}
+	}
+
+	// 只有明确给出的标志才覆盖，未给出的标志保留文件和环境变量中的值
+	fs.Visit(func(fl *flag.Flag) {
+		if f, ok := byFlag[fl.Name]; ok {
+			f.value.Set(fl.Value.String())
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// MustLoad 和 Load 一样，但出错时打印错误并以状态码 2 退出；指定了 -print-config 时打印配置并以 0 退出。
+func MustLoad(name string, args []string) *Config {
+	cfg, err := Load(name, args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(2)
+	}
+	if cfg.PrintConfig {
+		cfg.Print(os.Stdout)
+		os.Exit(0)
+	}
+	return cfg
+}
+
+// Print 以 JSON 格式输出配置，密码、密钥等敏感信息显示为 ***。
+func (c *Config) Print(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.masked())
+}
+
+// redacted 替换敏感信息，没有设置的字段保持为空，这样仍然能看出有没有配置
+const redacted = "***"
+
+// masked 返回隐去敏感信息的副本。账号和 API key 只隐去密码和 key 本身，保留名字和其余部分。
+func (c *Config) masked() *Config {
+	r := *c
+	mask := func(s *string) {
+		if *s != "" {
+			*s = redacted
+		}
+	}
+	mask(&r.Auth.Secret)
+	mask(&r.Redis.Password)
+	mask(&r.Client.Password)
+	mask(&r.Client.APIKey)
+	r.Auth.Accounts = maskListField(r.Auth.Accounts)
+	r.Auth.APIKeys = maskListField(r.Auth.APIKeys)
+	r.Store.MySQL.DSN = maskDSN(r.Store.MySQL.DSN)
+	return &r
+}
+
+// maskListField 隐去 名字:秘密[:其他],... 格式中每一项的第二个字段
+func maskListField(list string) string {
+	if list == "" {
+		return ""
+	}
+	items := strings.Split(list, ",")
+	for i, item := range items {
+		parts := strings.Split(item, ":")
+		if len(parts) > 1 {
+			parts[1] = redacted
+		}
+		items[i] = strings.Join(parts, ":")
+	}
+	return strings.Join(items, ",")
+}
+
+// maskDSN 隐去 user:password@tcp(host)/db 中的密码
+func maskDSN(dsn string) string {
+	at := strings.LastIndex(dsn, "@")
+	if at < 0 {
+		return dsn
+	}
+	if colon := strings.Index(dsn[:at], ":"); colon >= 0 {
+		return dsn[:colon+1] + redacted + dsn[at:]
+	}
+	return dsn
+}
+
+// loadFile 按扩展名解析配置文件，文件中没有出现的字段保留原来的值。
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件: %w", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		dec := json.NewDecoder(strings.NewReader(string(data)))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(strings.NewReader(string(data)))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+		if errors.Is(err, io.EOF) {
+			err = nil // 空文件
+		}
+	default:
+		return fmt.Errorf("不支持的配置文件格式 %q，请使用 .json、.yaml 或 .yml", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("解析配置文件 %s: %w", path, err)
+	}
+	return nil
+}
+
+// field 是一个可以从字符串设置的配置字段
+type field struct {
+	env, flag, usage string
+	value            flag.Value
+}
+
+// collect 递归收集结构体中带 env 或 flag 标签的字段
+func collect(v reflect.Value) []field {
+	var fields []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf, fv := t.Field(i), v.Field(i)
+		if fv.Kind() == reflect.Struct && sf.Type != reflect.TypeOf(Duration(0)) {
+			fields = append(fields, collect(fv)...)
+			continue
+		}
+		env, fl := sf.Tag.Get("env"), sf.Tag.Get("flag")
+		if env == "" && fl == "" {
+			continue
+		}
+		fields = append(fields, field{env: env, flag: fl, usage: sf.Tag.Get("usage"), value: valueOf(fv)})
+	}
+	return fields
+}
+
+// valueOf 把结构体字段包装成 flag.Value
+func valueOf(v reflect.Value) flag.Value {
+	switch p := v.Addr().Interface().(type) {
+	case *Duration:
+		return p
+	case *string:
+		return (*stringValue)(p)
+	case *int:
+		return (*intValue)(p)
+	case *bool:
+		return (*boolValue)(p)
+	default:
+		panic(fmt.Sprintf("config: 不支持的字段类型 %s", v.Type()))
+	}
+}
+
+type stringValue string
+
+func (s *stringValue) String() string     { return string(*s) }
+func (s *stringValue) Set(v string) error { *s = stringValue(v); return nil }
+
+type intValue int
+
+func (n *intValue) String() string { return strconv.Itoa(int(*n)) }
+func (n *intValue) Set(v string) error {
+	i, err := strconv.Atoi(v)
+	*n = intValue(i)
+	return err
+}
+
+type boolValue bool
+
+func (b *boolValue) String() string   { return strconv.FormatBool(bool(*b)) }
+func (b *boolValue) IsBoolFlag() bool { return true }
+func (b *boolValue) Set(v string) error {
+	x, err := strconv.ParseBool(v)
+	*b = boolValue(x)
+	return err
+}
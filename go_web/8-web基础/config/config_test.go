@@ -0,0 +1,187 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const accounts = "admin:admin123:1"
+
+// writeFile 在临时目录中写一个配置文件，返回它的路径
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// 默认值 < 配置文件 < 环境变量 < 命令行标志
+func TestLoadLayers(t *testing.T) {
+	files := map[string]string{
+		"config.json": `{
+			"server": {"addr": ":7000", "shutdown_timeout": "30s"},
+			"log": {"level": "debug", "format": "text"},
+			"cache": {"size": 50, "ttl": "1m"},
+			"auth": {"accounts": "` + accounts + `"}
+		}`,
+		"config.yaml": `
+server:
+  addr: ":7000"
+  shutdown_timeout: 30s
+log:
+  level: debug
+  format: text
+cache:
+  size: 50
+  ttl: 1m
+auth:
+  accounts: "` + accounts + `"
+`,
+	}
+	for name, content := range files {
+		path := writeFile(t, name, content)
+		t.Setenv("WEB_ADDR", ":8000")
+		t.Setenv("WEB_CACHE_SIZE", "60")
+		t.Setenv("WEB_LOG_FORMAT", "json")
+
+		cfg, err := Load("test", []string{"-config", path, "-addr", ":9000", "-cache-ttl", "2m"})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		tests := []struct {
+			what      string
+			got, want any
+		}{
+			{"标志覆盖环境变量", cfg.Server.Addr, ":9000"},
+			{"标志覆盖文件", cfg.Cache.TTL.D(), 2 * time.Minute},
+			{"环境变量覆盖文件", cfg.Cache.Size, 60},
+			// 环境变量的值和默认值相同时也要覆盖文件
+			{"环境变量覆盖文件中的非默认值", cfg.Log.Format, "json"},
+			{"文件覆盖默认值", cfg.Log.Level, "debug"},
+			{"文件中的时间", cfg.Server.ShutdownTimeout.D(), 30 * time.Second},
+			{"文件中没有的字段保留默认值", cfg.Store.Kind, "memory"},
+			{"默认值", cfg.Limit.Window.D(), time.Minute},
+		}
+		for _, tt := range tests {
+			if tt.got != tt.want {
+				t.Errorf("%s: %s: 得到 %v，应该是 %v", name, tt.what, tt.got, tt.want)
+			}
+		}
+	}
+}
+
+// 没有给出的标志不会用默认值覆盖文件和环境变量，明确给出的标志即使等于默认值也会覆盖
+func TestLoadUnsetFlags(t *testing.T) {
+	t.Setenv("WEB_AUTH_ACCOUNTS", accounts)
+	t.Setenv("WEB_LOG_LEVEL", "warn")
+	t.Setenv("WEB_STORE", "stateful")
+	cfg, err := Load("test", []string{"-store", "memory", "-auth=false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Log.Level != "warn" || cfg.Store.Kind != "memory" || cfg.Auth.Enabled {
+		t.Fatalf("日志级别 %s，存储 %s，认证 %v", cfg.Log.Level, cfg.Store.Kind, cfg.Auth.Enabled)
+	}
+
+	// 配置文件也可以用 WEB_CONFIG 指定
+	t.Setenv("WEB_CONFIG", writeFile(t, "c.yml", "log:\n  format: text\n"))
+	if cfg, err = Load("test", nil); err != nil || cfg.Log.Format != "text" {
+		t.Fatalf("WEB_CONFIG 指定的文件没有生效: %v, %v", cfg, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		file string // 文件名和内容用 | 分隔
+		args []string
+		want string // 错误信息中应该有的内容
+	}{
+		// 没有内置账号，开启认证时必须配置
+		{"没有账号", nil, "", nil, "auth.accounts"},
+		{"关闭认证时不需要账号", nil, "", []string{"-auth=false"}, ""},
+		{"未知的标志", nil, "", []string{"-nope"}, "nope"},
+		{"标志的值不对", nil, "", []string{"-auth=false", "-retries", "x"}, "retries"},
+		{"环境变量的值不对", map[string]string{"WEB_CACHE_TTL": "soon"}, "", []string{"-auth=false"}, "WEB_CACHE_TTL"},
+		{"文件中的未知字段", nil, "c.json|{\"server\": {\"port\": 80}}", []string{"-auth=false"}, "port"},
+		{"YAML 中的未知字段", nil, "c.yaml|serve:\n  addr: x\n", []string{"-auth=false"}, "serve"},
+		{"不支持的格式", nil, "c.toml|addr = 1", []string{"-auth=false"}, ".toml"},
+		{"校验失败", nil, "", []string{"-auth=false", "-store", "file", "-data", "", "-retries", "11", "-server", "ftp://x"},
+			"store.data_file"},
+		{"密钥太短", map[string]string{"WEB_JWT_SECRET": "short", "WEB_AUTH_ACCOUNTS": accounts}, "", nil, "auth.secret"},
+		{"使用 Redis 时需要地址", map[string]string{"WEB_REDIS_ADDR": ""}, "", []string{"-auth=false", "-cache", "redis"}, "redis.addr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				name, content, _ := strings.Cut(tt.file, "|")
+				args = append([]string{"-config", writeFile(t, name, content)}, args...)
+			}
+			_, err := Load("test", args)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Fatalf("应该成功，得到 %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Fatalf("错误应该提到 %s，得到 %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestPrintMasksSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Auth.Secret = strings.Repeat("s", 32)
+	cfg.Auth.Accounts = "admin:admin123:1,bob:$2a$10$abcdefghijklmnopqrstuv"
+	cfg.Auth.APIKeys = "ci:ci-key-0123456789:viewer"
+	cfg.Redis.Password = "redis-pass"
+	cfg.Client.Password = "client-pass"
+	cfg.Store.MySQL.DSN = "root:p@ss:word@tcp(127.0.0.1:3306)/test?parseTime=true"
+	orig := cfg
+
+	var buf bytes.Buffer
+	if err := cfg.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, secret := range []string{"ssss", "admin123", "$2a$", "ci-key", "redis-pass", "client-pass", "p@ss"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("输出中有 %q:\n%s", secret, out)
+		}
+	}
+
+	var printed Config
+	if err := json.Unmarshal(buf.Bytes(), &printed); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ got, want string }{
+		{printed.Auth.Secret, "***"},
+		{printed.Auth.Accounts, "admin:***:1,bob:***"},
+		{printed.Auth.APIKeys, "ci:***:viewer"},
+		{printed.Redis.Password, "***"},
+		{printed.Client.Password, "***"},
+		// 没有配置的字段保持为空，能看出有没有配置
+		{printed.Client.APIKey, ""},
+		{printed.Store.MySQL.DSN, "root:***@tcp(127.0.0.1:3306)/test?parseTime=true"},
+		{printed.Redis.Addr, cfg.Redis.Addr},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("输出 %q，应该是 %q", tt.got, tt.want)
+		}
+	}
+	if cfg != orig {
+		t.Fatal("Print 修改了配置")
+	}
+}
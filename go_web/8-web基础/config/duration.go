@@ -0,0 +1,41 @@
+package config
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Duration 是可以在配置文件中写成 "10s"、"1m30s" 的 time.Duration。
+type Duration time.Duration
+
+// D 返回 time.Duration 类型的值。
+func (d Duration) D() time.Duration { return time.Duration(d) }
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) { return []byte(d.String()), nil }
+
+func (d *Duration) UnmarshalText(b []byte) error { return d.Set(string(b)) }
+
+// UnmarshalJSON 同时接受字符串 "10s" 和表示纳秒的数字
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var n int64
+	if json.Unmarshal(b, &n) == nil {
+		*d = Duration(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return d.Set(s)
+}
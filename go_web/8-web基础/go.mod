@@ -0,0 +1,17 @@
+module example.com/web
+
+go 1.25.0
+
+require (
+	example.com/database v0.0.0
+	golang.org/x/crypto v0.54.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/go-sql-driver/mysql v1.9.3 // indirect
+)
+
+// 和 example.com/database 互相依赖，原因见 ../9-数据库/go.mod
+replace example.com/database => ../9-数据库
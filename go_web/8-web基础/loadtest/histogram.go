@@ -0,0 +1,166 @@
+package loadtest
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// Histogram 是 HDR (High Dynamic Range) 直方图，记录从 1µs 到几小时的延迟，相对误差不超过 0.1%。
+//
+// 小于 2048µs 的值每个微秒一个桶；更大的值按 2 的幂分段，每段再平均分成 1024 个桶，
+// 所以桶的宽度和值本身成比例，用固定的内存就能同时精确记录很小和很大的值，
+// 不像按固定间隔分桶那样要么浪费内存、要么丢失精度。原理见 http://hdrhistogram.org。
+//
+// Histogram 不能被多个 Go 协程同时使用。
+type Histogram struct {
+	counts []int64 // 按需增长
+	total  int64
+	sum    int64 // 所有值的和，单位微秒
+	min    int64
+	max    int64
+}
+
+const (
+	subBucketBits  = 11 // 每段 2048 个桶，前一半和上一段重叠，所以实际是 1024 个
+	subBucketCount = 1 << subBucketBits
+	subBucketHalf  = subBucketCount / 2
+)
+
+// NewHistogram 创建空的直方图
+func NewHistogram() *Histogram {
+	return &Histogram{min: math.MaxInt64}
+}
+
+// bucketIndex 返回值 v（微秒）所在的桶
+func bucketIndex(v int64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits // v>>shift 在 [1024, 2047] 中
+	return subBucketCount + (shift-1)*subBucketHalf + int(v>>shift) - subBucketHalf
+}
+
+// bucketRange 返回桶 i 中最小和最大的值
+func bucketRange(i int) (lo, hi int64) {
+	if i < subBucketCount {
+		return int64(i), int64(i)
+	}
+	shift := (i-subBucketCount)/subBucketHalf + 1
+	sub := int64((i-subBucketCount)%subBucketHalf + subBucketHalf)
+	return sub << shift, (sub+1)<<shift - 1
+}
+
+// Record 记录一个值，小于 1µs 的按 0 记录
+func (h *Histogram) Record(d time.Duration) {
+	v := max(d.Microseconds(), 0)
+	i := bucketIndex(v)
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]int64, i+1-len(h.counts))...)
+	}
+	h.counts[i]++
+	h.total++
+	h.sum += v
+	h.min = min(h.min, v)
+	h.max = max(h.max, v)
+}
+
+// Merge 把 other 中的值加进来
+func (h *Histogram) Merge(other *Histogram) {
+	if len(other.counts) > len(h.counts) {
+		h.counts = append(h.counts, make([]int64, len(other.counts)-len(h.counts))...)
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	h.total += other.total
+	h.sum += other.sum
+	h.min = min(h.min, other.min)
+	h.max = max(h.max, other.max)
+}
+
+// Count 返回记录的值的个数
+func (h *Histogram) Count() int64 { return h.total }
+
+// Min 返回最小值，没有记录时返回 0
+func (h *Histogram) Min() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return micros(h.min)
+}
+
+// Max 返回最大值
+func (h *Histogram) Max() time.Duration { return micros(h.max) }
+
+// Mean 返回平均值
+func (h *Histogram) Mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return micros(h.sum / h.total)
+}
+
+// Quantile 返回分位数，q 在 0 到 1 之间，例如 0.99 是 p99：至少 99% 的值不大于它。
+// 返回值是所在桶的上界（不超过最大值），和 HdrHistogram 的约定一样。
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	target := max(int64(math.Ceil(q*float64(h.total))), 1)
+	var seen int64
+	for i, c := range h.counts {
+		if seen += c; seen >= target {
+			_, hi := bucketRange(i)
+			return micros(min(hi, h.max))
+		}
+	}
+	return micros(h.max)
+}
+
+// Bracket 是延迟分布中的一行：Percentile% 的请求不超过 Value
+type Bracket struct {
+	Percentile float64       `json:"percentile"`
+	Value      time.Duration `json:"-"`
+	ValueMS    float64       `json:"value_ms"`
+	Count      int64         `json:"count"` // 不超过 Value 的请求数
+}
+
+// Distribution 返回 HdrHistogram 风格的延迟分布：分位数依次是 0、50、75、87.5、93.75……
+// 每次把离 100% 的距离减半，尾部越来越细，直到超出记录的值的个数，最后是 100%。
+func (h *Histogram) Distribution() []Bracket {
+	if h.total == 0 {
+		return nil
+	}
+	var out []Bracket
+	add := func(p float64) {
+		v := h.Quantile(p / 100)
+		out = append(out, Bracket{Percentile: p, Value: v, ValueMS: ms(v), Count: h.countUpTo(v)})
+	}
+	for k := 0; ; k++ {
+		rest := math.Pow(0.5, float64(k))
+		if 1/rest > float64(h.total) || k > 20 {
+			break
+		}
+		add(100 * (1 - rest))
+	}
+	add(100)
+	return out
+}
+
+// countUpTo 返回不超过 d 的值的个数（按桶计算）
+func (h *Histogram) countUpTo(d time.Duration) int64 {
+	limit := bucketIndex(d.Microseconds())
+	var n int64
+	for i := 0; i <= limit && i < len(h.counts); i++ {
+		n += h.counts[i]
+	}
+	return n
+}
+
+func micros(v int64) time.Duration { return time.Duration(v) * time.Microsecond }
+
+// ms 把时间转换成毫秒，用于 JSON 输出
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,145 @@
+package loadtest
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+// 桶连续地覆盖所有值，每个桶的宽度不超过其中最小值的 1/1024
+func TestBuckets(t *testing.T) {
+	var prevHi int64 = -1
+	for i := range bucketIndex(1<<40) + 1 {
+		lo, hi := bucketRange(i)
+		if lo != prevHi+1 || hi < lo {
+			t.Fatalf("桶 %d 是 [%d, %d]，上一个桶结束于 %d", i, lo, hi, prevHi)
+		}
+		if lo >= subBucketCount && float64(hi-lo+1)/float64(lo) > 1.0/1024 {
+			t.Fatalf("桶 %d [%d, %d] 太宽", i, lo, hi)
+		}
+		if bucketIndex(lo) != i || bucketIndex(hi) != i {
+			t.Fatalf("桶 %d 是 [%d, %d]，bucketIndex 返回 %d 和 %d", i, lo, hi, bucketIndex(lo), bucketIndex(hi))
+		}
+		prevHi = hi
+	}
+
+	tests := []struct {
+		v      int64
+		lo, hi int64
+	}{
+		{0, 0, 0},
+		{2047, 2047, 2047},
+		{2048, 2048, 2049}, // 第一段每个桶 2µs
+		{4095, 4094, 4095},
+		{4096, 4096, 4099},
+		{1_000_000, 999_936, 1_000_447}, // 1s 附近每个桶 512µs
+	}
+	for _, tt := range tests {
+		if lo, hi := bucketRange(bucketIndex(tt.v)); lo != tt.lo || hi != tt.hi {
+			t.Errorf("%dµs 在桶 [%d, %d] 中，应该是 [%d, %d]", tt.v, lo, hi, tt.lo, tt.hi)
+		}
+	}
+}
+
+// exact 返回排好序的 vs 的分位数，定义和 Quantile 一样：至少 q 的值不大于它
+func exact(sorted []int64, q float64) int64 {
+	i := max(int(math.Ceil(q*float64(len(sorted)))), 1) - 1
+	return sorted[i]
+}
+
+func TestQuantile(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	dists := map[string]func() int64{
+		"均匀 1µs-10ms": func() int64 { return 1 + r.Int64N(10_000) },
+		"指数 平均 50ms":  func() int64 { return int64(r.ExpFloat64() * 50_000) },
+		// 大部分请求很快，少数很慢
+		"长尾": func() int64 {
+			if r.IntN(100) == 0 {
+				return 1_000_000 + r.Int64N(9_000_000)
+			}
+			return 500 + r.Int64N(1000)
+		},
+	}
+	for name, next := range dists {
+		h := NewHistogram()
+		vs := make([]int64, 100_000)
+		var sum int64
+		for i := range vs {
+			vs[i] = next()
+			sum += vs[i]
+			h.Record(micros(vs[i]))
+		}
+		slices.Sort(vs)
+
+		if h.Count() != int64(len(vs)) || h.Min() != micros(vs[0]) || h.Max() != micros(vs[len(vs)-1]) || h.Mean() != micros(sum/int64(len(vs))) {
+			t.Errorf("%s: Count %d，Min %v，Max %v，Mean %v", name, h.Count(), h.Min(), h.Max(), h.Mean())
+		}
+		for _, q := range []float64{0, 0.001, 0.5, 0.9, 0.99, 0.999, 0.9999, 1} {
+			want := exact(vs, q)
+			got := h.Quantile(q).Microseconds()
+			// 返回所在桶的上界：不小于真实值，相对误差不超过 0.1%
+			if got < want || float64(got-want) > float64(want)/1024 {
+				t.Errorf("%s: p%v 是 %dµs，实际是 %dµs", name, q*100, got, want)
+			}
+		}
+	}
+}
+
+func TestHistogramEdges(t *testing.T) {
+	h := NewHistogram()
+	if h.Count() != 0 || h.Min() != 0 || h.Max() != 0 || h.Mean() != 0 || h.Quantile(0.5) != 0 || h.Distribution() != nil {
+		t.Fatal("空的直方图应该全部返回 0")
+	}
+
+	h.Record(-time.Second)          // 按 0 记录
+	h.Record(500 * time.Nanosecond) // 不足 1µs
+	h.Record(3 * time.Hour)
+	if h.Min() != 0 || h.Max() != 3*time.Hour || h.Count() != 3 {
+		t.Fatalf("Min %v，Max %v，Count %d", h.Min(), h.Max(), h.Count())
+	}
+	// 上界不超过最大值
+	if q := h.Quantile(1); q != 3*time.Hour {
+		t.Fatalf("p100 是 %v", q)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b, all := NewHistogram(), NewHistogram(), NewHistogram()
+	for i := range 1000 {
+		d := time.Duration(i*i) * time.Microsecond
+		all.Record(d)
+		if i%3 == 0 {
+			a.Record(d)
+		} else {
+			b.Record(d)
+		}
+	}
+	a.Merge(b)
+	a.Merge(NewHistogram()) // 合并空的直方图不影响最小值
+	if !slices.Equal(a.counts, all.counts) || a.Count() != all.Count() || a.Min() != all.Min() || a.Max() != all.Max() || a.Mean() != all.Mean() {
+		t.Fatalf("合并之后是 %d 个值 [%v, %v]，应该是 %d 个值 [%v, %v]", a.Count(), a.Min(), a.Max(), all.Count(), all.Min(), all.Max())
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 10; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	var got []float64
+	for _, b := range h.Distribution() {
+		got = append(got, b.Percentile)
+		if b.ValueMS != ms(b.Value) || b.Count != h.countUpTo(b.Value) {
+			t.Errorf("%v%%: %+v", b.Percentile, b)
+		}
+	}
+	// 10 个值只能细分到 87.5%
+	if want := []float64{0, 50, 75, 87.5, 100}; !slices.Equal(got, want) {
+		t.Fatalf("分位数是 %v，应该是 %v", got, want)
+	}
+	if last := h.Distribution()[len(got)-1]; last.Value != 10*time.Millisecond || last.Count != 10 {
+		t.Fatalf("100%% 是 %+v", last)
+	}
+}
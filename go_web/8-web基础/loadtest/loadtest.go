@@ -0,0 +1,171 @@
+// loadtest 包按设定的请求组合对服务器施加压力，统计延迟分布、吞吐量和错误，cmd/loadgen 是它的命令行工具。
+//
+// 请求由 pool 包的工作池执行，有两种模式：
+//
+//   - 固定并发 (Rate 为 0)：Concurrency 个 worker 一个请求完成后马上发下一个，测出服务器最多能处理多少请求
+//   - 固定速率 (Rate 大于 0)：按 Rate 个/秒的节奏安排请求，Concurrency 是同时进行的请求的上限
+//
+// 固定速率时延迟从请求"本应发出"的时间算起：服务器变慢、worker 都在忙时，后面的请求要排队，
+// 排队的时间也算进延迟，否则慢的时候发出的请求少，统计结果会比实际好很多 (coordinated omission)。
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"time"
+
+	"example.com/web/pool"
+)
+
+// Op 是一种请求
+type Op struct {
+	Name   string
+	Weight int                             // 在请求组合中的比重，小于 1 时为 1
+	Do     func(ctx context.Context) error // 发送一个请求，返回错误表示失败
+}
+
+// Options 是一次压测的设置，Duration 和 Requests 至少设置一个
+type Options struct {
+	Ops         []Op
+	Rate        float64       // 每秒发出的请求数，0 表示固定并发模式
+	Concurrency int           // worker 数，小于 1 时为 1
+	Duration    time.Duration // 持续时间，0 表示不限
+	Requests    int           // 总请求数，0 表示不限
+	Timeout     time.Duration // 单个请求的超时时间，0 表示不限
+
+	// Classify 把错误归类，用于错误统计，为 nil 时使用 ClassifyError
+	Classify func(error) string
+}
+
+// task 是提交给工作池的任务
+type task struct {
+	op        int
+	scheduled time.Time // 固定速率时请求本应发出的时间，固定并发时为零值
+}
+
+// Run 执行压测，ctx 取消时停止发出新请求，已经发出的请求完成后返回统计结果。
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if len(opts.Ops) == 0 {
+		return nil, errors.New("loadtest: 没有请求")
+	}
+	if opts.Duration <= 0 && opts.Requests <= 0 {
+		return nil, errors.New("loadtest: Duration 和 Requests 至少设置一个")
+	}
+	if opts.Rate < 0 {
+		return nil, fmt.Errorf("loadtest: Rate %v 不能小于 0", opts.Rate)
+	}
+	classify := opts.Classify
+	if classify == nil {
+		classify = ClassifyError
+	}
+	choose := weighted(opts.Ops)
+	workers := max(opts.Concurrency, 1)
+
+	// 请求本身不使用 ctx，Ctrl+C 只停止发出新请求，不打断已经发出的请求
+	p := pool.New(context.WithoutCancel(ctx), func(_ context.Context, t task) (time.Duration, error) {
+		// 固定并发时从 worker 开始处理算起，提交时等待空闲 worker 的时间不是服务器造成的
+		begin := t.scheduled
+		if begin.IsZero() {
+			begin = time.Now()
+		}
+		reqCtx := context.Background()
+		if opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			reqCtx, cancel = context.WithTimeout(reqCtx, opts.Timeout)
+			defer cancel()
+		}
+		err := opts.Ops[t.op].Do(reqCtx)
+		return time.Since(begin), err
+	}, pool.Options{Workers: workers}) // 队列长度为 0：worker 都在忙时提交会阻塞
+
+	start := time.Now()
+	go func() {
+		defer p.Close()
+		var deadline <-chan time.Time
+		if opts.Duration > 0 {
+			timer := time.NewTimer(opts.Duration)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+		for i := 0; opts.Requests <= 0 || i < opts.Requests; i++ {
+			var scheduled time.Time
+			if opts.Rate > 0 {
+				scheduled = start.Add(time.Duration(float64(i) / opts.Rate * float64(time.Second)))
+				if wait := time.Until(scheduled); wait > 0 {
+					timer := time.NewTimer(wait)
+					select {
+					case <-timer.C:
+					case <-ctx.Done():
+						timer.Stop()
+						return
+					case <-deadline:
+						timer.Stop()
+						return
+					}
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-deadline:
+				return
+			default:
+			}
+			if p.Submit(ctx, task{op: choose(), scheduled: scheduled}) != nil {
+				return
+			}
+		}
+	}()
+
+	r := newReport(opts, workers)
+	for res := range p.Results() {
+		r.record(opts.Ops[res.In.op].Name, res.Out, res.Err, classify)
+	}
+	r.finish(time.Since(start))
+	return r, nil
+}
+
+// weighted 返回按比重随机选择请求的函数
+func weighted(ops []Op) func() int {
+	total := 0
+	cumulative := make([]int, len(ops))
+	for i, op := range ops {
+		total += max(op.Weight, 1)
+		cumulative[i] = total
+	}
+	return func() int {
+		n := rand.N(total)
+		for i, c := range cumulative {
+			if n < c {
+				return i
+			}
+		}
+		return len(ops) - 1
+	}
+}
+
+// ClassifyError 按错误的种类归类：超时、连接被拒绝、连接被重置等，其他错误使用错误信息本身。
+func ClassifyError(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		if opErr.Err != nil {
+			var sysErr *os.SyscallError
+			if errors.As(opErr.Err, &sysErr) {
+				return opErr.Op + ": " + sysErr.Err.Error()
+			}
+		}
+		return opErr.Op + " error"
+	}
+	return err.Error()
+}
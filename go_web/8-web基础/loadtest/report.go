@@ -0,0 +1,188 @@
+package loadtest
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"example.com/web/pool"
+)
+
+// Report 是压测的结果，可以直接编码成 JSON，时间的单位都是毫秒。
+type Report struct {
+	Mode        string  `json:"mode"` // rate 或 concurrency
+	Rate        float64 `json:"rate,omitempty"`
+	Concurrency int     `json:"concurrency"`
+
+	Elapsed    time.Duration `json:"-"`
+	ElapsedMS  float64       `json:"elapsed_ms"`
+	Requests   int64         `json:"requests"`
+	Failed     int64         `json:"failed"`
+	Throughput float64       `json:"throughput"` // 每秒完成的请求数，包括失败的
+
+	Latency    Latency          `json:"latency"`
+	Operations []OpReport       `json:"operations"` // 按名字排序
+	Errors     map[string]int64 `json:"errors"`     // 错误种类和次数
+	Histogram  []Bracket        `json:"histogram"`  // 所有请求的延迟分布
+
+	hist *Histogram        // 所有请求
+	ops  map[string]*opAcc // 按请求名字
+}
+
+// Latency 是一组请求的延迟统计，单位毫秒
+type Latency struct {
+	Min  float64 `json:"min_ms"`
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// OpReport 是一种请求的统计
+type OpReport struct {
+	Name     string  `json:"name"`
+	Requests int64   `json:"requests"`
+	Failed   int64   `json:"failed"`
+	Latency  Latency `json:"latency"`
+}
+
+type opAcc struct {
+	hist             *Histogram
+	requests, failed int64
+}
+
+func newReport(opts Options, workers int) *Report {
+	r := &Report{
+		Mode:        "concurrency",
+		Concurrency: workers,
+		Errors:      make(map[string]int64),
+		hist:        NewHistogram(),
+		ops:         make(map[string]*opAcc),
+	}
+	if opts.Rate > 0 {
+		r.Mode, r.Rate = "rate", opts.Rate
+	}
+	for _, op := range opts.Ops {
+		r.ops[op.Name] = &opAcc{hist: NewHistogram()}
+	}
+	return r
+}
+
+// record 记录一个请求的结果。失败的请求也记录延迟，超时的请求往往正是最慢的那些。
+func (r *Report) record(op string, latency time.Duration, err error, classify func(error) string) {
+	acc := r.ops[op]
+	r.Requests++
+	acc.requests++
+	if err != nil {
+		r.Failed++
+		acc.failed++
+		var pe *pool.PanicError
+		if errors.As(err, &pe) {
+			r.Errors["panic"]++
+			return // panic 时没有延迟，只计数
+		}
+		r.Errors[classify(err)]++
+	}
+	r.hist.Record(latency)
+	acc.hist.Record(latency)
+}
+
+func (r *Report) finish(elapsed time.Duration) {
+	r.Elapsed, r.ElapsedMS = elapsed, ms(elapsed)
+	if elapsed > 0 {
+		r.Throughput = float64(r.Requests) / elapsed.Seconds()
+	}
+	r.Latency = summarize(r.hist)
+	r.Histogram = r.hist.Distribution()
+	for _, name := range slices.Sorted(maps.Keys(r.ops)) {
+		acc := r.ops[name]
+		r.Operations = append(r.Operations, OpReport{
+			Name:     name,
+			Requests: acc.requests,
+			Failed:   acc.failed,
+			Latency:  summarize(acc.hist),
+		})
+	}
+}
+
+func summarize(h *Histogram) Latency {
+	return Latency{
+		Min:  ms(h.Min()),
+		Mean: ms(h.Mean()),
+		P50:  ms(h.Quantile(0.50)),
+		P90:  ms(h.Quantile(0.90)),
+		P99:  ms(h.Quantile(0.99)),
+		Max:  ms(h.Max()),
+	}
+}
+
+// WriteJSON 以 JSON 格式输出报告
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText 输出给人看的报告：总体统计、每种请求的延迟、错误和延迟分布
+func (r *Report) WriteText(w io.Writer) error {
+	mode := fmt.Sprintf("固定并发 %d", r.Concurrency)
+	if r.Mode == "rate" {
+		mode = fmt.Sprintf("固定速率 %.0f 请求/秒，最多 %d 个并发", r.Rate, r.Concurrency)
+	}
+	fmt.Fprintf(w, "模式      %s\n", mode)
+	fmt.Fprintf(w, "用时      %v\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "请求      %d（失败 %d，%.2f%%）\n", r.Requests, r.Failed, percent(r.Failed, r.Requests))
+	fmt.Fprintf(w, "吞吐量    %.1f 请求/秒\n\n", r.Throughput)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "请求\t次数\t失败\tmin\tmean\tp50\tp90\tp99\tmax\t")
+	row := func(name string, n, failed int64, l Latency) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t\n", name, n, failed,
+			fmtMS(l.Min), fmtMS(l.Mean), fmtMS(l.P50), fmtMS(l.P90), fmtMS(l.P99), fmtMS(l.Max))
+	}
+	for _, op := range r.Operations {
+		row(op.Name, op.Requests, op.Failed, op.Latency)
+	}
+	row("全部", r.Requests, r.Failed, r.Latency)
+	tw.Flush()
+
+	if len(r.Errors) > 0 {
+		fmt.Fprintln(w, "\n错误:")
+		kinds := slices.SortedFunc(maps.Keys(r.Errors), func(a, b string) int {
+			return cmp.Or(cmp.Compare(r.Errors[b], r.Errors[a]), strings.Compare(a, b))
+		})
+		for _, kind := range kinds {
+			fmt.Fprintf(w, "  %6d  %s\n", r.Errors[kind], kind)
+		}
+	}
+
+	if len(r.Histogram) > 0 {
+		fmt.Fprintln(w, "\n延迟分布 (HDR):")
+		tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "分位数\t延迟\t请求数\t")
+		for _, b := range r.Histogram {
+			fmt.Fprintf(tw, "%.4f%%\t%s\t%d\t\n", b.Percentile, fmtMS(b.ValueMS), b.Count)
+		}
+		tw.Flush()
+	}
+	return nil
+}
+
+func percent(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+func fmtMS(v float64) string {
+	return fmt.Sprintf("%.2fms", v)
+}
@@ -0,0 +1,17 @@
+// middleware 包提供可以组合的 HTTP 中间件，每个中间件都是 func(http.Handler) http.Handler。
+package middleware
+
+import "net/http"
+
+// Middleware 包装一个处理函数，在它前后加入额外的逻辑。
+type Middleware func(http.Handler) http.Handler
+
+// Chain 把中间件依次套在 h 外面，第一个中间件在最外层，最先看到请求：
+//
+//	Chain(h, a, b, c) 等价于 a(b(c(h)))
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
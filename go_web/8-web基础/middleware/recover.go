@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover 捕获处理函数中的 panic（参考 41-panic 和 42-defer：defer 中调用 recover），
+// 记录错误和调用栈，然后调用 onPanic 返回错误响应，而不是让连接直接断开。
+// 如果 panic 前已经开始写响应，就只能记录日志了。
+func Recover(logger *slog.Logger, onPanic http.Handler) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &responseRecorder{ResponseWriter: w}
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v) // 处理函数主动中止响应，交给 net/http 处理
+				}
+
+				logger.ErrorContext(r.Context(), "处理请求时发生 panic",
+					"panic", v,
+					"method", r.Method,
+					"path", r.URL.Path,
+					"request_id", RequestIDFrom(r.Context()),
+					"stack", string(debug.Stack()),
+				)
+				if rw.status == 0 {
+					onPanic.ServeHTTP(w, r)
+				}
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
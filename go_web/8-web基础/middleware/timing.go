@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Timing 测量从收到请求到开始写响应所花的时间，在响应头 Server-Timing 中返回（单位毫秒），
+// 浏览器的开发者工具可以直接显示。
+func Timing() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &responseRecorder{
+				ResponseWriter: w,
+				beforeWrite: func(h http.Header) {
+					ms := float64(time.Since(start).Microseconds()) / 1000
+					h.Add("Server-Timing", fmt.Sprintf("app;dur=%.3f", ms))
+				},
+			}
+			next.ServeHTTP(rw, r)
+			if rw.status == 0 {
+				rw.WriteHeader(http.StatusOK) // 处理函数没有写任何内容时也要带上耗时
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import "net/http"
+
+// responseRecorder 记录响应的状态码和字节数，beforeWrite 在写出响应头之前调用一次，
+// 可以用来补充响应头。
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	beforeWrite func(h http.Header)
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+		if rw.beforeWrite != nil {
+			rw.beforeWrite(rw.Header())
+		}
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// Status 返回写出的状态码，处理函数什么都没写时按 200 计算。
+func (rw *responseRecorder) Status() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+// Unwrap 让 http.ResponseController 能找到原始的 ResponseWriter（例如调用 Flush）。
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
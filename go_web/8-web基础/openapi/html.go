@@ -0,0 +1,573 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ServeHTTP 以 JSON 格式输出文档
+func (d *Document) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(d)
+}
+
+// HTMLHandler 返回输出 HTML 文档页面的处理函数，specURL 是 JSON 文档的地址，显示在页面顶部
+func (d *Document) HTMLHandler(specURL string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if err := d.WriteHTML(&buf, specURL); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		buf.WriteTo(w)
+	})
+}
+
+// WriteHTML 把文档渲染成 Swagger UI 风格的页面：操作按 Tag 分组，展开后可以看到参数、请求体、响应和示例，
+// 并且可以直接在页面上发送请求。页面不依赖外部的脚本和样式。
+func (d *Document) WriteHTML(w io.Writer, specURL string) error {
+	return pageTemplate.Execute(w, d.page(specURL))
+}
+
+// 页面需要的数据，在 Go 中整理好，模板只负责输出
+type page struct {
+	Info     Info
+	SpecURL  string
+	Groups   []pageGroup
+	Schemas  []pageSchema
+	Security []pageSecurity
+}
+
+type pageGroup struct {
+	Tag Tag
+	Ops []pageOp
+}
+
+type pageOp struct {
+	ID, Method, Path string
+	*Operation
+	Secured   bool
+	Body      *pageBody
+	Responses []pageResponse
+}
+
+type pageBody struct {
+	*RequestBody
+	MediaType string
+	Schema    *Schema
+	Example   string
+}
+
+type pageResponse struct {
+	Code string
+	*Response
+	MediaType string
+	Schema    *Schema
+	Example   string
+}
+
+type pageSchema struct {
+	Name string
+	*Schema
+	Props []pageProp
+}
+
+type pageProp struct {
+	Name     string
+	Required bool
+	Schema   *Schema
+}
+
+type pageSecurity struct {
+	ID string // 在 Components.SecuritySchemes 中的名字
+	*SecurityScheme
+}
+
+func (d *Document) page(specURL string) page {
+	p := page{Info: d.Info, SpecURL: specURL}
+
+	// 先按 Tags 中的顺序，再按操作中第一次出现的顺序分组
+	groups := make(map[string]int)
+	addGroup := func(t Tag) {
+		if _, ok := groups[t.Name]; !ok {
+			groups[t.Name] = len(p.Groups)
+			p.Groups = append(p.Groups, pageGroup{Tag: t})
+		}
+	}
+	for _, t := range d.Tags {
+		addGroup(t)
+	}
+	for i, ref := range d.order {
+		op := pageOp{
+			ID:        fmt.Sprintf("op-%d", i),
+			Method:    ref.Method,
+			Path:      ref.Path,
+			Operation: ref.Op,
+			Secured:   len(ref.Op.Security) > 0 || ref.Op.Security == nil && len(d.Security) > 0,
+		}
+		if rb := ref.Op.RequestBody; rb != nil {
+			mt, s := firstContent(rb.Content)
+			op.Body = &pageBody{RequestBody: rb, MediaType: mt, Schema: s, Example: d.exampleJSON(s, true)}
+		}
+		for _, code := range sortedCodes(ref.Op.Responses) {
+			resp := ref.Op.Responses[code]
+			mt, s := firstContent(resp.Content)
+			example := "" // 错误响应的格式都一样，只给成功的响应生成示例
+			if strings.Contains(mt, "json") && strings.HasPrefix(code, "2") {
+				example = d.exampleJSON(s, false)
+			}
+			op.Responses = append(op.Responses, pageResponse{Code: code, Response: resp, MediaType: mt, Schema: s, Example: example})
+		}
+		tag := ref.Op.Tags[0]
+		addGroup(Tag{Name: tag})
+		g := &p.Groups[groups[tag]]
+		g.Ops = append(g.Ops, op)
+	}
+	p.Groups = slices.DeleteFunc(p.Groups, func(g pageGroup) bool { return len(g.Ops) == 0 })
+
+	if c := d.Components; c != nil {
+		for _, name := range sortedKeys(c.Schemas) {
+			s := c.Schemas[name]
+			ps := pageSchema{Name: name, Schema: s}
+			for _, prop := range propertyOrder(s) {
+				ps.Props = append(ps.Props, pageProp{Name: prop, Required: slices.Contains(s.Required, prop), Schema: s.Properties[prop]})
+			}
+			p.Schemas = append(p.Schemas, ps)
+		}
+		for _, name := range sortedKeys(c.SecuritySchemes) {
+			p.Security = append(p.Security, pageSecurity{ID: name, SecurityScheme: c.SecuritySchemes[name]})
+		}
+	}
+	return p
+}
+
+// firstContent 返回第一个媒体类型（优先 JSON）和它的 Schema
+func firstContent(content map[string]MediaType) (string, *Schema) {
+	types := sortedKeys(content)
+	if len(types) == 0 {
+		return "", nil
+	}
+	for _, t := range types {
+		if strings.Contains(t, "json") {
+			return t, content[t].Schema
+		}
+	}
+	return types[0], content[types[0]].Schema
+}
+
+// sortedCodes 按状态码排序，default 排在最后
+func sortedCodes(responses map[string]*Response) []string {
+	codes := sortedKeys(responses)
+	if i := slices.Index(codes, "default"); i >= 0 {
+		codes = append(slices.Delete(codes, i, i+1), "default")
+	}
+	return codes
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
+
+// propertyOrder 返回属性的顺序：SchemaOf 生成的按字段顺序，手写的按名字排序
+func propertyOrder(s *Schema) []string {
+	if len(s.order) == len(s.Properties) {
+		return s.order
+	}
+	return sortedKeys(s.Properties)
+}
+
+// resolve 返回 $ref 引用的 Schema，引用不存在时返回 nil
+func (d *Document) resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		if d.Components == nil {
+			return nil
+		}
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// exampleJSON 生成 s 的示例并编码成缩进的 JSON，request 为 true 时不包括只读的属性
+func (d *Document) exampleJSON(s *Schema, request bool) string {
+	if s == nil {
+		return ""
+	}
+	b, err := json.MarshalIndent(d.example(s, request, 0), "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// example 按 Schema 生成示例值：优先使用 Examples、Default 和 Enum，否则按类型和约束生成
+func (d *Document) example(s *Schema, request bool, depth int) any {
+	s = d.resolve(s)
+	switch {
+	case s == nil || depth > 8: // 防止自引用的 Schema 无限递归
+		return nil
+	case len(s.Examples) > 0:
+		return s.Examples[0]
+	case s.Default != nil:
+		return s.Default
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	}
+	switch typeName(s) {
+	case "object":
+		var obj object
+		for _, name := range propertyOrder(s) {
+			prop := s.Properties[name]
+			if request && d.resolve(prop) != nil && d.resolve(prop).ReadOnly {
+				continue
+			}
+			obj = append(obj, field{name, d.example(prop, request, depth+1)})
+		}
+		if len(obj) == 0 && s.AdditionalProperties != nil {
+			obj = append(obj, field{"key", d.example(s.AdditionalProperties, request, depth+1)})
+		}
+		return obj
+	case "array":
+		return []any{d.example(s.Items, request, depth+1)}
+	case "integer":
+		if s.Minimum != nil {
+			return int64(*s.Minimum)
+		}
+		return 0
+	case "number":
+		if s.Minimum != nil {
+			return *s.Minimum
+		}
+		return 0.0
+	case "boolean":
+		return true
+	case "string":
+		switch s.Format {
+		case "date-time":
+			return "2024-01-01T00:00:00Z"
+		case "uri":
+			return "https://example.com"
+		}
+		return "string"
+	}
+	return nil
+}
+
+// object 是保持属性顺序的 JSON 对象，示例中的属性和结构体字段的顺序一致
+type object []field
+
+type field struct {
+	name  string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(f.name)
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// typeName 返回 Schema 的类型名，可以为 null 时返回不是 null 的那个
+func typeName(s *Schema) string {
+	switch t := s.Type.(type) {
+	case string:
+		return t
+	case []string:
+		for _, name := range t {
+			if name != "null" {
+				return name
+			}
+		}
+	}
+	if s.Properties != nil {
+		return "object"
+	}
+	return ""
+}
+
+// typeHTML 输出类型，引用的 Schema 链接到页面底部的定义
+func typeHTML(s *Schema) template.HTML {
+	if s == nil {
+		return ""
+	}
+	esc := template.HTMLEscapeString
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		return template.HTML(`<a href="#schema-` + esc(name) + `">` + esc(name) + `</a>`)
+	}
+	name := typeName(s)
+	switch {
+	case name == "array" && s.Items != nil:
+		return typeHTML(s.Items) + "[]"
+	case name == "":
+		name = "any"
+	case s.Format != "":
+		name += "(" + s.Format + ")"
+	}
+	if t, ok := s.Type.([]string); ok && slices.Contains(t, "null") {
+		name += " | null"
+	}
+	return template.HTML(esc(name))
+}
+
+// constraints 用一句话说明 Schema 的约束
+func constraints(s *Schema) string {
+	if s == nil {
+		return ""
+	}
+	var parts []string
+	num := func(f *float64) string { return strconv.FormatFloat(*f, 'f', -1, 64) }
+	switch {
+	case s.Minimum != nil && s.Maximum != nil:
+		parts = append(parts, num(s.Minimum)+" ≤ 值 ≤ "+num(s.Maximum))
+	case s.Minimum != nil:
+		parts = append(parts, "≥ "+num(s.Minimum))
+	case s.Maximum != nil:
+		parts = append(parts, "≤ "+num(s.Maximum))
+	}
+	lenRange := func(lo, hi *int, unit string) {
+		switch {
+		case lo != nil && hi != nil:
+			parts = append(parts, fmt.Sprintf("%d 到 %d 个%s", *lo, *hi, unit))
+		case lo != nil:
+			parts = append(parts, fmt.Sprintf("至少 %d 个%s", *lo, unit))
+		case hi != nil:
+			parts = append(parts, fmt.Sprintf("最多 %d 个%s", *hi, unit))
+		}
+	}
+	lenRange(s.MinLength, s.MaxLength, "字符")
+	lenRange(s.MinItems, s.MaxItems, "元素")
+	if len(s.Enum) > 0 {
+		vals := make([]string, len(s.Enum))
+		for i, v := range s.Enum {
+			vals[i] = fmt.Sprint(v)
+		}
+		parts = append(parts, "可选 "+strings.Join(vals, "、"))
+	}
+	if s.Default != nil {
+		parts = append(parts, fmt.Sprintf("默认 %v", s.Default))
+	}
+	if s.ReadOnly {
+		parts = append(parts, "只读")
+	}
+	return strings.Join(parts, "；")
+}
+
+var pageTemplate = template.Must(template.New("page").Funcs(template.FuncMap{
+	"lower":       strings.ToLower,
+	"type":        typeHTML,
+	"constraints": constraints,
+}).Parse(pageHTML))
+
+const pageHTML = `<!DOCTYPE html>
+<html lang="zh">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Info.Title}}</title>
+<style>
+	body { font-family: -apple-system, "Segoe UI", Arial, sans-serif; margin: 0; color: #3b4151; background: #fafafa; }
+	.container { max-width: 1000px; margin: 0 auto; padding: 20px 40px 60px; }
+	h1 { font-size: 32px; margin-bottom: 4px; }
+	h1 .version { font-size: 13px; background: #7d8492; color: #fff; border-radius: 10px; padding: 2px 8px; vertical-align: middle; }
+	h2 { border-bottom: 1px solid #d8dde7; padding-bottom: 8px; margin-top: 36px; }
+	h4 { margin: 16px 0 8px; }
+	a { color: #4990e2; }
+	.desc { white-space: pre-line; }
+	.muted { color: #7d8492; font-size: 13px; }
+	.auth { background: #fff; border: 1px solid #d8dde7; border-radius: 4px; padding: 12px 16px; margin-top: 20px; }
+	.auth input { width: 360px; max-width: 100%; }
+	details.op { border: 1px solid; border-radius: 4px; margin: 8px 0; background: #fff; }
+	details.op > summary { display: flex; align-items: center; gap: 10px; padding: 6px 10px; cursor: pointer; list-style: none; }
+	details.op > summary::-webkit-details-marker { display: none; }
+	.method { min-width: 64px; text-align: center; color: #fff; font-weight: bold; font-size: 14px; border-radius: 3px; padding: 6px 0; }
+	.path { font-family: monospace; font-size: 16px; font-weight: 600; }
+	.summary { flex: 1; font-size: 13px; }
+	.lock { font-size: 13px; }
+	.op-body { padding: 4px 20px 16px; border-top: 1px solid #e8e8e8; }
+	.get { border-color: #61affe; background: rgba(97,175,254,.1) !important; } .get .method { background: #61affe; }
+	.post { border-color: #49cc90; background: rgba(73,204,144,.1) !important; } .post .method { background: #49cc90; }
+	.put { border-color: #fca130; background: rgba(252,161,48,.1) !important; } .put .method { background: #fca130; }
+	.patch { border-color: #50e3c2; background: rgba(80,227,194,.1) !important; } .patch .method { background: #50e3c2; }
+	.delete { border-color: #f93e3e; background: rgba(249,62,62,.1) !important; } .delete .method { background: #f93e3e; }
+	.deprecated .path { text-decoration: line-through; }
+	table { border-collapse: collapse; width: 100%; font-size: 14px; }
+	th { text-align: left; font-size: 12px; border-bottom: 1px solid #d8dde7; padding: 6px 8px; }
+	td { vertical-align: top; border-bottom: 1px solid #eee; padding: 6px 8px; }
+	.name { font-family: monospace; font-weight: 600; white-space: nowrap; }
+	.required { color: #f93e3e; font-size: 11px; }
+	pre { background: #333; color: #fff; border-radius: 4px; padding: 10px; overflow-x: auto; font-size: 13px; margin: 4px 0; }
+	textarea { width: 100%; font-family: monospace; font-size: 13px; box-sizing: border-box; }
+	input { font-family: monospace; padding: 4px; }
+	button { background: #4990e2; color: #fff; border: 0; border-radius: 4px; padding: 6px 18px; cursor: pointer; font-weight: bold; }
+	.schema { background: #fff; border: 1px solid #d8dde7; border-radius: 4px; padding: 4px 16px 12px; margin: 12px 0; }
+	.result:empty { display: none; }
+</style>
+</head>
+<body>
+<div class="container">
+	<h1>{{.Info.Title}} <span class="version">{{.Info.Version}}</span></h1>
+	<p class="muted"><a href="{{.SpecURL}}">{{.SpecURL}}</a> · OpenAPI 3.1</p>
+	{{with .Info.Description}}<p class="desc">{{.}}</p>{{end}}
+
+	{{with .Security}}
+	<div class="auth">
+		<strong>认证</strong>
+		{{range .}}
+		<p>
+			<label class="name" for="auth-{{.ID}}">{{.ID}}</label>
+			<span class="muted">{{if eq .Type "http"}}Authorization: {{.Scheme}}{{else}}{{.In}} {{.Name}}{{end}}{{with .Description}} — {{.}}{{end}}</span><br>
+			<input id="auth-{{.ID}}" data-type="{{.Type}}" data-scheme="{{.Scheme}}" data-header="{{.Name}}" data-in="{{.In}}" class="credential" placeholder="填写后，页面上发出的请求都会带上">
+		</p>
+		{{end}}
+	</div>
+	{{end}}
+
+	{{range .Groups}}
+	<h2>{{.Tag.Name}}{{with .Tag.Description}} <span class="muted">{{.}}</span>{{end}}</h2>
+	{{range .Ops}}
+	<details class="op {{lower .Method}}{{if .Deprecated}} deprecated{{end}}" id="{{.ID}}">
+		<summary>
+			<span class="method">{{.Method}}</span>
+			<span class="path">{{.Path}}</span>
+			<span class="summary">{{.Summary}}</span>
+			{{if .Secured}}<span class="lock" title="需要认证">🔒</span>{{end}}
+		</summary>
+		<div class="op-body">
+			{{with .Description}}<p class="desc">{{.}}</p>{{end}}
+			<form data-method="{{.Method}}" data-path="{{.Path}}" onsubmit="return tryIt(this)">
+			{{with .Parameters}}
+			<h4>参数</h4>
+			<table>
+				<tr><th>名字</th><th>位置</th><th>类型</th><th>说明</th><th>值</th></tr>
+				{{range .}}
+				<tr>
+					<td class="name">{{.Name}}{{if .Required}} <span class="required">* 必填</span>{{end}}</td>
+					<td>{{.In}}</td>
+					<td>{{type .Schema}}</td>
+					<td>{{.Description}}{{with constraints .Schema}}<div class="muted">{{.}}</div>{{end}}</td>
+					<td><input name="{{.Name}}" data-in="{{.In}}"{{if .Required}} required{{end}}></td>
+				</tr>
+				{{end}}
+			</table>
+			{{end}}
+			{{with .Body}}
+			<h4>请求体 <span class="muted">{{.MediaType}}{{if .Required}}，必填{{end}}</span></h4>
+			{{with .Description}}<p>{{.}}</p>{{end}}
+			{{with .Schema}}<p class="muted">类型 {{type .}}</p>{{end}}
+			<textarea name="body" data-content-type="{{.MediaType}}" rows="{{if .Example}}8{{else}}3{{end}}">{{.Example}}</textarea>
+			{{end}}
+			<p><button type="submit">发送请求</button></p>
+			<pre class="result"></pre>
+			</form>
+			<h4>响应</h4>
+			<table>
+				<tr><th>状态码</th><th>说明</th></tr>
+				{{range .Responses}}
+				<tr>
+					<td class="name">{{.Code}}</td>
+					<td>
+						{{.Description}}
+						{{with .MediaType}}<div class="muted">{{.}}</div>{{end}}
+						{{with .Schema}}<div class="muted">类型 {{type .}}</div>{{end}}
+						{{range $name, $h := .Headers}}<div class="muted">响应头 <span class="name">{{$name}}</span> {{$h.Description}}</div>{{end}}
+						{{with .Example}}<pre>{{.}}</pre>{{end}}
+					</td>
+				</tr>
+				{{end}}
+			</table>
+		</div>
+	</details>
+	{{end}}
+	{{end}}
+
+	{{with .Schemas}}
+	<h2>Schemas</h2>
+	{{range .}}
+	<div class="schema" id="schema-{{.Name}}">
+		<h4>{{.Name}} <span class="muted">{{type .Schema}}</span></h4>
+		{{with .Description}}<p class="desc">{{.}}</p>{{end}}
+		{{with .Props}}
+		<table>
+			<tr><th>属性</th><th>类型</th><th>约束</th><th>说明</th></tr>
+			{{range .}}
+			<tr>
+				<td class="name">{{.Name}}{{if .Required}} <span class="required">* 必填</span>{{end}}</td>
+				<td>{{type .Schema}}</td>
+				<td>{{constraints .Schema}}</td>
+				<td>{{.Schema.Description}}</td>
+			</tr>
+			{{end}}
+		</table>
+		{{end}}
+	</div>
+	{{end}}
+	{{end}}
+</div>
+<script>
+// 发送表单对应的请求：路径参数替换到路径中，查询参数拼到 URL 上，认证信息来自页面顶部
+function tryIt(form) {
+	let path = form.dataset.path;
+	const query = new URLSearchParams();
+	const headers = {};
+	for (const input of form.querySelectorAll("input[data-in]")) {
+		if (input.value === "") continue;
+		switch (input.dataset.in) {
+		case "path": path = path.replace("{" + input.name + "}", encodeURIComponent(input.value)); break;
+		case "query": query.append(input.name, input.value); break;
+		case "header": headers[input.name] = input.value; break;
+		}
+	}
+	for (const cred of document.querySelectorAll(".credential")) {
+		if (cred.value === "") continue;
+		if (cred.dataset.type === "http") {
+			headers["Authorization"] = cred.dataset.scheme.replace(/^./, c => c.toUpperCase()) + " " + cred.value;
+		} else if (cred.dataset.in === "header") {
+			headers[cred.dataset.header] = cred.value;
+		}
+	}
+	const init = {method: form.dataset.method, headers: headers};
+	const body = form.querySelector("textarea[name=body]");
+	if (body && body.value.trim() !== "") {
+		headers["Content-Type"] = body.dataset.contentType;
+		init.body = body.value;
+	}
+	const url = path + (query.size > 0 ? "?" + query : "");
+	const out = form.querySelector(".result");
+	out.textContent = init.method + " " + url + " ...";
+	fetch(url, init).then(async resp => {
+		let text = await resp.text();
+		try { text = JSON.stringify(JSON.parse(text), null, 2); } catch (e) {}
+		const hdrs = [...resp.headers].map(([k, v]) => k + ": " + v).join("\n");
+		out.textContent = init.method + " " + url + "\n\n" + resp.status + " " + resp.statusText + "\n" + hdrs + "\n\n" + text;
+	}).catch(err => { out.textContent = String(err); });
+	return false;
+}
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,291 @@
+// openapi 包根据路由和类型信息生成 OpenAPI 3.1 文档 (https://spec.openapis.org/oas/v3.1.0)，
+// 并把文档渲染成 Swagger UI 风格的 HTML 页面：
+//
+//	spec := openapi.NewSpec(openapi.Info{Title: "用户服务", Version: "1.0.0"})
+//	user := spec.Define("User", openapi.SchemaOf(store.User{})) // 由 json 和 validate 标签生成
+//	spec.Operations["GET /users/{id}"] = &openapi.Operation{
+//		Summary:   "获取用户",
+//		Responses: map[string]*openapi.Response{"200": openapi.JSONResponse("用户", user)},
+//	}
+//	doc := spec.Document(rt.Routes())
+//
+// 路径来自路由器，路径参数从路径中自动生成；没有写文档的路由也会出现在文档中，只是没有说明。
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"example.com/web/router"
+)
+
+// Version 是生成的文档使用的 OpenAPI 版本
+const Version = "3.1.0"
+
+// Document 是 OpenAPI 文档的根对象，可以直接编码成 JSON。
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components *Components           `json:"components,omitempty"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+
+	order []operationRef // 按路由注册顺序排列的操作，用于生成 HTML 页面
+}
+
+// Info 是 API 的基本信息，Description 可以使用 CommonMark
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag 用于给操作分组，HTML 页面按 Tag 分节
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem 是一个路径上的所有操作，键为小写的方法名
+type PathItem map[string]*Operation
+
+// Operation 是一个方法和路径上的操作
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses,omitempty"` // 键为状态码或 default
+	Security    []SecurityRequirement `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+// Parameter 是路径、查询参数或请求头，In 为 path、query、header 或 cookie
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"` // 键为媒体类型
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]*Header   `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme 是一种认证方式，例如 {Type: "http", Scheme: "bearer"} 或 {Type: "apiKey", In: "header", Name: "X-API-Key"}
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// SecurityRequirement 列出请求可以使用的认证方式，键为 Components.SecuritySchemes 中的名字。
+// 一个操作有多个 SecurityRequirement 时满足其中一个即可。
+type SecurityRequirement map[string][]string
+
+// JSONBody 返回内容为 JSON 的必填请求体
+func JSONBody(description string, s *Schema) *RequestBody {
+	return &RequestBody{Description: description, Required: true, Content: map[string]MediaType{"application/json": {Schema: s}}}
+}
+
+// JSONResponse 返回内容为 JSON 的响应，s 为 nil 时没有响应体
+func JSONResponse(description string, s *Schema) *Response {
+	return ContentResponse(description, "application/json", s)
+}
+
+// ContentResponse 返回内容为 mediaType 的响应，s 为 nil 时没有响应体
+func ContentResponse(description, mediaType string, s *Schema) *Response {
+	resp := &Response{Description: description}
+	if s != nil {
+		resp.Content = map[string]MediaType{mediaType: {Schema: s}}
+	}
+	return resp
+}
+
+// Spec 收集生成文档需要的信息：API 的基本信息、每个操作的说明和共用的 Schema。
+type Spec struct {
+	Info       Info
+	Tags       []Tag
+	Components Components
+
+	// Operations 是每个操作的说明，键和注册路由时一样是 "方法 路径"，例如 "GET /users/{id}"
+	Operations map[string]*Operation
+}
+
+// NewSpec 创建空的 Spec
+func NewSpec(info Info) *Spec {
+	return &Spec{
+		Info: info,
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: make(map[string]*SecurityScheme),
+		},
+		Operations: make(map[string]*Operation),
+	}
+}
+
+// Define 把 s 加入 components.schemas，返回引用它的 Schema，用在操作中。
+func (s *Spec) Define(name string, schema *Schema) *Schema {
+	if _, dup := s.Components.Schemas[name]; dup {
+		panic("openapi: 重复定义 Schema " + name)
+	}
+	s.Components.Schemas[name] = schema
+	return Ref(name)
+}
+
+// Ref 返回引用 components.schemas 中 name 的 Schema
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// operationRef 记录操作所在的路径和方法
+type operationRef struct {
+	Path   string
+	Method string
+	Op     *Operation
+}
+
+// Document 按 routes 生成文档。HEAD 和 OPTIONS 由路由器自动处理，不出现在文档中。
+// Operations 中有路由不存在的操作属于程序错误，会直接 panic。
+func (s *Spec) Document(routes []router.Route) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    s.Info,
+		Tags:    s.Tags,
+		Paths:   make(map[string]PathItem),
+	}
+	if len(s.Components.Schemas) > 0 || len(s.Components.SecuritySchemes) > 0 {
+		doc.Components = &s.Components
+	}
+
+	documented := make(map[string]bool)
+	for _, rt := range routes {
+		if rt.Method == http.MethodHead || rt.Method == http.MethodOptions {
+			continue
+		}
+		key := rt.Method + " " + rt.Pattern
+		documented[key] = true
+
+		// 复制一份再补充，不修改 Spec 中的说明
+		op := &Operation{}
+		if o, ok := s.Operations[key]; ok {
+			*op = *o
+		}
+		path, params := Path(rt.Pattern)
+		op.Parameters = append(pathParameters(params, op.Parameters), queryParameters(op.Parameters)...)
+		if len(op.Tags) == 0 {
+			op.Tags = []string{defaultTag(path)}
+		}
+		if len(op.Responses) == 0 {
+			op.Responses = map[string]*Response{"200": {Description: http.StatusText(http.StatusOK)}}
+		}
+
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(rt.Method)] = op
+		doc.order = append(doc.order, operationRef{Path: path, Method: rt.Method, Op: op})
+	}
+	for key := range s.Operations {
+		if !documented[key] {
+			panic(fmt.Sprintf("openapi: 操作 %s 没有对应的路由", key))
+		}
+	}
+	return doc
+}
+
+// 路由模式中的通配符，例如 {id}、{path...} 和 {$}
+var wildcard = regexp.MustCompile(`\{([^}]*)\}`)
+
+// Path 把 ServeMux 的路由模式转换成 OpenAPI 的路径，返回路径和其中的参数名：
+// /users/{id} 不变，{name...} 变成 {name}，结尾的 {$} 去掉。
+func Path(pattern string) (path string, params []string) {
+	path = wildcard.ReplaceAllStringFunc(pattern, func(m string) string {
+		name := strings.TrimSuffix(m[1:len(m)-1], "...")
+		if name == "$" {
+			return ""
+		}
+		params = append(params, name)
+		return "{" + name + "}"
+	})
+	if path == "" {
+		path = "/"
+	}
+	return path, params
+}
+
+// pathParameters 按路径中的顺序返回路径参数，操作中已经说明的参数保留原样，其余的生成字符串类型的参数
+func pathParameters(names []string, declared []*Parameter) []*Parameter {
+	var out []*Parameter
+	for _, name := range names {
+		p := &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}}
+		for _, d := range declared {
+			if d.In == "path" && d.Name == name {
+				c := *d
+				c.Required = true // OpenAPI 要求路径参数必填
+				p = &c
+			}
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// queryParameters 返回不是路径参数的参数
+func queryParameters(declared []*Parameter) []*Parameter {
+	var out []*Parameter
+	for _, d := range declared {
+		if d.In != "path" {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// defaultTag 使用路径的第一段作为分组，根路径为 default
+func defaultTag(path string) string {
+	first, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if first == "" || strings.HasPrefix(first, "{") {
+		return "default"
+	}
+	return first
+}
@@ -0,0 +1,119 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+
+	"example.com/web/router"
+)
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		params  []string
+	}{
+		{"/users", "/users", nil},
+		{"/users/{id}", "/users/{id}", []string{"id"}},
+		{"/users/{id}/posts/{post}", "/users/{id}/posts/{post}", []string{"id", "post"}},
+		{"/files/{path...}", "/files/{path}", []string{"path"}},
+		{"/{$}", "/", nil},
+		{"/users/{$}", "/users/", nil},
+		{"/", "/", nil},
+	}
+	for _, tt := range tests {
+		path, params := Path(tt.pattern)
+		if path != tt.path || !slices.Equal(params, tt.params) {
+			t.Errorf("Path(%q) = %q, %q，应该是 %q, %q", tt.pattern, path, params, tt.path, tt.params)
+		}
+	}
+}
+
+func TestDocument(t *testing.T) {
+	rt := router.New()
+	noop := func(http.ResponseWriter, *http.Request) {}
+	rt.Get("/{$}", noop)
+	rt.Get("/users/{id}", noop)
+	rt.Patch("/users/{id}", noop)
+	rt.HandleFunc(http.MethodOptions, "/users/{id}", noop)
+	rt.Get("/files/{path...}", noop)
+
+	spec := NewSpec(Info{Title: "测试", Version: "1"})
+	user := spec.Define("User", SchemaOf(struct {
+		Name string `json:"name"`
+	}{}))
+	idParam := &Parameter{Name: "id", In: "path", Description: "用户 ID", Schema: &Schema{Type: "integer"}}
+	spec.Operations["GET /users/{id}"] = &Operation{
+		Summary:    "获取用户",
+		Parameters: []*Parameter{{Name: "fields", In: "query"}, idParam},
+		Responses:  map[string]*Response{"200": JSONResponse("用户", user)},
+	}
+	doc := spec.Document(rt.Routes())
+
+	if doc.OpenAPI != Version || doc.Info.Title != "测试" || doc.Components.Schemas["User"] == nil || user.Ref != "#/components/schemas/User" {
+		t.Fatalf("文档是 %+v", doc)
+	}
+	var paths []string
+	for _, op := range doc.order {
+		paths = append(paths, op.Method+" "+op.Path)
+	}
+	// OPTIONS 由路由器自动处理，不出现在文档中
+	if want := []string{"GET /", "GET /users/{id}", "PATCH /users/{id}", "GET /files/{path}"}; !slices.Equal(paths, want) {
+		t.Fatalf("文档中的操作是 %q，应该是 %q", paths, want)
+	}
+	if _, ok := doc.Paths["/users/{id}"]["options"]; ok {
+		t.Fatal("文档中有 OPTIONS")
+	}
+
+	// 路径参数排在前面并且必填，说明过的参数保留说明
+	get := doc.Paths["/users/{id}"]["get"]
+	if len(get.Parameters) != 2 || get.Parameters[0].Name != "id" || !get.Parameters[0].Required ||
+		get.Parameters[0].Description != "用户 ID" || get.Parameters[1].Name != "fields" {
+		t.Fatalf("GET /users/{id} 的参数是 %+v", get.Parameters)
+	}
+	if idParam.Required || len(spec.Operations["GET /users/{id}"].Parameters) != 2 || spec.Operations["GET /users/{id}"].Tags != nil {
+		t.Fatal("Document 修改了 Spec 中的说明")
+	}
+	// 没有说明的路由也在文档中，参数和分组自动生成
+	patch := doc.Paths["/users/{id}"]["patch"]
+	want := &Operation{
+		Tags:       []string{"users"},
+		Parameters: []*Parameter{{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}}},
+		Responses:  map[string]*Response{"200": {Description: "OK"}},
+	}
+	if !reflect.DeepEqual(patch, want) {
+		t.Fatalf("PATCH /users/{id} 是 %+v", patch)
+	}
+	for path, tag := range map[string]string{"/": "default", "/users/{id}": "users", "/files/{path}": "files"} {
+		if got := doc.Paths[path]["get"].Tags; !slices.Equal(got, []string{tag}) {
+			t.Errorf("GET %s 的分组是 %q，应该是 %q", path, got, tag)
+		}
+	}
+}
+
+func TestSpecPanics(t *testing.T) {
+	tests := map[string]func(){
+		"重复定义": func() {
+			spec := NewSpec(Info{})
+			spec.Define("User", &Schema{})
+			spec.Define("User", &Schema{})
+		},
+		"没有路由的操作": func() {
+			spec := NewSpec(Info{})
+			spec.Operations["DELETE /users/{id}"] = &Operation{}
+			spec.Document(nil)
+		},
+	}
+	for name, fn := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: 应该 panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
@@ -0,0 +1,213 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/web/validate"
+)
+
+// Schema 是 JSON Schema (2020-12) 的一个子集，OpenAPI 3.1 直接使用 JSON Schema 描述数据。
+type Schema struct {
+	Ref         string `json:"$ref,omitempty"`
+	Type        any    `json:"type,omitempty"` // 类型名，可以为 null 时是 [类型名, "null"]
+	Format      string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+
+	Enum      []any    `json:"enum,omitempty"`
+	Minimum   *float64 `json:"minimum,omitempty"`
+	Maximum   *float64 `json:"maximum,omitempty"`
+	MinLength *int     `json:"minLength,omitempty"`
+	MaxLength *int     `json:"maxLength,omitempty"`
+	MinItems  *int     `json:"minItems,omitempty"`
+	MaxItems  *int     `json:"maxItems,omitempty"`
+	Default   any      `json:"default,omitempty"`
+	ReadOnly  bool     `json:"readOnly,omitempty"`
+	Examples  []any    `json:"examples,omitempty"`
+
+	order []string // 属性按结构体字段的顺序排列，用于生成 HTML 页面
+}
+
+// SchemaOf 根据 v 的类型生成 Schema，v 可以是值或者 reflect.Type。结构体的属性名来自 json 标签，
+// validate 标签转换成对应的约束：
+//
+//	required  加入 required，字符串同时设置 minLength 为 1
+//	min、max  数字为 minimum、maximum，字符串为 minLength、maxLength，切片为 minItems、maxItems
+//	oneof     enum
+//
+// time.Time 是 date-time 格式的字符串，指针可以为 null。
+func SchemaOf(v any) *Schema {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	return schemaOf(t)
+}
+
+var (
+	timeType      = reflect.TypeFor[time.Time]()
+	rawJSONType   = reflect.TypeFor[json.RawMessage]()
+	marshalerType = reflect.TypeFor[json.Marshaler]()
+)
+
+func schemaOf(t reflect.Type) *Schema {
+	switch {
+	case t == nil:
+		return &Schema{}
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawJSONType:
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := schemaOf(t.Elem())
+		if name, ok := s.Type.(string); ok {
+			s.Type = []string{name, "null"}
+		}
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: ptr(0.0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"} // encoding/json 把 []byte 编码成 base64
+		}
+		return &Schema{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+			return &Schema{} // 自定义了 JSON 编码，不知道具体格式
+		}
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		addFields(s, t)
+		return s
+	default: // 接口等，可以是任何值
+		return &Schema{}
+	}
+}
+
+// addFields 把结构体 t 的字段加到 s 中，没有 json 名字的嵌入结构体的字段提升到外层，和 encoding/json 一样
+func addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(s, ft)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		name = validate.FieldName(sf)
+
+		prop := schemaOf(ft)
+		if strings.Contains(","+opts+",", ",string,") {
+			prop = &Schema{Type: "string"} // json:",string" 把数字和布尔值编码成字符串
+		}
+		if applyRules(prop, ft, sf.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		if _, dup := s.Properties[name]; !dup {
+			s.order = append(s.order, name)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// applyRules 把 validate 标签转换成约束，返回字段是否必填。标签中的规则和 validate 包一致。
+func applyRules(s *Schema, t reflect.Type, tag string) (required bool) {
+	if tag == "" || tag == "-" {
+		return false
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		rule, param, _ := strings.Cut(rule, "=")
+		switch rule {
+		case "required":
+			required = true
+			if t.Kind() == reflect.String && s.MinLength == nil {
+				s.MinLength = ptr(1) // validate 要求去掉首尾空白后不能为空，这里只能表达不能是空字符串
+			}
+		case "min", "max":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				panic(fmt.Sprintf("openapi: 规则 %s 的参数 %q 不是数字", rule, param))
+			}
+			switch t.Kind() {
+			case reflect.String:
+				setLimit(&s.MinLength, &s.MaxLength, rule, int(n))
+			case reflect.Slice, reflect.Array, reflect.Map:
+				setLimit(&s.MinItems, &s.MaxItems, rule, int(n))
+			default:
+				setLimit(&s.Minimum, &s.Maximum, rule, n)
+			}
+		case "oneof":
+			for _, opt := range strings.Fields(param) {
+				s.Enum = append(s.Enum, enumValue(t, opt))
+			}
+		}
+	}
+	return required
+}
+
+func setLimit[T any](lo, hi **T, rule string, v T) {
+	if rule == "min" {
+		*lo = &v
+	} else {
+		*hi = &v
+	}
+}
+
+// enumValue 按字段的类型转换 oneof 中的值，例如整数字段的 oneof=1 2 是数字而不是字符串
+func enumValue(t reflect.Type, s string) any {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+	return s
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,94 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type base struct {
+	ID      int       `json:"id"`
+	Created time.Time `json:"created"`
+}
+
+type item struct {
+	base
+	Name    string          `json:"name" validate:"required,max=50"`
+	Age     int             `json:"age" validate:"min=0,max=150"`
+	Score   float64         `json:"score,omitempty" validate:"max=100"`
+	Role    string          `json:"role" validate:"oneof=admin viewer"`
+	Level   uint8           `json:"level" validate:"oneof=1 2"`
+	Tags    []string        `json:"tags" validate:"min=1"`
+	Labels  map[string]int  `json:"labels"`
+	Nick    *string         `json:"nick" validate:"required,max=5"`
+	Parent  *base           `json:"parent"`
+	Data    []byte          `json:"data"`
+	Raw     json.RawMessage `json:"raw"`
+	Big     int64           `json:"big,string"`
+	Any     any             `json:"any"`
+	When    jsonTime        `json:"when"`
+	Dash    string          `json:"-,"`
+	NoTag   bool
+	Skipped string `json:"-"`
+	private string
+}
+
+// jsonTime 自定义了 JSON 编码
+type jsonTime struct{ t time.Time }
+
+func (t jsonTime) MarshalJSON() ([]byte, error) { return json.Marshal(t.t.Unix()) }
+
+func TestSchemaOf(t *testing.T) {
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{0, `{"type":"integer"}`},
+		{int64(0), `{"type":"integer","format":"int64"}`},
+		{uint(0), `{"type":"integer","minimum":0}`},
+		{"", `{"type":"string"}`},
+		{true, `{"type":"boolean"}`},
+		{1.5, `{"type":"number"}`},
+		{[]int{}, `{"type":"array","items":{"type":"integer"}}`},
+		{map[string]bool{}, `{"type":"object","additionalProperties":{"type":"boolean"}}`},
+		{(*int)(nil), `{"type":["integer","null"]}`},
+		{reflect.TypeFor[[]*time.Time](), `{"type":"array","items":{"type":["string","null"],"format":"date-time"}}`},
+		{nil, `{}`},
+		{item{}, `{"type":"object","properties":{` +
+			`"-":{"type":"string"},` +
+			`"NoTag":{"type":"boolean"},` +
+			`"age":{"type":"integer","minimum":0,"maximum":150},` +
+			`"any":{},` +
+			`"big":{"type":"string"},` +
+			`"created":{"type":"string","format":"date-time"},` +
+			`"data":{"type":"string","format":"byte"},` +
+			`"id":{"type":"integer"},` +
+			`"labels":{"type":"object","additionalProperties":{"type":"integer"}},` +
+			`"level":{"type":"integer","enum":[1,2],"minimum":0},` +
+			`"name":{"type":"string","minLength":1,"maxLength":50},` +
+			`"nick":{"type":["string","null"],"minLength":1,"maxLength":5},` +
+			`"parent":{"type":["object","null"],"properties":{"created":{"type":"string","format":"date-time"},"id":{"type":"integer"}}},` +
+			`"raw":{},` +
+			`"role":{"type":"string","enum":["admin","viewer"]},` +
+			`"score":{"type":"number","maximum":100},` +
+			`"tags":{"type":"array","items":{"type":"string"},"minItems":1},` +
+			`"when":{}` +
+			`},"required":["name","nick"]}`},
+	}
+	for _, tt := range tests {
+		b, err := json.Marshal(SchemaOf(tt.v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("SchemaOf(%T) = %s\n应该是 %s", tt.v, b, tt.want)
+		}
+	}
+
+	// 属性按字段顺序排列，嵌入结构体的字段在它的位置展开
+	want := []string{"id", "created", "name", "age", "score", "role", "level", "tags", "labels", "nick", "parent", "data", "raw", "big", "any", "when", "-", "NoTag"}
+	if got := SchemaOf(item{}).order; !reflect.DeepEqual(got, want) {
+		t.Errorf("属性的顺序是 %q，应该是 %q", got, want)
+	}
+}
@@ -0,0 +1,369 @@
+// pool 包是从 go_example/34-工作池 发展来的通用工作池：
+// 任务和结果的类型由类型参数决定，worker 数量可以在运行中调整。
+//
+//	p := pool.New(ctx, func(ctx context.Context, n int) (int, error) {
+//		return n * 2, nil
+//	}, pool.Options{Workers: 3, QueueSize: 100})
+//	go func() {
+//		for j := 1; j <= 9; j++ {
+//			p.Submit(ctx, j)
+//		}
+//		p.Close()
+//	}()
+//	for r := range p.Results() {
+//		fmt.Println(r.In, r.Out, r.Err)
+//	}
+//
+// 每个提交成功的任务都有一个结果，调用方需要一直读 Results 直到它被关闭，否则 worker 会阻塞。
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrClosed    = errors.New("pool: 工作池已关闭")
+	ErrQueueFull = errors.New("pool: 队列已满")
+)
+
+// PanicError 表示任务执行时发生了 panic。panic 只影响这一个任务，worker 继续处理后面的任务。
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("pool: 任务 panic: %v", e.Value)
+}
+
+// Options 是工作池的设置
+type Options struct {
+	Workers   int  // 初始 worker 数，小于 1 时为 1
+	QueueSize int  // 等待执行的任务最多有多少，队列满时 Submit 阻塞，形成背压
+	Ordered   bool // 结果按提交顺序输出；先完成的任务要等前面的任务，会占用更多内存
+}
+
+// Result 是一个任务的结果
+type Result[In, Out any] struct {
+	Seq int // 提交的顺序，从 0 开始
+	In  In
+	Out Out
+	Err error // fn 返回的错误、*PanicError，或者工作池的 ctx 取消后没有执行的任务的 ctx.Err()
+}
+
+// Stats 是工作池的运行统计
+type Stats struct {
+	Workers   int   // 设置的 worker 数，缩容后多出来的 worker 可能还在处理最后一个任务
+	Queued    int64 // 在队列中等待的任务
+	Running   int64 // 正在执行的任务
+	Completed int64 // 成功完成的任务
+	Failed    int64 // 返回错误或 panic 的任务
+}
+
+type job[In any] struct {
+	seq int
+	in  In
+}
+
+// Pool 用一组 worker 并发执行 fn。
+type Pool[In, Out any] struct {
+	ctx     context.Context
+	fn      func(ctx context.Context, in In) (Out, error)
+	jobs    chan job[In]
+	done    chan Result[In, Out] // worker 输出的结果，由 collect 转发到 results
+	results chan Result[In, Out]
+	wg      sync.WaitGroup
+	ordered bool
+
+	// submitMu 只在分配 Seq 和检查 closed 时持有，Submit 等待队列空位时不持有，
+	// 否则 Close 要等到有 worker 腾出空位才能拿到锁
+	submitMu sync.Mutex
+	seq      int
+	sending  sync.WaitGroup // 已经分配了 Seq、还没有放进队列或放弃的 Submit，全部结束后才能关闭 jobs
+	closing  chan struct{}  // Close 时关闭，让等待队列空位的 Submit 返回 ErrClosed
+
+	mu      sync.Mutex       // 保护 stops 和 skipped；修改 closed 时同时持有 submitMu 和 mu
+	stops   []chan struct{}  // 每个 worker 一个，关闭后 worker 处理完手上的任务就退出
+	skipped map[int]struct{} // 按顺序输出时，分配了 Seq 但提交失败的任务，collect 跳过它们
+	wake    chan struct{}    // 有新的 skipped 时通知 collect
+	closed  bool
+
+	queued, running, completed, failed atomic.Int64
+}
+
+// New 创建工作池并启动 worker。ctx 取消后，还在队列中的任务不再执行，结果的 Err 为 ctx.Err()。
+func New[In, Out any](ctx context.Context, fn func(ctx context.Context, in In) (Out, error), opts Options) *Pool[In, Out] {
+	p := &Pool[In, Out]{
+		ctx:     ctx,
+		fn:      fn,
+		jobs:    make(chan job[In], max(opts.QueueSize, 0)),
+		done:    make(chan Result[In, Out]),
+		results: make(chan Result[In, Out]),
+		ordered: opts.Ordered,
+		closing: make(chan struct{}),
+		skipped: make(map[int]struct{}),
+		wake:    make(chan struct{}, 1),
+	}
+	p.Resize(max(opts.Workers, 1))
+	go p.collect()
+	return p
+}
+
+// Submit 提交一个任务，队列满时阻塞，直到有空位、ctx 或工作池的 ctx 被取消、或者工作池被关闭。
+func (p *Pool[In, Out]) Submit(ctx context.Context, in In) error {
+	seq, err := p.reserve()
+	if err != nil {
+		return err
+	}
+	select {
+	case p.jobs <- job[In]{seq: seq, in: in}:
+		p.sending.Done()
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-p.ctx.Done():
+		err = p.ctx.Err()
+	case <-p.closing:
+		err = ErrClosed
+	}
+	p.abandon(seq)
+	return err
+}
+
+// TrySubmit 和 Submit 一样，但队列满时不等待，直接返回 ErrQueueFull。
+func (p *Pool[In, Out]) TrySubmit(in In) error {
+	seq, err := p.reserve()
+	if err != nil {
+		return err
+	}
+	select {
+	case p.jobs <- job[In]{seq: seq, in: in}:
+		p.sending.Done()
+		return nil
+	case <-p.closing:
+		err = ErrClosed
+	default:
+		err = ErrQueueFull
+	}
+	p.abandon(seq)
+	return err
+}
+
+// reserve 为一次提交分配 Seq，之后必须调用 sending.Done 或 abandon
+func (p *Pool[In, Out]) reserve() (int, error) {
+	p.submitMu.Lock()
+	defer p.submitMu.Unlock()
+	if p.closed {
+		return 0, ErrClosed
+	}
+	seq := p.seq
+	p.seq++
+	p.queued.Add(1)
+	p.sending.Add(1)
+	return seq, nil
+}
+
+// abandon 放弃分配了 seq 但没有放进队列的任务，按顺序输出时让 collect 跳过它
+func (p *Pool[In, Out]) abandon(seq int) {
+	p.queued.Add(-1)
+	if p.ordered {
+		p.mu.Lock()
+		p.skipped[seq] = struct{}{}
+		p.mu.Unlock()
+		select {
+		case p.wake <- struct{}{}:
+		default: // 已经有一个通知在等 collect 处理
+		}
+	}
+	p.sending.Done()
+}
+
+// Close 表示不再提交任务，正在等待队列空位的 Submit 返回 ErrClosed。
+// 已经提交的任务执行完之后，Results 被关闭。Close 不等待任务完成，可以多次调用。
+func (p *Pool[In, Out]) Close() {
+	p.submitMu.Lock()
+	defer p.submitMu.Unlock()
+	if p.closed {
+		return
+	}
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	close(p.closing)
+
+	go func() {
+		p.sending.Wait() // 之后没有人再往 jobs 中发送
+		close(p.jobs)
+		p.wg.Wait()
+		close(p.done)
+	}()
+}
+
+// Results 返回结果通道，所有任务完成并且调用了 Close 之后被关闭。
+func (p *Pool[In, Out]) Results() <-chan Result[In, Out] {
+	return p.results
+}
+
+// Resize 把 worker 数调整为 n（至少为 1）。
+// 减少时多出来的 worker 处理完手上的任务再退出，不会中断正在执行的任务。
+// Close 之后调用不起作用。
+func (p *Pool[In, Out]) Resize(n int) {
+	n = max(n, 1)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	for len(p.stops) < n {
+		stop := make(chan struct{})
+		p.stops = append(p.stops, stop)
+		p.wg.Add(1)
+		go p.worker(stop)
+	}
+	for len(p.stops) > n {
+		last := len(p.stops) - 1
+		close(p.stops[last])
+		p.stops = p.stops[:last]
+	}
+}
+
+// Stats 返回当前的统计
+func (p *Pool[In, Out]) Stats() Stats {
+	p.mu.Lock()
+	workers := len(p.stops)
+	p.mu.Unlock()
+	return Stats{
+		Workers:   workers,
+		Queued:    p.queued.Load(),
+		Running:   p.running.Load(),
+		Completed: p.completed.Load(),
+		Failed:    p.failed.Load(),
+	}
+}
+
+func (p *Pool[In, Out]) worker(stop <-chan struct{}) {
+	defer p.wg.Done()
+	for {
+		// 先检查 stop，缩容之后空闲的 worker 不再接新任务
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		select {
+		case <-stop:
+			return
+		case j, ok := <-p.jobs:
+			if !ok {
+				return
+			}
+			p.done <- p.run(j)
+		}
+	}
+}
+
+func (p *Pool[In, Out]) run(j job[In]) Result[In, Out] {
+	p.queued.Add(-1)
+	p.running.Add(1)
+	out, err := p.call(j.in)
+	p.running.Add(-1)
+	if err != nil {
+		p.failed.Add(1)
+	} else {
+		p.completed.Add(1)
+	}
+	return Result[In, Out]{Seq: j.seq, In: j.in, Out: out, Err: err}
+}
+
+// call 执行一个任务，把 panic 转换成 *PanicError
+func (p *Pool[In, Out]) call(in In) (out Out, err error) {
+	if err := p.ctx.Err(); err != nil {
+		return out, err
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return p.fn(p.ctx, in)
+}
+
+// collect 把 worker 的结果转发到 results，按顺序输出时先缓存提前完成的结果，跳过提交失败的 Seq
+func (p *Pool[In, Out]) collect() {
+	defer close(p.results)
+	if !p.ordered {
+		for r := range p.done {
+			p.results <- r
+		}
+		return
+	}
+
+	pending := make(map[int]Result[In, Out])
+	next := 0
+	for {
+		select {
+		case r, ok := <-p.done:
+			if !ok {
+				// 所有 Submit 都已经结束，剩下的空缺都在 skipped 中
+				p.flush(pending, &next)
+				return
+			}
+			pending[r.Seq] = r
+		case <-p.wake:
+		}
+		p.flush(pending, &next)
+	}
+}
+
+// flush 按顺序输出从 next 开始已经完成的结果
+func (p *Pool[In, Out]) flush(pending map[int]Result[In, Out], next *int) {
+	for {
+		if r, ok := pending[*next]; ok {
+			delete(pending, *next)
+			p.results <- r
+			*next++
+			continue
+		}
+		p.mu.Lock()
+		_, skip := p.skipped[*next]
+		delete(p.skipped, *next)
+		p.mu.Unlock()
+		if !skip {
+			return
+		}
+		*next++
+	}
+}
+
+// Map 用 workers 个 worker 处理 inputs，按顺序返回所有结果，失败的任务的错误合并成一个返回。
+func Map[In, Out any](ctx context.Context, inputs []In, workers int, fn func(ctx context.Context, in In) (Out, error)) ([]Out, error) {
+	p := New(ctx, fn, Options{Workers: workers, QueueSize: workers})
+	go func() {
+		for _, in := range inputs {
+			if p.Submit(ctx, in) != nil {
+				break
+			}
+		}
+		p.Close()
+	}()
+
+	outs := make([]Out, len(inputs))
+	var errs []error
+	n := 0
+	for r := range p.Results() {
+		outs[r.Seq] = r.Out
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("第 %d 个任务: %w", r.Seq, r.Err))
+		}
+		n++
+	}
+	if n < len(inputs) {
+		errs = append(errs, ctx.Err())
+	}
+	return outs, errors.Join(errs...)
+}
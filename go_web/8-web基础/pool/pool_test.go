@@ -0,0 +1,231 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitFor 在一秒内等待 cond 成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("一秒内没有等到%s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOrdered(t *testing.T) {
+	const n = 50
+	// 前面的任务执行得更久，不按顺序输出时结果基本是倒序的
+	p := New(context.Background(), func(_ context.Context, i int) (int, error) {
+		time.Sleep(time.Duration(n-i) * 100 * time.Microsecond)
+		return i * 2, nil
+	}, Options{Workers: 8, QueueSize: n, Ordered: true})
+	for i := range n {
+		if err := p.Submit(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Close()
+
+	next := 0
+	for r := range p.Results() {
+		if r.Seq != next || r.In != next || r.Out != next*2 || r.Err != nil {
+			t.Fatalf("第 %d 个结果是 %+v", next, r)
+		}
+		next++
+	}
+	if next != n {
+		t.Fatalf("收到 %d 个结果，应该是 %d 个", next, n)
+	}
+}
+
+// 按顺序输出时，提交失败的任务不能让后面的结果一直等下去
+func TestOrderedSkipsFailedSubmit(t *testing.T) {
+	gate := make(chan struct{})
+	p := New(context.Background(), func(_ context.Context, i int) (int, error) {
+		<-gate
+		return i, nil
+	}, Options{Workers: 1, Ordered: true})
+
+	if err := p.Submit(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Submit(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("worker 忙时 Submit 返回 %v，应该超时", err)
+	}
+	go p.Submit(context.Background(), 2)
+	close(gate)
+
+	for _, want := range []int{0, 2} {
+		select {
+		case r := <-p.Results():
+			if r.Seq != want {
+				t.Fatalf("收到 Seq %d，应该是 %d", r.Seq, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("一秒内没有收到 Seq %d", want)
+		}
+	}
+	p.Close()
+	if _, ok := <-p.Results(); ok {
+		t.Fatal("Close 之后还有多余的结果")
+	}
+}
+
+func TestResize(t *testing.T) {
+	gate := make(chan struct{})
+	p := New(context.Background(), func(_ context.Context, i int) (int, error) {
+		<-gate
+		return i, nil
+	}, Options{Workers: 1, QueueSize: 10})
+	for i := range 6 {
+		if err := p.Submit(context.Background(), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, " 1 个任务在执行", func() bool { return p.Stats().Running == 1 })
+
+	p.Resize(4)
+	waitFor(t, "扩容后 4 个任务同时执行", func() bool { return p.Stats().Running == 4 })
+	if st := p.Stats(); st.Workers != 4 || st.Queued != 2 {
+		t.Fatalf("扩容后统计是 %+v", st)
+	}
+
+	// 缩容不中断正在执行的任务
+	p.Resize(0)
+	if st := p.Stats(); st.Workers != 1 || st.Running != 4 {
+		t.Fatalf("缩容后统计是 %+v", st)
+	}
+	close(gate)
+	p.Close()
+	n := 0
+	for range p.Results() {
+		n++
+	}
+	if st := p.Stats(); n != 6 || st.Completed != 6 || st.Queued != 0 || st.Running != 0 {
+		t.Fatalf("收到 %d 个结果，统计是 %+v", n, p.Stats())
+	}
+
+	p.Resize(3) // Close 之后不起作用
+	if w := p.Stats().Workers; w != 1 {
+		t.Fatalf("Close 之后 Resize 把 worker 数改成了 %d", w)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	p := New(context.Background(), func(_ context.Context, i int) (int, error) {
+		if i == 2 {
+			panic("坏任务")
+		}
+		if i == 3 {
+			return 0, errors.New("失败")
+		}
+		return i, nil
+	}, Options{Workers: 1, QueueSize: 5, Ordered: true})
+	for i := range 5 {
+		p.Submit(context.Background(), i)
+	}
+	p.Close()
+
+	for r := range p.Results() {
+		var pe *PanicError
+		switch {
+		case r.In == 2:
+			if !errors.As(r.Err, &pe) || pe.Value != "坏任务" || len(pe.Stack) == 0 {
+				t.Fatalf("panic 的任务返回 %v", r.Err)
+			}
+		case r.In == 3:
+			if r.Err == nil || errors.As(r.Err, &pe) {
+				t.Fatalf("返回错误的任务得到 %v", r.Err)
+			}
+		case r.Err != nil || r.Out != r.In:
+			t.Fatalf("panic 影响了其他任务: %+v", r)
+		}
+	}
+	if st := p.Stats(); st.Completed != 3 || st.Failed != 2 {
+		t.Fatalf("统计是 %+v，应该有 3 个成功、2 个失败", st)
+	}
+}
+
+func TestBackpressure(t *testing.T) {
+	gate := make(chan struct{})
+	defer close(gate)
+	p := New(context.Background(), func(_ context.Context, i int) (int, error) {
+		<-gate
+		return i, nil
+	}, Options{Workers: 1, QueueSize: 1})
+
+	p.Submit(context.Background(), 0) // worker 在执行
+	waitFor(t, "worker 开始执行", func() bool { return p.Stats().Running == 1 })
+	if err := p.TrySubmit(1); err != nil { // 放进队列
+		t.Fatal(err)
+	}
+	if err := p.TrySubmit(2); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("队列满时 TrySubmit 返回 %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Submit(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("队列满时 Submit 返回 %v，应该一直等到超时", err)
+	}
+	if st := p.Stats(); st.Queued != 1 {
+		t.Fatalf("提交失败的任务不应该计入 Queued: %+v", st)
+	}
+}
+
+// 没有人读 Results、队列也满了的时候，Close 不能等 worker 腾出空位，阻塞的 Submit 返回 ErrClosed
+func TestCloseUnblocksSubmit(t *testing.T) {
+	p := New(context.Background(), func(_ context.Context, i int) (int, error) {
+		return i, nil
+	}, Options{Workers: 1, QueueSize: 1})
+	// worker 执行完的结果卡在 collect 上，之后队列很快就满了
+	submitted := 0
+	waitFor(t, "队列满", func() bool {
+		err := p.TrySubmit(submitted)
+		if err == nil {
+			submitted++
+		}
+		return errors.Is(err, ErrQueueFull)
+	})
+
+	blocked := make(chan error)
+	go func() { blocked <- p.Submit(context.Background(), -1) }()
+
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close 被等待队列空位的 Submit 阻塞")
+	}
+	select {
+	case err := <-blocked:
+		if !errors.Is(err, ErrClosed) {
+			t.Fatalf("Close 之后阻塞的 Submit 返回 %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close 之后 Submit 还在阻塞")
+	}
+	if err := p.Submit(context.Background(), -1); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Close 之后 Submit 返回 %v", err)
+	}
+
+	n := 0
+	for range p.Results() {
+		n++
+	}
+	if n != submitted {
+		t.Fatalf("收到 %d 个结果，应该是 Close 之前提交成功的 %d 个", n, submitted)
+	}
+}
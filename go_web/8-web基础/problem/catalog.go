@@ -0,0 +1,96 @@
+package problem
+
+import "net/http"
+
+type entry struct {
+	status int
+	text   map[string][2]string // 语言 -> {title, detail 格式}
+}
+
+// catalog 列出所有错误码对应的状态码和说明
+var catalog = map[Code]entry{
+	InvalidJSON: {http.StatusBadRequest, map[string][2]string{
+		"zh": {"无效的 JSON 数据", "请求体不是合法的 JSON"},
+		"en": {"Invalid JSON", "the request body is not valid JSON"},
+	}},
+	InvalidUserID: {http.StatusBadRequest, map[string][2]string{
+		"zh": {"无效的用户 ID", "%q 不是有效的用户 ID"},
+		"en": {"Invalid user ID", "%q is not a valid user ID"},
+	}},
+	InvalidParameter: {http.StatusBadRequest, map[string][2]string{
+		"zh": {"无效的查询参数", "参数 %s 必须是不小于 %d 的整数"},
+		"en": {"Invalid query parameter", "parameter %s must be an integer no less than %d"},
+	}},
+	ConflictingParameters: {http.StatusBadRequest, map[string][2]string{
+		"zh": {"查询参数冲突", "%s 和 %s 不能同时使用"},
+		"en": {"Conflicting query parameters", "%s and %s cannot be used together"},
+	}},
+	InvalidSort: {http.StatusBadRequest, map[string][2]string{
+		"zh": {"无效的排序参数", "不支持的排序 %q，可用字段为 id、name、age"},
+		"en": {"Invalid sort parameter", "unsupported sort %q, available fields are id, name, age"},
+	}},
+	InvalidCursor: {http.StatusBadRequest, map[string][2]string{
+		"zh": {"无效的游标", "ID 为 %d 的用户不在查询结果中"},
+		"en": {"Invalid cursor", "user %d is not part of the result set"},
+	}},
+	NotFound: {http.StatusNotFound, map[string][2]string{
+		"zh": {"资源不存在", "找不到 %s"},
+		"en": {"Not found", "%s was not found"},
+	}},
+	UserNotFound: {http.StatusNotFound, map[string][2]string{
+		"zh": {"用户不存在", "ID 为 %d 的用户不存在"},
+		"en": {"User not found", "user %d does not exist"},
+	}},
+	MethodNotAllowed: {http.StatusMethodNotAllowed, map[string][2]string{
+		"zh": {"方法不允许", "%s 不支持 %s 方法"},
+		"en": {"Method not allowed", "%s does not support method %s"},
+	}},
+	IDConflict: {http.StatusConflict, map[string][2]string{
+		"zh": {"ID 冲突", "请求体中的 ID %d 与 URL 中的 %d 不一致"},
+		"en": {"ID conflict", "ID %d in the body does not match %d in the URL"},
+	}},
+	BodyTooLarge: {http.StatusRequestEntityTooLarge, map[string][2]string{
+		"zh": {"请求体过大", "请求体不能超过 %d 字节"},
+		"en": {"Request body too large", "the request body must not exceed %d bytes"},
+	}},
+	UnsupportedMediaType: {http.StatusUnsupportedMediaType, map[string][2]string{
+		"zh": {"不支持的媒体类型", "%s 只支持 %s"},
+		"en": {"Unsupported media type", "%s only accepts %s"},
+	}},
+	ValidationFailed: {http.StatusUnprocessableEntity, map[string][2]string{
+		"zh": {"请求数据校验失败", "有 %d 个字段没有通过校验"},
+		"en": {"Validation failed", "%d field(s) failed validation"},
+	}},
+	AuthRequired: {http.StatusUnauthorized, map[string][2]string{
+		"zh": {"需要认证", "修改数据需要先登录，或者携带 API key"},
+		"en": {"Authentication required", "log in or provide an API key to modify data"},
+	}},
+	InvalidCredentials: {http.StatusUnauthorized, map[string][2]string{
+		"zh": {"登录失败", "用户名或密码错误"},
+		"en": {"Login failed", "incorrect username or password"},
+	}},
+	InvalidToken: {http.StatusUnauthorized, map[string][2]string{
+		"zh": {"令牌无效", "令牌格式错误、签名不正确或用途不对"},
+		"en": {"Invalid token", "the token is malformed, has a bad signature or is used for the wrong purpose"},
+	}},
+	TokenExpired: {http.StatusUnauthorized, map[string][2]string{
+		"zh": {"令牌已过期", "请使用刷新令牌换取新的访问令牌，或重新登录"},
+		"en": {"Token expired", "use the refresh token to obtain a new access token, or log in again"},
+	}},
+	InvalidAPIKey: {http.StatusUnauthorized, map[string][2]string{
+		"zh": {"API key 无效", "X-API-Key 请求头中的 key 不存在"},
+		"en": {"Invalid API key", "the key in the X-API-Key header is not recognized"},
+	}},
+	Forbidden: {http.StatusForbidden, map[string][2]string{
+		"zh": {"权限不足", "缺少权限 %s"},
+		"en": {"Forbidden", "missing permission %s"},
+	}},
+	RateLimited: {http.StatusTooManyRequests, map[string][2]string{
+		"zh": {"请求过于频繁", "每 %d 秒最多 %d 个请求，请在 %d 秒后重试"},
+		"en": {"Too many requests", "at most %[2]d requests per %[1]d seconds, retry in %[3]d seconds"},
+	}},
+	Internal: {http.StatusInternalServerError, map[string][2]string{
+		"zh": {"服务器内部错误", "处理请求时出错，请稍后重试"},
+		"en": {"Internal server error", "an error occurred while handling the request, please try again later"},
+	}},
+}
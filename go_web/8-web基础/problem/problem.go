@@ -0,0 +1,159 @@
+// problem 包按照 RFC 7807 (application/problem+json) 输出统一格式的错误响应。
+//
+// 每种错误有一个稳定的错误码 Code，客户端应该根据 code 判断错误类型，
+// title 和 detail 只是给人看的说明，会根据请求的 Accept-Language 选择中文或英文。
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"example.com/web/validate"
+)
+
+// Code 是机器可读的错误码，一旦发布就不再修改。
+type Code string
+
+const (
+	InvalidJSON           Code = "invalid_json"
+	InvalidUserID         Code = "invalid_user_id"
+	InvalidParameter      Code = "invalid_parameter"
+	ConflictingParameters Code = "conflicting_parameters"
+	InvalidSort           Code = "invalid_sort"
+	InvalidCursor         Code = "invalid_cursor"
+	NotFound              Code = "not_found"
+	UserNotFound          Code = "user_not_found"
+	MethodNotAllowed      Code = "method_not_allowed"
+	IDConflict            Code = "id_conflict"
+	BodyTooLarge          Code = "body_too_large"
+	UnsupportedMediaType  Code = "unsupported_media_type"
+	ValidationFailed      Code = "validation_failed"
+	AuthRequired          Code = "authentication_required"
+	InvalidCredentials    Code = "invalid_credentials"
+	InvalidToken          Code = "invalid_token"
+	TokenExpired          Code = "token_expired"
+	InvalidAPIKey         Code = "invalid_api_key"
+	Forbidden             Code = "forbidden"
+	RateLimited           Code = "rate_limited"
+	Internal              Code = "internal_error"
+)
+
+// Problem 是响应体的结构。
+type Problem struct {
+	Type     string                `json:"type"`
+	Title    string                `json:"title"`
+	Status   int                   `json:"status"`
+	Detail   string                `json:"detail,omitempty"`
+	Instance string                `json:"instance,omitempty"`
+	Code     Code                  `json:"code"`
+	Errors   []validate.FieldError `json:"errors,omitempty"` // 字段校验错误，只在 validation_failed 时出现
+	// Permission 是缺少的权限，只在 forbidden 时出现
+	Permission string `json:"permission,omitempty"`
+}
+
+// Error 是还没有输出的错误：只记录错误码和参数，输出时再根据语言生成说明。
+// 它实现了 error 接口，可以在函数之间像普通错误一样返回。
+type Error struct {
+	Code   Code
+	Args   []any
+	Fields validate.Errors
+}
+
+// New 创建一个错误，args 用来填充说明中的占位符。
+func New(code Code, args ...any) *Error {
+	return &Error{Code: code, Args: args}
+}
+
+// Codes 按字母顺序返回所有错误码，用于生成文档。
+func Codes() []Code {
+	return slices.Sorted(maps.Keys(catalog))
+}
+
+func (e *Error) Error() string {
+	return e.Problem(DefaultLang).Detail
+}
+
+// Problem 生成 lang 语言的响应体，Instance 由 Write 填写。
+func (e *Error) Problem(lang string) *Problem {
+	entry, ok := catalog[e.Code]
+	if !ok {
+		entry = catalog[Internal]
+	}
+	text, ok := entry.text[lang]
+	if !ok {
+		text = entry.text[DefaultLang]
+	}
+	p := &Problem{
+		Type:   "/problems/" + string(e.Code),
+		Title:  text[0],
+		Status: entry.status,
+		Detail: fmt.Sprintf(text[1], e.Args...),
+		Code:   e.Code,
+	}
+	if len(e.Fields) > 0 {
+		p.Errors = e.Fields.Localize(lang)
+	}
+	if e.Code == Forbidden && len(e.Args) > 0 {
+		p.Permission = fmt.Sprint(e.Args[0])
+	}
+	return p
+}
+
+// Write 输出错误码为 code 的错误响应。
+func Write(w http.ResponseWriter, r *http.Request, code Code, args ...any) {
+	WriteError(w, r, New(code, args...))
+}
+
+// WriteError 输出任意错误：*Error 原样输出，validate.Errors 输出为 validation_failed，
+// 其他错误都当作服务器内部错误，不把内部细节暴露给客户端。
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var pe *Error
+	var fields validate.Errors
+	switch {
+	case errors.As(err, &pe):
+	case errors.As(err, &fields):
+		pe = &Error{Code: ValidationFailed, Args: []any{len(fields)}, Fields: fields}
+	default:
+		pe = New(Internal)
+	}
+
+	lang := Language(r)
+	p := pe.Problem(lang)
+	p.Instance = r.URL.Path
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// DefaultLang 是默认语言，Accept-Language 中没有支持的语言时使用。
+const DefaultLang = "zh"
+
+// Language 根据 Accept-Language 头选择 zh 或 en，按 q 值取权重最高的一个。
+func Language(r *http.Request) string {
+	best, bestQ := DefaultLang, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if _, ok := supported[primary]; ok && q > bestQ {
+			best, bestQ = primary, q
+		}
+	}
+	return best
+}
+
+var supported = map[string]bool{"zh": true, "en": true}
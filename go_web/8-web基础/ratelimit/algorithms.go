@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 三种算法的状态都编码成空格分隔的数字，方便在 Redis 中查看。
+// 不同算法的 key 加上不同的前缀，共用一个 Store 也不会互相覆盖。
+
+// TokenBucket 是令牌桶：每个窗口放入 limit 个令牌，桶里最多 burst 个。
+type TokenBucket struct {
+	store  Store
+	limit  int
+	window time.Duration
+	burst  int
+	now    func() time.Time
+}
+
+// NewTokenBucket 创建令牌桶，平均每 window 允许 limit 个请求，最多连续 burst 个，burst <= 0 时等于 limit。
+func NewTokenBucket(store Store, limit int, window time.Duration, burst int) *TokenBucket {
+	checkLimit(limit, window)
+	if burst <= 0 {
+		burst = limit
+	}
+	return &TokenBucket{store: store, limit: limit, window: window, burst: burst, now: time.Now}
+}
+
+func (b *TokenBucket) Allow(ctx context.Context, key string) (Result, error) {
+	rate := float64(b.limit) / float64(b.window) // 每纳秒放入的令牌数
+	var res Result
+	// 状态：剩余令牌数（可以是小数）和上次更新的时间
+	err := b.store.Update(ctx, "tb:"+key, func(state []byte) ([]byte, time.Duration) {
+		now := b.now()
+		tokens := float64(b.burst)
+		if f := strings.Fields(string(state)); len(f) == 2 {
+			t, err1 := strconv.ParseFloat(f[0], 64)
+			last, err2 := strconv.ParseInt(f[1], 10, 64)
+			if err1 == nil && err2 == nil {
+				elapsed := max(float64(now.UnixNano()-last), 0) // 多个进程的时钟可能不完全一致
+				tokens = min(t+elapsed*rate, float64(b.burst))
+			}
+		}
+
+		res = Result{Limit: b.limit, Window: b.window}
+		if tokens >= 1 {
+			tokens--
+			res.Allowed = true
+		} else {
+			res.RetryAfter = nanos((1 - tokens) / rate)
+		}
+		res.Remaining = int(tokens)
+		res.Reset = nanos((float64(b.burst) - tokens) / rate)
+		// 桶满之后的状态和没有状态一样，可以过期删除
+		return fmt.Appendf(nil, "%g %d", tokens, now.UnixNano()), max(res.Reset, time.Millisecond)
+	})
+	return res, err
+}
+
+// SlidingLog 是滑动窗口日志：任意 window 长的时间内最多 limit 个请求。
+type SlidingLog struct {
+	store  Store
+	limit  int
+	window time.Duration
+	now    func() time.Time
+}
+
+// NewSlidingLog 创建滑动窗口日志限流器，每个 key 最多记录 limit 个时间。
+func NewSlidingLog(store Store, limit int, window time.Duration) *SlidingLog {
+	checkLimit(limit, window)
+	return &SlidingLog{store: store, limit: limit, window: window, now: time.Now}
+}
+
+func (l *SlidingLog) Allow(ctx context.Context, key string) (Result, error) {
+	var res Result
+	// 状态：窗口内每个请求的时间，从早到晚
+	err := l.store.Update(ctx, "sl:"+key, func(state []byte) ([]byte, time.Duration) {
+		now := l.now()
+		cutoff := now.Add(-l.window).UnixNano()
+		var log []int64
+		for _, f := range strings.Fields(string(state)) {
+			if t, err := strconv.ParseInt(f, 10, 64); err == nil && t > cutoff {
+				log = append(log, t)
+			}
+		}
+
+		res = Result{Limit: l.limit, Window: l.window}
+		if len(log) < l.limit {
+			log = append(log, now.UnixNano())
+			res.Allowed = true
+		} else {
+			res.RetryAfter = time.Duration(log[0] - cutoff) // 最早的请求移出窗口之后
+		}
+		res.Remaining = l.limit - len(log)
+		res.Reset = time.Duration(log[len(log)-1] - cutoff)
+
+		var b []byte
+		for i, t := range log {
+			if i > 0 {
+				b = append(b, ' ')
+			}
+			b = strconv.AppendInt(b, t, 10)
+		}
+		return b, l.window
+	})
+	return res, err
+}
+
+// FixedWindow 是固定窗口计数器：时间按 window 切成一段一段，每段最多 limit 个请求。
+type FixedWindow struct {
+	store  Store
+	limit  int
+	window time.Duration
+	now    func() time.Time
+}
+
+// NewFixedWindow 创建固定窗口限流器。
+func NewFixedWindow(store Store, limit int, window time.Duration) *FixedWindow {
+	checkLimit(limit, window)
+	return &FixedWindow{store: store, limit: limit, window: window, now: time.Now}
+}
+
+func (w *FixedWindow) Allow(ctx context.Context, key string) (Result, error) {
+	var res Result
+	// 状态：当前窗口的开始时间和其中的请求数
+	err := w.store.Update(ctx, "fw:"+key, func(state []byte) ([]byte, time.Duration) {
+		now := w.now()
+		start := now.Truncate(w.window)
+		count := 0
+		if f := strings.Fields(string(state)); len(f) == 2 && f[0] == strconv.FormatInt(start.UnixNano(), 10) {
+			count, _ = strconv.Atoi(f[1])
+		}
+
+		res = Result{Limit: w.limit, Window: w.window, Reset: start.Add(w.window).Sub(now)}
+		if count < w.limit {
+			count++
+			res.Allowed = true
+		} else {
+			res.RetryAfter = res.Reset
+		}
+		res.Remaining = w.limit - count
+		return fmt.Appendf(nil, "%d %d", start.UnixNano(), count), res.Reset
+	})
+	return res, err
+}
+
+func checkLimit(limit int, window time.Duration) {
+	if limit <= 0 || window <= 0 {
+		panic("ratelimit: limit 和 window 必须大于 0")
+	}
+}
+
+// nanos 把纳秒数转换成 Duration，向上取整
+func nanos(f float64) time.Duration {
+	return time.Duration(math.Ceil(f))
+}
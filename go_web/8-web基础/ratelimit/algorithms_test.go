@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// clock 是测试用的时钟，只有 advance 时才前进
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// step 是一次请求：先把时钟拨快 after，再检查结果
+type step struct {
+	after      time.Duration
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+	reset      time.Duration // 为 0 时不检查
+}
+
+func runSteps(t *testing.T, l Limiter, c *clock, steps []step) {
+	t.Helper()
+	for i, s := range steps {
+		c.advance(s.after)
+		res, err := l.Allow(context.Background(), "client")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != s.allowed || res.Remaining != s.remaining || res.RetryAfter != s.retryAfter ||
+			s.reset != 0 && res.Reset != s.reset {
+			t.Fatalf("第 %d 个请求（+%v）: 得到 %+v，应该是 %+v", i, s.after, res, s)
+		}
+	}
+}
+
+// 起点对齐到 10 秒，固定窗口的边界容易计算
+var epoch = time.Unix(1_700_000_000, 0)
+
+func TestTokenBucket(t *testing.T) {
+	c := &clock{t: epoch}
+	b := NewTokenBucket(NewMemoryStore(), 10, 10*time.Second, 3) // 每秒一个令牌，最多攒 3 个
+	b.now = c.now
+	runSteps(t, b, c, []step{
+		{allowed: true, remaining: 2, reset: time.Second},
+		{allowed: true, remaining: 1},
+		{allowed: true, remaining: 0, reset: 3 * time.Second},
+		{allowed: false, remaining: 0, retryAfter: time.Second},
+		{after: 500 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond},
+		{after: 500 * time.Millisecond, allowed: true, remaining: 0},
+		// 很久没有请求，令牌最多攒到 burst 个
+		{after: time.Minute, allowed: true, remaining: 2},
+	})
+	if r, _ := b.Allow(context.Background(), "other"); !r.Allowed || r.Remaining != 2 {
+		t.Fatalf("不同的 key 应该各自计数，得到 %+v", r)
+	}
+}
+
+func TestSlidingLog(t *testing.T) {
+	c := &clock{t: epoch}
+	l := NewSlidingLog(NewMemoryStore(), 2, 10*time.Second)
+	l.now = c.now
+	runSteps(t, l, c, []step{
+		{allowed: true, remaining: 1, reset: 10 * time.Second},
+		{after: 4 * time.Second, allowed: true, remaining: 0, reset: 10 * time.Second},
+		// 第一个请求在 5 秒后移出窗口
+		{after: time.Second, allowed: false, remaining: 0, retryAfter: 5 * time.Second, reset: 9 * time.Second},
+		{after: 5 * time.Second, allowed: true, remaining: 0},
+		// 被拒绝的请求不记录，第二个请求移出窗口之后又可以了
+		{after: 4 * time.Second, allowed: true, remaining: 0},
+		{after: time.Second, allowed: false, remaining: 0, retryAfter: 5 * time.Second},
+	})
+}
+
+func TestFixedWindow(t *testing.T) {
+	c := &clock{t: epoch.Add(8 * time.Second)}
+	w := NewFixedWindow(NewMemoryStore(), 2, 10*time.Second)
+	w.now = c.now
+	runSteps(t, w, c, []step{
+		{allowed: true, remaining: 1, reset: 2 * time.Second},
+		{allowed: true, remaining: 0},
+		{after: time.Second, allowed: false, remaining: 0, retryAfter: time.Second, reset: time.Second},
+		// 新窗口重新计数：窗口交界处的 2 秒内放过了两倍的请求
+		{after: time.Second, allowed: true, remaining: 1, reset: 10 * time.Second},
+		{after: 9999 * time.Millisecond, allowed: true, remaining: 0, reset: time.Millisecond},
+		{allowed: false, remaining: 0, retryAfter: time.Millisecond},
+	})
+}
+
+func TestCheckLimit(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("limit 为 0 时应该 panic")
+		}
+	}()
+	NewFixedWindow(NewMemoryStore(), 0, time.Second)
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore 把状态保存在当前进程中，多个服务器进程各自计数。
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	updates int // 每隔一段时间清理一次过期的状态，避免不再访问的客户端一直占用内存
+}
+
+type memoryEntry struct {
+	state    []byte
+	expireAt time.Time
+}
+
+// 每多少次 Update 清理一次过期的状态
+const sweepEvery = 1024
+
+// NewMemoryStore 创建内存存储。
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (m *MemoryStore) Update(ctx context.Context, key string, fn func([]byte) ([]byte, time.Duration)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var state []byte
+	if e, ok := m.entries[key]; ok && now.Before(e.expireAt) {
+		state = e.state
+	}
+	next, ttl := fn(state)
+	m.entries[key] = memoryEntry{state: next, expireAt: now.Add(ttl)}
+
+	if m.updates++; m.updates >= sweepEvery {
+		m.updates = 0
+		for k, e := range m.entries {
+			if !now.Before(e.expireAt) {
+				delete(m.entries, k)
+			}
+		}
+	}
+	return nil
+}
+
+// Len 返回保存的状态数，包括还没有清理的过期状态
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	incr := func(key string, ttl time.Duration) string {
+		var got string
+		m.Update(ctx, key, func(state []byte) ([]byte, time.Duration) {
+			n, _ := strconv.Atoi(string(state))
+			got = strconv.Itoa(n + 1)
+			return []byte(got), ttl
+		})
+		return got
+	}
+
+	if incr("a", time.Minute) != "1" || incr("a", time.Minute) != "2" {
+		t.Fatal("Update 没有读到上次保存的状态")
+	}
+	incr("b", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if got := incr("b", time.Millisecond); got != "1" {
+		t.Fatalf("过期的状态应该当作不存在，得到 %s", got)
+	}
+
+	// 过期的状态在每 sweepEvery 次 Update 时清理
+	for i := range 100 {
+		incr("expired"+strconv.Itoa(i), time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if n := m.Len(); n != 102 {
+		t.Fatalf("清理之前有 %d 个状态，应该是 102 个", n)
+	}
+	for range sweepEvery {
+		incr("a", time.Minute)
+	}
+	if n := m.Len(); n != 1 {
+		t.Fatalf("清理之后有 %d 个状态，应该只剩 a", n)
+	}
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/web/middleware"
+)
+
+// Middleware 按 key(r) 分别限制每个客户端的请求速率。
+//
+// 每个响应都带上 RateLimit-Limit、RateLimit-Remaining、RateLimit-Reset 和 RateLimit-Policy 头
+// (draft-ietf-httpapi-ratelimit-headers)；超过限额时再设置 Retry-After，然后调用 onLimited 输出响应，通常是 429。
+// 存储出错时（例如 Redis 不可用）放行请求并记录日志，限流出问题不应该让整个服务不可用；
+// 但 ErrContended 说明同一个客户端的并发请求多到更新一直冲突，按超过限额处理。
+func Middleware(l Limiter, key func(r *http.Request) string, onLimited func(w http.ResponseWriter, r *http.Request, res Result)) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := l.Allow(r.Context(), key(r))
+			switch {
+			case errors.Is(err, ErrContended):
+				res.Allowed, res.Remaining = false, 0
+			case err != nil:
+				slog.WarnContext(r.Context(), "限流检查失败，放行请求", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+			h.Set("RateLimit-Policy", strconv.Itoa(res.Limit)+";w="+strconv.Itoa(seconds(res.Window)))
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(max(seconds(res.RetryAfter), 1)))
+				onLimited(w, r, res)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// 响应头中的时间都是整秒，向上取整，客户端按这个时间重试时一定已经可以了
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// ClientIP 返回客户端的 IP。
+// trustProxy 为 true 时表示服务器在一层反向代理后面，使用代理追加在 X-Forwarded-For 最后的地址；
+// 前面的地址是客户端自己填的，不能用来限流。
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+			last := xff[len(xff)-1]
+			if i := strings.LastIndex(last, ","); i >= 0 {
+				last = last[i+1:]
+			}
+			if ip := strings.TrimSpace(last); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// limiterFunc 把函数当作 Limiter，模拟存储出错
+type limiterFunc func(ctx context.Context, key string) (Result, error)
+
+func (f limiterFunc) Allow(ctx context.Context, key string) (Result, error) { return f(ctx, key) }
+
+func serve(l Limiter) *httptest.ResponseRecorder {
+	h := Middleware(l, func(r *http.Request) string { return ClientIP(r, false) },
+		func(w http.ResponseWriter, r *http.Request, res Result) { w.WriteHeader(http.StatusTooManyRequests) },
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	c := &clock{t: epoch.Add(10*time.Second + 500*time.Millisecond)} // 离下一个整分钟 29.5 秒，向上取整是 30
+	w := NewFixedWindow(NewMemoryStore(), 1, time.Minute)
+	w.now = c.now
+
+	tests := []struct {
+		code    int
+		headers map[string]string
+	}{
+		{http.StatusOK, map[string]string{
+			"RateLimit-Limit": "1", "RateLimit-Remaining": "0", "RateLimit-Reset": "30",
+			"RateLimit-Policy": "1;w=60", "Retry-After": "",
+		}},
+		{http.StatusTooManyRequests, map[string]string{
+			"RateLimit-Limit": "1", "RateLimit-Remaining": "0", "RateLimit-Reset": "30",
+			"RateLimit-Policy": "1;w=60", "Retry-After": "30",
+		}},
+	}
+	for i, tt := range tests {
+		rec := serve(w)
+		if rec.Code != tt.code {
+			t.Fatalf("第 %d 个请求返回 %d，应该是 %d", i, rec.Code, tt.code)
+		}
+		for k, v := range tt.headers {
+			if got := rec.Header().Get(k); got != v {
+				t.Errorf("第 %d 个请求的 %s 是 %q，应该是 %q", i, k, got, v)
+			}
+		}
+	}
+}
+
+func TestMiddlewareErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		res        Result
+		err        error
+		code       int
+		retryAfter string
+	}{
+		// 存储出错时放行，不输出限流头
+		{"存储出错", Result{}, errors.New("连接被拒绝"), http.StatusOK, ""},
+		// 冲突太多按超过限额处理；不足一秒的等待时间向上取整，至少是 1 秒
+		{"冲突", Result{Allowed: true, Limit: 5, Remaining: 3, Window: time.Second}, ErrContended, http.StatusTooManyRequests, "1"},
+		{"重试时间向上取整", Result{Limit: 5, Window: time.Minute, RetryAfter: 1500 * time.Millisecond}, nil, http.StatusTooManyRequests, "2"},
+	}
+	for _, tt := range tests {
+		rec := serve(limiterFunc(func(context.Context, string) (Result, error) { return tt.res, tt.err }))
+		if rec.Code != tt.code || rec.Header().Get("Retry-After") != tt.retryAfter {
+			t.Errorf("%s: 返回 %d，Retry-After 是 %q", tt.name, rec.Code, rec.Header().Get("Retry-After"))
+		}
+		_, hasLimit := rec.Header()["Ratelimit-Limit"]
+		if hasLimit != (tt.err == nil || errors.Is(tt.err, ErrContended)) {
+			t.Errorf("%s: 响应头是 %v", tt.name, rec.Header())
+		}
+		if errors.Is(tt.err, ErrContended) && rec.Header().Get("RateLimit-Remaining") != "0" {
+			t.Errorf("%s: RateLimit-Remaining 是 %q，应该是 0", tt.name, rec.Header().Get("RateLimit-Remaining"))
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		xff        []string
+		trust      bool
+		want       string
+	}{
+		{"10.0.0.1:1234", nil, false, "10.0.0.1"},
+		{"[::1]:1234", nil, false, "::1"},
+		{"10.0.0.1", nil, false, "10.0.0.1"}, // 没有端口时原样返回
+		// 不信任代理时忽略客户端可以伪造的 X-Forwarded-For
+		{"10.0.0.1:1234", []string{"1.2.3.4"}, false, "10.0.0.1"},
+		{"10.0.0.1:1234", []string{"1.2.3.4"}, true, "1.2.3.4"},
+		// 只用代理追加的最后一个地址
+		{"10.0.0.1:1234", []string{"6.6.6.6, 1.2.3.4"}, true, "1.2.3.4"},
+		{"10.0.0.1:1234", []string{"6.6.6.6", "1.2.3.4 "}, true, "1.2.3.4"},
+		{"10.0.0.1:1234", []string{"1.2.3.4, "}, true, "10.0.0.1"},
+		{"10.0.0.1:1234", []string{""}, true, "10.0.0.1"},
+		{"10.0.0.1:1234", nil, true, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		for _, v := range tt.xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if got := ClientIP(r, tt.trust); got != tt.want {
+			t.Errorf("ClientIP(%q, X-Forwarded-For %q, trust=%v) = %q，应该是 %q", tt.remoteAddr, tt.xff, tt.trust, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// ratelimit 包按客户端限制请求速率，把 go_example/35-速率限制 中打点器和缓冲通道的思路
+// 做成可以复用的限流器，提供三种算法：
+//
+//   - 令牌桶 (TokenBucket)：按固定速率往桶里放令牌，每个请求取走一个，桶的容量就是允许的突发请求数。
+//     和 35-速率限制 中的 burstyLimiter 一样，只是令牌数按时间计算出来，不需要后台协程。
+//   - 滑动窗口日志 (SlidingLog)：记录窗口内每个请求的时间，最精确，占用的空间和限额成正比。
+//   - 固定窗口 (FixedWindow)：每个窗口一个计数器，最省空间，但窗口交界处最多会放过两倍的请求。
+//
+// 限流器的状态保存在 Store 中：MemoryStore 只在当前进程内有效，RedisStore 让多个服务器进程共用限额。
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Result 是一次检查的结果，Middleware 据此设置响应头
+type Result struct {
+	Allowed    bool
+	Limit      int           // 每个窗口的限额
+	Window     time.Duration // 窗口长度
+	Remaining  int           // 这次请求之后还剩多少
+	Reset      time.Duration // 多久之后限额完全恢复
+	RetryAfter time.Duration // 被拒绝时，多久之后可以重试
+}
+
+// Limiter 检查 key 对应的客户端能否再发一个请求，允许时同时计入这个请求
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// Store 保存每个 key 的限流状态。
+type Store interface {
+	// Update 原子地读出 key 的状态交给 fn，再保存 fn 返回的新状态，ttl 之后新状态过期。
+	// 状态不存在或已过期时 fn 收到 nil。fn 可能因为并发冲突被调用多次，只能根据 state 计算，
+	// 最后一次调用的结果被保存。
+	Update(ctx context.Context, key string, fn func(state []byte) (next []byte, ttl time.Duration)) error
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"example.com/database"
+)
+
+// ErrContended 表示同一个 key 的并发更新冲突太多，重试 MaxRetries 次都没有成功。
+// 这时 Limiter 返回的 Result 是最后一次计算的结果。
+var ErrContended = errors.New("ratelimit: 并发更新冲突太多")
+
+// RedisStore 把状态保存在 Redis 中，多个服务器进程共用同一份限额。
+//
+// 读出、计算、写回需要是原子的。这里不依赖 Lua 脚本（9-数据库 中的 redisserver 不支持），
+// 而是用乐观事务：WATCH key 并读出状态，再在 MULTI/EXEC 中写入新状态；
+// 其间别的进程修改了这个 key，EXEC 就不会执行，重新读取、计算。
+// 没有锁，进程崩溃不会让 key 卡住，每次 Update 在没有冲突时需要三次网络往返。
+type RedisStore struct {
+	r      *database.Redis
+	prefix string
+
+	// MaxRetries 是 EXEC 因为冲突失败后最多重试的次数
+	MaxRetries int
+}
+
+// NewRedisStore 使用 9-数据库 中的 Redis 客户端，所有 key 加上 prefix。客户端由调用方关闭。
+func NewRedisStore(r *database.Redis, prefix string) *RedisStore {
+	return &RedisStore{r: r, prefix: prefix, MaxRetries: 20}
+}
+
+func (s *RedisStore) Update(ctx context.Context, key string, fn func([]byte) ([]byte, time.Duration)) error {
+	key = s.prefix + key
+	for attempt := 0; ; attempt++ {
+		err := s.r.Watch(ctx, func(tx *database.Tx) error {
+			v, err := tx.Do(ctx, "GET", key)
+			if err != nil {
+				return err
+			}
+			var state []byte
+			if !v.Nil() {
+				state = []byte(v.Str)
+			}
+			next, ttl := fn(state)
+			_, err = tx.Exec(ctx, []any{"SET", key, next, "PX", max(ttl.Milliseconds(), 1)})
+			return err
+		}, key)
+		if !errors.Is(err, database.ErrTxFailed) {
+			return err
+		}
+		if attempt == s.MaxRetries {
+			return ErrContended
+		}
+
+		// 随机等一会儿再重试，错开同时冲突的请求
+		select {
+		case <-time.After(time.Duration(rand.Int64N(int64(attempt+1) * int64(time.Millisecond)))):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
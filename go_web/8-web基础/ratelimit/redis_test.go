@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/database"
+	"example.com/database/redisserver"
+)
+
+func newTestRedisStore(t *testing.T) (*RedisStore, *redisserver.Server) {
+	t.Helper()
+	srv, err := redisserver.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	r := database.NewRedis(database.RedisOptions{Addr: srv.Addr()})
+	t.Cleanup(func() { r.Close() })
+	return NewRedisStore(r, "test:"), srv
+}
+
+// incr 把状态当作十进制计数加一
+func incr(state []byte) ([]byte, time.Duration) {
+	n, _ := strconv.Atoi(string(state))
+	return []byte(strconv.Itoa(n + 1)), time.Minute
+}
+
+func TestRedisStore(t *testing.T) {
+	s, srv := newTestRedisStore(t)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			if err := s.Update(ctx, "k", incr); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if v := srv.Do("GET", "test:k"); v.Str != "50" {
+		t.Fatalf("50 次并发更新之后是 %q，有更新丢失了", v.Str)
+	}
+	if v := srv.Do("PTTL", "test:k"); v.Int <= 0 || v.Int > time.Minute.Milliseconds() {
+		t.Fatalf("PTTL 是 %d", v.Int)
+	}
+}
+
+// fn 执行期间别的进程改了 key，EXEC 失败，重新读取、计算
+func TestRedisStoreRetry(t *testing.T) {
+	tests := []struct {
+		maxRetries int
+		conflicts  int
+		calls      int
+		err        error
+		want       string
+	}{
+		{maxRetries: 2, conflicts: 0, calls: 1, want: "1"},
+		{maxRetries: 2, conflicts: 2, calls: 3, want: "3"},
+		{maxRetries: 2, conflicts: 3, calls: 3, err: ErrContended, want: "3"},
+		{maxRetries: 0, conflicts: 1, calls: 1, err: ErrContended, want: "1"},
+	}
+	for _, tt := range tests {
+		s, srv := newTestRedisStore(t)
+		s.MaxRetries = tt.maxRetries
+		calls := 0
+		err := s.Update(context.Background(), "k", func(state []byte) ([]byte, time.Duration) {
+			calls++
+			if calls <= tt.conflicts {
+				srv.Do("INCR", "test:k")
+			}
+			return incr(state)
+		})
+		if !errors.Is(err, tt.err) || calls != tt.calls {
+			t.Errorf("MaxRetries %d、冲突 %d 次: 调用 fn %d 次，返回 %v", tt.maxRetries, tt.conflicts, calls, err)
+		}
+		// 冲突的写入保留下来，失败的 EXEC 没有写入
+		if v := srv.Do("GET", "test:k"); v.Str != tt.want {
+			t.Errorf("MaxRetries %d、冲突 %d 次: 最后的值是 %q，应该是 %q", tt.maxRetries, tt.conflicts, v.Str, tt.want)
+		}
+	}
+}
+
+func TestRedisStoreLimiter(t *testing.T) {
+	s, _ := newTestRedisStore(t)
+	l := NewSlidingLog(s, 2, time.Minute)
+	for i, want := range []bool{true, true, false} {
+		res, err := l.Allow(context.Background(), "client")
+		if err != nil || res.Allowed != want {
+			t.Fatalf("第 %d 个请求返回 %+v, %v", i, res, err)
+		}
+	}
+}
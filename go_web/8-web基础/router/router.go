@@ -0,0 +1,152 @@
+// router 包在 Go 1.22 的 http.ServeMux 路由模式之上补充了几项功能：
+//
+//   - 按方法注册处理函数，路径参数用 r.PathValue("id") 读取
+//   - 路由分组，组内的路径自动加上前缀
+//   - 方法不匹配时返回 405 并带上 Allow 头
+//   - 自动处理 HEAD（使用 GET 的处理函数）和 OPTIONS（返回 Allow 头）
+//   - 路由匹配之后执行的中间件，其中可以使用 r.Pattern 和 r.PathValue
+//   - 记录所有路由，方便生成文档
+package router
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Route 描述一条已注册的路由。
+type Route struct {
+	Method  string
+	Pattern string // 完整路径，例如 /users/{id}
+	Handler http.Handler
+}
+
+// Router 是一个路由器，同时也是 http.Handler。
+type Router struct {
+	*shared
+	prefix string
+	mws    []func(http.Handler) http.Handler
+}
+
+// 分组之间共享的状态
+type shared struct {
+	mux    *http.ServeMux
+	paths  map[string]map[string]http.Handler // 路径 -> 方法 -> 处理函数
+	routes []Route
+
+	// NotFound 处理没有匹配任何路径的请求，默认为 http.NotFound。
+	NotFound http.Handler
+	// MethodNotAllowed 处理路径匹配但方法不匹配的请求，调用前已经设置好 Allow 头。
+	MethodNotAllowed http.Handler
+}
+
+// New 创建路由器。
+func New() *Router {
+	s := &shared{
+		mux:   http.NewServeMux(),
+		paths: make(map[string]map[string]http.Handler),
+		MethodNotAllowed: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}),
+	}
+	// "/" 匹配所有其他模式都不匹配的请求
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if s.NotFound != nil {
+			s.NotFound.ServeHTTP(w, r)
+			return
+		}
+		http.NotFound(w, r)
+	})
+	return &Router{shared: s}
+}
+
+// Handle 为 method 和 path 注册处理函数，path 使用 ServeMux 的模式语法，例如 /users/{id}。
+// 精确匹配根路径请写 "/{$}"。
+func (rt *Router) Handle(method, path string, h http.Handler) {
+	pattern := rt.prefix + path
+	if pattern == "" {
+		pattern = "/"
+	}
+	methods, ok := rt.paths[pattern]
+	if !ok {
+		methods = make(map[string]http.Handler)
+		rt.paths[pattern] = methods
+		rt.mux.Handle(pattern, rt.dispatch(methods))
+	}
+	if _, dup := methods[method]; dup {
+		panic("router: 重复注册路由 " + method + " " + pattern)
+	}
+	for i := len(rt.mws) - 1; i >= 0; i-- {
+		h = rt.mws[i](h)
+	}
+	methods[method] = h
+	rt.routes = append(rt.routes, Route{Method: method, Pattern: pattern, Handler: h})
+}
+
+// HandleFunc 和 Handle 一样，参数是普通函数。
+func (rt *Router) HandleFunc(method, path string, h http.HandlerFunc) {
+	rt.Handle(method, path, h)
+}
+
+func (rt *Router) Get(path string, h http.HandlerFunc)    { rt.Handle(http.MethodGet, path, h) }
+func (rt *Router) Post(path string, h http.HandlerFunc)   { rt.Handle(http.MethodPost, path, h) }
+func (rt *Router) Put(path string, h http.HandlerFunc)    { rt.Handle(http.MethodPut, path, h) }
+func (rt *Router) Patch(path string, h http.HandlerFunc)  { rt.Handle(http.MethodPatch, path, h) }
+func (rt *Router) Delete(path string, h http.HandlerFunc) { rt.Handle(http.MethodDelete, path, h) }
+
+// Use 添加中间件，包在之后用这个路由器及其分组注册的处理函数外面，第一个在最外层。
+// 和套在整个路由器外面的中间件不同，这时路由已经匹配，r.Pattern 和 r.PathValue 都可以使用。
+func (rt *Router) Use(mws ...func(http.Handler) http.Handler) {
+	rt.mws = append(rt.mws, mws...)
+}
+
+// Group 创建一个路由分组，fn 中注册的路径都会加上 prefix，分组继承当前的中间件。
+func (rt *Router) Group(prefix string, fn func(g *Router)) {
+	fn(&Router{shared: rt.shared, prefix: rt.prefix + prefix, mws: slices.Clone(rt.mws)})
+}
+
+// Routes 按注册顺序返回所有路由。
+func (rt *Router) Routes() []Route {
+	return slices.Clone(rt.routes)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+// dispatch 返回一个路径的总处理函数，按请求方法分发。
+func (s *shared) dispatch(methods map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h, ok := methods[r.Method]; ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		// HEAD 使用 GET 的处理函数，net/http 会丢弃响应体
+		if h, ok := methods[http.MethodGet]; ok && r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Allow", allow(methods))
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s.MethodNotAllowed.ServeHTTP(w, r)
+	})
+}
+
+// allow 生成 Allow 头的值
+func allow(methods map[string]http.Handler) string {
+	list := []string{http.MethodOptions}
+	for m := range methods {
+		list = append(list, m)
+	}
+	if _, ok := methods[http.MethodGet]; ok {
+		if _, ok := methods[http.MethodHead]; !ok {
+			list = append(list, http.MethodHead)
+		}
+	}
+	slices.Sort(list)
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,153 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// reply 返回一个处理函数，输出 name 和匹配到的模式
+func reply(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Pattern", r.Pattern)
+		w.Write([]byte(name + " " + r.PathValue("id")))
+	}
+}
+
+func newTestRouter() *Router {
+	rt := New()
+	rt.Get("/users", reply("list"))
+	rt.Post("/users", reply("create"))
+	rt.Get("/users/{id}", reply("get"))
+	rt.Delete("/users/{id}", reply("delete"))
+	rt.Put("/items/{id}", reply("put"))
+	rt.HandleFunc(http.MethodOptions, "/custom", reply("options"))
+	return rt
+}
+
+func TestRouter(t *testing.T) {
+	rt := newTestRouter()
+	tests := []struct {
+		method, path string
+		code         int
+		body         string
+		allow        string
+	}{
+		{"GET", "/users", 200, "list ", ""},
+		{"POST", "/users", 200, "create ", ""},
+		{"GET", "/users/7", 200, "get 7", ""},
+		{"DELETE", "/users/7", 200, "delete 7", ""},
+		// 路径匹配、方法不匹配
+		{"PUT", "/users/7", 405, "Method Not Allowed\n", "DELETE, GET, HEAD, OPTIONS"},
+		{"PATCH", "/users", 405, "Method Not Allowed\n", "GET, HEAD, OPTIONS, POST"},
+		{"GET", "/items/1", 405, "Method Not Allowed\n", "OPTIONS, PUT"},
+		// HEAD 使用 GET 的处理函数；没有 GET 时也是 405
+		{"HEAD", "/users/7", 200, "get 7", ""},
+		{"HEAD", "/items/1", 405, "Method Not Allowed\n", "OPTIONS, PUT"},
+		// OPTIONS 返回 204 和 Allow 头，除非注册了自己的处理函数
+		{"OPTIONS", "/users/7", 204, "", "DELETE, GET, HEAD, OPTIONS"},
+		{"OPTIONS", "/items/1", 204, "", "OPTIONS, PUT"},
+		{"OPTIONS", "/custom", 200, "options ", ""},
+		{"GET", "/nothing", 404, "404 page not found\n", ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.code || rec.Body.String() != tt.body || rec.Header().Get("Allow") != tt.allow {
+			t.Errorf("%s %s: 返回 %d %q，Allow 是 %q", tt.method, tt.path, rec.Code, rec.Body, rec.Header().Get("Allow"))
+		}
+	}
+}
+
+// 经过真正的服务器时 HEAD 的响应没有响应体，响应头和 GET 一样
+func TestHead(t *testing.T) {
+	srv := httptest.NewServer(newTestRouter())
+	defer srv.Close()
+	resp, err := http.Head(srv.URL + "/users/7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || len(body) != 0 || resp.ContentLength != int64(len("get 7")) || resp.Header.Get("X-Pattern") != "/users/{id}" {
+		t.Fatalf("HEAD 返回 %d %q，Content-Length %d，响应头 %v", resp.StatusCode, body, resp.ContentLength, resp.Header)
+	}
+}
+
+func TestCustomHandlers(t *testing.T) {
+	rt := newTestRouter()
+	rt.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "没有这个地址", http.StatusNotFound)
+	})
+	rt.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "不支持 "+r.Method+"，可以用 "+w.Header().Get("Allow"), http.StatusMethodNotAllowed)
+	})
+	for path, want := range map[string]string{
+		"/nothing": "没有这个地址\n",
+		"/items/1": "不支持 GET，可以用 OPTIONS, PUT\n",
+	} {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Body.String() != want {
+			t.Errorf("GET %s 返回 %q，应该是 %q", path, rec.Body, want)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	var trace []string
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// 中间件在路由匹配之后执行，可以读到模式
+				trace = append(trace, name+" "+r.Pattern)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	rt := New()
+	rt.Use(mw("outer"))
+	rt.Group("/api", func(g *Router) {
+		g.Use(mw("api"))
+		g.Group("/v1", func(g *Router) {
+			g.Get("/users/{id}", reply("v1"))
+		})
+		g.Get("/health", reply("health"))
+	})
+	// 分组中添加的中间件不影响外面
+	rt.Get("/{$}", reply("root"))
+
+	for path, want := range map[string][]string{
+		"/api/v1/users/1": {"outer /api/v1/users/{id}", "api /api/v1/users/{id}"},
+		"/api/health":     {"outer /api/health", "api /api/health"},
+		"/":               {"outer /{$}"},
+	} {
+		trace = nil
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != 200 || !slices.Equal(trace, want) {
+			t.Errorf("GET %s 返回 %d，中间件执行了 %q，应该是 %q", path, rec.Code, trace, want)
+		}
+	}
+
+	var routes []string
+	for _, r := range rt.Routes() {
+		routes = append(routes, r.Method+" "+r.Pattern)
+	}
+	if want := []string{"GET /api/v1/users/{id}", "GET /api/health", "GET /{$}"}; !slices.Equal(routes, want) {
+		t.Fatalf("Routes 返回 %q，应该是 %q", routes, want)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	rt := newTestRouter()
+	defer func() {
+		if r, _ := recover().(string); !strings.Contains(r, "GET /users/{id}") {
+			t.Fatalf("重复注册时 panic(%q)", r)
+		}
+	}()
+	rt.Get("/users/{id}", reply("again"))
+}
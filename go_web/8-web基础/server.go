@@ -1,150 +1,1064 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"example.com/database"
+	"example.com/web/auth"
+	"example.com/web/authz"
+	"example.com/web/cache"
+	"example.com/web/config"
+	"example.com/web/middleware"
+	"example.com/web/openapi"
+	"example.com/web/problem"
+	"example.com/web/ratelimit"
+	"example.com/web/router"
+	"example.com/web/shutdown"
+	"example.com/web/store"
+	"example.com/web/validate"
 )
 
 // 用户结构体
-type User struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
-	Age  int    `json:"age"`
+type User = store.User
+
+// 用户存储，在 main 中根据 -store 标志选择具体实现
+var users store.UserRepository
+
+// 用户缓存，-cache none 时为 nil；启用时 users 就是它
+var userCache *cache.Store
+
+// 登录和令牌校验，在 main 中根据 auth 配置创建
+var authn *auth.Authenticator
+
+func main() {
+	// 配置来自默认值、配置文件、环境变量和命令行标志，go run server.go -print-config 可以查看
+	cfg := config.MustLoad("server", os.Args[1:])
+
+	var err error
+	users, err = openStore(cfg.Store)
+	if err != nil {
+		fmt.Printf("打开用户存储失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	backend, cacheRedis := openCache(cfg)
+	if backend != nil {
+		userCache = cache.NewStore(users, backend, cfg.Cache.TTL.D())
+		users = userCache
+	}
+
+	fmt.Printf("启动 HTTP 服务器在 %s 端口...\n", cfg.Server.Addr)
+
+	logger := newLogger(cfg.Log)
+	slog.SetDefault(logger)
+
+	authn, err = newAuthenticator(cfg.Auth)
+	if err != nil {
+		fmt.Printf("初始化认证失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	limiter, limitRedis := newLimiter(cfg)
+
+	// 启动服务器，收到 Ctrl+C 或 SIGTERM 后等待处理中的请求完成，再把用户数据写回存储，最后关闭 Redis 连接
+	srv := &http.Server{Addr: cfg.Server.Addr, Handler: newHandler(cfg, limiter, logger)}
+	code := shutdown.Run(srv, cfg.Server.ShutdownTimeout.D(), closeStore, closeRedis(cacheRedis), closeRedis(limitRedis))
+	fmt.Println("服务器已退出")
+	os.Exit(code)
 }
 
-// 模拟数据库
-var users = []User{
-	{ID: 1, Name: "张三", Age: 25},
-	{ID: 2, Name: "李四", Age: 30},
+// newHandler 把路由和中间件组装成服务器的 Handler，users 和 authn 需要已经初始化。limiter 为 nil 时不限流。
+// 所有请求都经过的中间件：请求 ID -> 访问日志 -> 计时 -> panic 恢复 -> 限流 -> 认证 -> 路由 -> 授权
+func newHandler(cfg *config.Config, limiter ratelimit.Limiter, logger *slog.Logger) http.Handler {
+	var routeMiddlewares []middleware.Middleware
+	if cfg.Auth.Enabled {
+		routeMiddlewares = append(routeMiddlewares, authz.Middleware(policy, userRole, writeAuthError))
+	}
+	middlewares := []middleware.Middleware{
+		middleware.RequestID(),
+		middleware.Logger(logger),
+		middleware.Timing(),
+		middleware.Recover(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			problem.Write(w, r, problem.Internal)
+		})),
+	}
+	// 限流放在认证前面，猜密码和伪造令牌的请求也会被限制
+	if limiter != nil {
+		middlewares = append(middlewares, ratelimit.Middleware(limiter, rateLimitKey(cfg.Limit.TrustProxy), writeRateLimited))
+	}
+	if cfg.Auth.Enabled {
+		middlewares = append(middlewares, auth.Middleware(authn, writeAuthError, "/login", "/login/refresh"))
+	}
+	return middleware.Chain(newRouter(routeMiddlewares...), middlewares...)
 }
 
-func main() {
-	fmt.Println("启动 HTTP 服务器在 :8080 端口...")
+// 按配置打开用户存储，mysql 存储在 9-数据库 中实现
+func openStore(cfg config.StoreConfig) (store.UserRepository, error) {
+	if cfg.Kind != "mysql" {
+		return store.Open(cfg.Kind, cfg.DataFile)
+	}
+
+	const connectTimeout = 5 * time.Second
+	db, err := database.OpenMySQL(cfg.MySQL.DSN, database.PoolOptions{
+		MaxOpenConns:    cfg.MySQL.MaxOpenConns,
+		MaxIdleConns:    cfg.MySQL.MaxIdleConns,
+		ConnMaxLifetime: cfg.MySQL.ConnMaxLifetime.D(),
+	}, connectTimeout)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	s, err := database.NewMySQLStore(ctx, db, cfg.MySQL.QueryTimeout.D())
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// 按配置创建缓存后端，-cache none 时返回 nil
+func openCache(cfg *config.Config) (cache.Backend, *database.Redis) {
+	switch cfg.Cache.Backend {
+	case "memory":
+		return cache.NewMemory(cfg.Cache.Size), nil
+	case "redis":
+		r := newRedis(cfg.Redis)
+		return cache.NewRedis(r, "web:"), r
+	}
+	return nil, nil
+}
+
+func newRedis(cfg config.RedisConfig) *database.Redis {
+	return database.NewRedis(database.RedisOptions{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+}
+
+// closeRedis 返回关闭 Redis 客户端的退出清理函数，r 为 nil 时什么也不做
+func closeRedis(r *database.Redis) shutdown.Hook {
+	return func(ctx context.Context) error {
+		if r == nil {
+			return nil
+		}
+		return r.Close()
+	}
+}
+
+// 按配置创建认证器。没有配置密钥时随机生成一个，重启后之前签发的令牌全部失效。
+func newAuthenticator(cfg config.AuthConfig) (*auth.Authenticator, error) {
+	secret := []byte(cfg.Secret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+		slog.Warn("没有配置 WEB_JWT_SECRET，使用随机密钥，重启后需要重新登录")
+	}
+	tokens, err := auth.NewTokens(secret, cfg.AccessTTL.D(), cfg.RefreshTTL.D())
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := auth.ParseAccounts(cfg.Accounts)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := auth.ParseAPIKeys(cfg.APIKeys)
+	if err != nil {
+		return nil, err
+	}
+	// 用过的刷新令牌记录在内存中，重启后清空；多个服务器进程之间不共享，
+	// 同一个刷新令牌在每个进程上各能用一次，部署多个进程时需要换成共享的 Denylist
+	return &auth.Authenticator{Tokens: tokens, Accounts: accounts, APIKeys: keys, Denylist: auth.NewMemoryDenylist()}, nil
+}
+
+// 按配置创建限流器，-rate-limit none 时返回 nil。状态保存在 Redis 中时同时返回它的客户端，由调用方关闭。
+func newLimiter(cfg *config.Config) (ratelimit.Limiter, *database.Redis) {
+	l := cfg.Limit
+	if l.Algorithm == "none" {
+		return nil, nil
+	}
+	var s ratelimit.Store = ratelimit.NewMemoryStore()
+	var r *database.Redis
+	if l.Store == "redis" {
+		r = newRedis(cfg.Redis)
+		s = ratelimit.NewRedisStore(r, "web:ratelimit:")
+	}
+	switch l.Algorithm {
+	case "token_bucket":
+		return ratelimit.NewTokenBucket(s, l.Requests, l.Window.D(), l.Burst), r
+	case "sliding_log":
+		return ratelimit.NewSlidingLog(s, l.Requests, l.Window.D()), r
+	case "fixed_window":
+		return ratelimit.NewFixedWindow(s, l.Requests, l.Window.D()), r
+	}
+	return nil, r
+}
+
+// rateLimitKey 按 API key 限流，没有携带有效 API key 的请求按客户端 IP 限流
+func rateLimitKey(trustProxy bool) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		if key := r.Header.Get(auth.APIKeyHeader); key != "" {
+			if k, ok := authn.APIKeys.Lookup(key); ok {
+				return "key:" + k.Name
+			}
+		}
+		return "ip:" + ratelimit.ClientIP(r, trustProxy)
+	}
+}
+
+// 超过限额时返回 429
+func writeRateLimited(w http.ResponseWriter, r *http.Request, res ratelimit.Result) {
+	seconds := func(d time.Duration) int { return max(int(math.Ceil(d.Seconds())), 1) }
+	problem.Write(w, r, problem.RateLimited, seconds(res.Window), res.Limit, seconds(res.RetryAfter))
+}
+
+// 按配置创建日志输出
+func newLogger(cfg config.LogConfig) *slog.Logger {
+	var level slog.Level
+	level.UnmarshalText([]byte(cfg.Level)) // 配置已经校验过，只会是 debug/info/warn/error
+	opts := &slog.HandlerOptions{Level: level}
+	if cfg.Format == "text" {
+		return slog.New(slog.NewTextHandler(os.Stdout, opts))
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, opts))
+}
+
+// 关闭用户存储，文件存储会在这里把数据写回磁盘
+func closeStore(ctx context.Context) error {
+	if c, ok := users.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// 授权规则：查看不需要权限，修改需要对应的权限，PATCH 自己只需要 users:update:self
+var policy = authz.NewPolicy(
+	authz.Rule{Method: http.MethodPost, Pattern: "/users", Permission: authz.UsersCreate},
+	authz.Rule{Method: http.MethodPut, Pattern: "/users/{id}", Permission: authz.UsersUpdate},
+	authz.Rule{Method: http.MethodPatch, Pattern: "/users/{id}", Permission: authz.UsersUpdate,
+		Self: authz.UsersUpdateSelf, Owner: authz.PathUser("id")},
+	authz.Rule{Method: http.MethodDelete, Pattern: "/users/{id}", Permission: authz.UsersDelete},
+)
 
-	// 注册路由处理函数
-	http.HandleFunc("/", homeHandler)
-	http.HandleFunc("/users", usersHandler)
+// userRole 返回调用方的角色。登录用户的角色每次从用户数据中读取，修改角色后立即生效；
+// 账号没有对应的用户时只能查看，对应的用户被删除后没有任何权限。
+func userRole(ctx context.Context, p auth.Principal) (string, error) {
+	if p.Method == auth.MethodAPIKey {
+		return p.Role, nil
+	}
+	if p.UserID == 0 {
+		return store.RoleViewer, nil
+	}
+	u, err := users.Get(ctx, p.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return "", nil
+	}
+	return u.Role, err
+}
+
+// 注册路由处理函数，mws 在路由匹配之后执行
+func newRouter(mws ...middleware.Middleware) *router.Router {
+	rt := router.New()
+	for _, mw := range mws {
+		rt.Use(mw)
+	}
+	rt.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.NotFound, r.URL.Path)
+	})
+	rt.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.MethodNotAllowed, r.URL.Path, r.Method)
+	})
+
+	// 首页是 API 文档，和 /openapi.json 一样由这个路由器上的路由生成，所有路由注册完之后才能得到。
+	// 文档属于这个路由器，多次调用 newRouter 得到的路由器互不影响。
+	var doc *openapi.Document
+	// Swagger UI 风格的 API 文档，可以直接在页面上发送请求
+	rt.Get("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		doc.HTMLHandler("/openapi.json").ServeHTTP(w, r)
+	})
+	// OpenAPI 3.1 文档，可以导入 Swagger UI、Postman 等工具或者用来生成客户端
+	rt.Get("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		doc.ServeHTTP(w, r)
+	})
+	// 账号来自 WEB_AUTH_ACCOUNTS，例如 WEB_AUTH_ACCOUNTS=admin:admin123:1 时：
+	// curl -X POST http://localhost:8080/login -d '{"username":"admin","password":"admin123"}'
+	rt.Post("/login", loginHandler)
+	rt.Post("/login/refresh", refreshHandler)
 	// curl http://localhost:8080/users
-	http.HandleFunc("/users/", userDetailHandler)
-	http.HandleFunc("/time", timeHandler)
-	http.HandleFunc("/health", healthHandler)
-
-	// 启动服务器
-	err := http.ListenAndServe(":8080", nil)
-	if err != nil {
-		fmt.Printf("服务器启动失败: %v\n", err)
-	}
-}
-
-// 首页处理
-func homeHandler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
-		return
-	}
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	html := `
-	<!DOCTYPE html>
-	<html>
-	<head>
-		<title>Go HTTP 服务器</title>
-		<style>
-			body { font-family: Arial, sans-serif; margin: 40px; }
-			.container { max-width: 800px; margin: 0 auto; }
-			.endpoint { background: #f5f5f5; padding: 10px; margin: 10px 0; }
-		</style>
-	</head>
-	<body>
-		<div class="container">
-			<h1>🎉 Go HTTP 服务器运行中</h1>
-			<p>可用的 API 端点：</p>
-			<div class="endpoint">
-				<strong>GET /</strong> - 首页 (返回 HTML)
-			</div>
-			<div class="endpoint">
-				<strong>GET /users</strong> - 获取所有用户列表 (JSON)
-			</div>
-			<div class="endpoint">
-				<strong>GET /users/{id}</strong> - 获取特定用户信息 (JSON)
-			</div>
-			<div class="endpoint">
-				<strong>GET /time</strong> - 获取服务器当前时间 (JSON)
-			</div>
-			<div class="endpoint">
-				<strong>GET /health</strong> - 健康检查 (JSON)
-			</div>
-		</div>
-	</body>
-	</html>
-	`
-	fmt.Fprint(w, html)
-}
-
-// 获取所有用户
-func usersHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case "GET":
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(users)
-	case "POST":
-		// 简单的 POST 处理
-		var newUser User
-		err := json.NewDecoder(r.Body).Decode(&newUser)
-		if err != nil {
-			http.Error(w, "无效的 JSON 数据", http.StatusBadRequest)
-			return
+	rt.Group("/users", func(g *router.Router) {
+		g.Get("", listUsersHandler)
+		g.Post("", createUserHandler)
+		g.Get("/{id}", getUserHandler)
+		g.Put("/{id}", replaceUserHandler)
+		g.Patch("/{id}", patchUserHandler)
+		g.Delete("/{id}", deleteUserHandler)
+		g.Get("/{id}/avatar", avatarHandler)
+	})
+	rt.Get("/time", timeHandler)
+	rt.Get("/health", healthHandler)
+	rt.Get("/cache/stats", cacheStatsHandler)
+
+	doc = apiSpec().Document(rt.Routes())
+	return rt
+}
+
+// apiSpec 描述每个路由的参数、请求体和响应。路径和路径参数来自路由器，
+// User 等类型的 Schema 由 json 和 validate 标签生成，需要的权限来自 policy。
+func apiSpec() *openapi.Spec {
+	spec := openapi.NewSpec(openapi.Info{
+		Title:   "Go HTTP 服务器",
+		Version: "1.0.0",
+		Description: "用户管理 API。读请求可以匿名访问；启用认证 (auth.enabled) 后，写请求需要 Authorization: Bearer 或 X-API-Key，" +
+			"并且角色有对应的权限：admin 可以做所有操作，editor 可以创建和修改，viewer 只能修改自己。\n" +
+			"错误响应统一为 application/problem+json，根据 Accept-Language 返回中文或英文说明。\n" +
+			"使用 -rate-limit token_bucket、sliding_log 或 fixed_window 启用限流，超过限额返回 429，响应头中有 RateLimit-* 和 Retry-After；" +
+			"使用 -cache memory 或 -cache redis 启用用户缓存。",
+	})
+	spec.Tags = []openapi.Tag{
+		{Name: "users", Description: "用户的增删改查"},
+		{Name: "login", Description: "登录和刷新令牌"},
+		{Name: "default", Description: "文档、时间、健康检查等"},
+	}
+	spec.Components.SecuritySchemes["bearerAuth"] = &openapi.SecurityScheme{
+		Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "POST /login 返回的访问令牌",
+	}
+	spec.Components.SecuritySchemes["apiKey"] = &openapi.SecurityScheme{
+		Type: "apiKey", In: "header", Name: auth.APIKeyHeader, Description: "配置文件或 WEB_API_KEYS 中的 API key",
+	}
+	secured := []openapi.SecurityRequirement{{"bearerAuth": {}}, {"apiKey": {}}}
+	other := []string{"default"}
+
+	userSchema := openapi.SchemaOf(User{})
+	userSchema.Properties["id"].ReadOnly = true
+	userSchema.Properties["id"].Description = "由服务器生成"
+	userSchema.Properties["role"].Description = "创建时默认为 viewer，指定其他角色需要 users:assign_role 权限"
+	userSchema.Properties["id"].Examples = []any{1}
+	userSchema.Properties["name"].Examples = []any{"王五"}
+	userSchema.Properties["age"].Examples = []any{28}
+	userSchema.Properties["role"].Examples = []any{store.RoleViewer}
+	user := spec.Define("User", userSchema)
+	tokens := spec.Define("TokenPair", openapi.SchemaOf(auth.TokenPair{}))
+	login := spec.Define("LoginRequest", openapi.SchemaOf(loginRequest{}))
+	refresh := spec.Define("RefreshRequest", openapi.SchemaOf(refreshRequest{}))
+	problemSchema := openapi.SchemaOf(problem.Problem{})
+	problemSchema.Properties["code"].Description = "机器可读的错误码，客户端应该根据它判断错误类型"
+	for _, code := range problem.Codes() {
+		problemSchema.Properties["code"].Enum = append(problemSchema.Properties["code"].Enum, code)
+	}
+	problemRef := spec.Define("Problem", problemSchema)
+
+	// 错误响应
+	fail := func(description string) *openapi.Response {
+		return openapi.ContentResponse(description, "application/problem+json", problemRef)
+	}
+	idParam := &openapi.Parameter{Name: "id", In: "path", Description: "用户 ID", Schema: &openapi.Schema{Type: "integer", Minimum: ptr(1.0)}}
+	intQuery := func(name, description string, min float64) *openapi.Parameter {
+		return &openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: "integer", Minimum: ptr(min)}}
+	}
+
+	spec.Operations = map[string]*openapi.Operation{
+		"GET /{$}": {
+			Summary:   "API 文档页面",
+			Responses: map[string]*openapi.Response{"200": openapi.ContentResponse("就是这个页面", "text/html", &openapi.Schema{Type: "string"})},
+		},
+		"GET /openapi.json": {
+			Tags:      other,
+			Summary:   "OpenAPI 3.1 文档",
+			Responses: map[string]*openapi.Response{"200": openapi.JSONResponse("本文档", &openapi.Schema{Type: "object"})},
+		},
+		"POST /login": {
+			Tags:        []string{"login"},
+			Summary:     "用户名密码登录",
+			Description: "返回访问令牌和刷新令牌，访问令牌放在 Authorization: Bearer 中使用。",
+			RequestBody: openapi.JSONBody("", login),
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("登录成功", tokens),
+				"400": fail("请求体不是有效的 JSON"),
+				"401": fail("用户名或密码错误"),
+				"422": fail("缺少用户名或密码"),
+			},
+		},
+		"POST /login/refresh": {
+			Tags:        []string{"login"},
+			Summary:     "刷新令牌",
+			Description: "用刷新令牌换取一对新的令牌，访问令牌过期后不需要重新输入密码。每个刷新令牌只能使用一次，之后要用新返回的刷新令牌。",
+			RequestBody: openapi.JSONBody("", refresh),
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("新的令牌", tokens),
+				"401": fail("刷新令牌无效、已过期或已经使用过"),
+			},
+		},
+		"GET /users": {
+			Summary:     "获取用户列表",
+			Description: "page 和 after 是两种分页方式，不能同时使用：page 按页码，after 按游标，返回 ID 大于 after 的用户。",
+			Parameters: []*openapi.Parameter{
+				intQuery("page", "页码，从 1 开始", 1),
+				{Name: "page_size", In: "query", Description: fmt.Sprintf("每页的用户数，超过 %d 按 %d 处理", maxPageSize, maxPageSize),
+					Schema: &openapi.Schema{Type: "integer", Minimum: ptr(1.0), Default: defaultPageSize}},
+				intQuery("after", "游标，返回 ID 大于它的用户", 1),
+				{Name: "name", In: "query", Description: "按名字过滤，包含这个子串的用户", Schema: &openapi.Schema{Type: "string"}},
+				intQuery("min_age", "最小年龄", 0),
+				intQuery("max_age", "最大年龄", 0),
+				{Name: "sort", In: "query", Description: "排序字段，逗号分隔，- 表示降序，例如 age,-name", Schema: &openapi.Schema{Type: "string"}},
+			},
+			Responses: map[string]*openapi.Response{
+				"200": {
+					Description: "一页用户",
+					Headers: map[string]*openapi.Header{
+						"X-Total-Count": {Description: "符合条件的用户总数", Schema: &openapi.Schema{Type: "integer"}},
+						"Link":          {Description: `RFC 8288 分页链接，rel 为 first、prev、next、last`, Schema: &openapi.Schema{Type: "string"}},
+					},
+					Content: map[string]openapi.MediaType{"application/json": {Schema: &openapi.Schema{Type: "array", Items: user}}},
+				},
+				"400": fail("查询参数无效"),
+			},
+		},
+		"POST /users": {
+			Summary:     "创建用户",
+			RequestBody: openapi.JSONBody("ID 由服务器生成，不能指定", user),
+			Security:    secured,
+			Responses: map[string]*openapi.Response{
+				"201": {
+					Description: "创建成功",
+					Headers:     map[string]*openapi.Header{"Location": {Description: "新用户的地址", Schema: &openapi.Schema{Type: "string"}}},
+					Content:     map[string]openapi.MediaType{"application/json": {Schema: user}},
+				},
+				"400": fail("请求体不是有效的 JSON"),
+				"401": fail("没有认证"),
+				"403": fail("没有权限"),
+				"413": fail("请求体太大"),
+				"422": fail("字段校验失败"),
+			},
+		},
+		"GET /users/{id}": {
+			Summary:    "获取用户",
+			Parameters: []*openapi.Parameter{idParam},
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("用户", user),
+				"400": fail("ID 无效"),
+				"404": fail("用户不存在"),
+			},
+		},
+		"PUT /users/{id}": {
+			Summary:     "整体替换用户",
+			Description: "请求体是完整的新用户。省略 ID 则以 URL 为准，和 URL 不一致时返回 409；省略角色则保持不变。",
+			Parameters:  []*openapi.Parameter{idParam},
+			RequestBody: openapi.JSONBody("", user),
+			Security:    secured,
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("修改后的用户", user),
+				"400": fail("ID 或请求体无效"),
+				"401": fail("没有认证"),
+				"403": fail("没有权限"),
+				"404": fail("用户不存在"),
+				"409": fail("请求体中的 ID 和 URL 不一致"),
+				"422": fail("字段校验失败"),
+			},
+		},
+		"PATCH /users/{id}": {
+			Summary:     "部分更新用户",
+			Description: "JSON Merge Patch (RFC 7386)：只修改请求体中出现的字段，值为 null 表示清空。",
+			Parameters:  []*openapi.Parameter{idParam},
+			RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+				"application/merge-patch+json": {Schema: user},
+			}},
+			Security: secured,
+			Responses: map[string]*openapi.Response{
+				"200": openapi.JSONResponse("修改后的用户", user),
+				"400": fail("ID 或请求体无效"),
+				"401": fail("没有认证"),
+				"403": fail("没有权限"),
+				"404": fail("用户不存在"),
+				"409": fail("请求体中的 ID 和 URL 不一致"),
+				"415": fail("Content-Type 不是 application/merge-patch+json"),
+				"422": fail("字段校验失败"),
+			},
+		},
+		"DELETE /users/{id}": {
+			Summary:    "删除用户",
+			Parameters: []*openapi.Parameter{idParam},
+			Security:   secured,
+			Responses: map[string]*openapi.Response{
+				"204": {Description: "删除成功"},
+				"401": fail("没有认证"),
+				"403": fail("没有权限"),
+				"404": fail("用户不存在"),
+			},
+		},
+		"GET /users/{id}/avatar": {
+			Summary:    "用户头像",
+			Parameters: []*openapi.Parameter{idParam},
+			Responses: map[string]*openapi.Response{
+				"200": openapi.ContentResponse("根据名字的第一个字生成的 SVG 图片", "image/svg+xml", &openapi.Schema{Type: "string"}),
+				"404": fail("用户不存在"),
+			},
+		},
+		"GET /time": {
+			Tags:    other,
+			Summary: "服务器当前时间",
+			Responses: map[string]*openapi.Response{"200": openapi.JSONResponse("当前时间", &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"timestamp": {Type: "integer", Format: "int64", Description: "Unix 时间戳，单位秒"},
+					"datetime":  {Type: "string", Examples: []any{"2024-01-01 12:00:00"}},
+					"timezone":  {Type: "string", Examples: []any{"Local"}},
+				},
+			})},
+		},
+		"GET /health": {
+			Tags:    other,
+			Summary: "健康检查",
+			Responses: map[string]*openapi.Response{"200": openapi.JSONResponse("服务正常", &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"status":    {Type: "string", Enum: []any{"healthy"}},
+					"timestamp": {Type: "string", Format: "date-time"},
+					"service":   {Type: "string", Examples: []any{"go-http-server"}},
+				},
+			})},
+		},
+		"GET /cache/stats": {
+			Tags:        other,
+			Summary:     "用户缓存命中统计",
+			Description: "使用 -cache memory 或 -cache redis 启用缓存，没有启用时只返回 enabled: false。",
+			Responses: map[string]*openapi.Response{"200": openapi.JSONResponse("缓存统计", &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"enabled":  {Type: "boolean"},
+					"hits":     {Type: "integer", Format: "int64"},
+					"misses":   {Type: "integer", Format: "int64"},
+					"errors":   {Type: "integer", Format: "int64"},
+					"shared":   {Type: "integer", Format: "int64", Description: "未命中时和其他请求共用一次存储读取的次数"},
+					"hit_rate": {Type: "number", Minimum: ptr(0.0), Maximum: ptr(1.0)},
+				},
+				Required: []string{"enabled"},
+			})},
+		},
+	}
+
+	// 需要的权限写在说明里，和授权规则保持一致
+	for key, op := range spec.Operations {
+		method, pattern, _ := strings.Cut(key, " ")
+		rule, ok := policy.Rule(method, pattern)
+		if !ok || rule.Permission == "" {
+			continue
 		}
+		perm := "需要权限 " + string(rule.Permission)
+		if rule.Self != "" {
+			perm += "，修改自己时 " + string(rule.Self) + " 也可以"
+		}
+		op.Description = strings.TrimSpace(op.Description + "\n" + perm + "。")
+	}
+	return spec
+}
 
-		// 生成新 ID
-		newUser.ID = len(users) + 1
-		users = append(users, newUser)
+func ptr[T any](v T) *T { return &v }
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(newUser)
+// 登录请求
+type loginRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// 刷新令牌请求
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// 用户名密码登录，成功后返回一对令牌
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	tokens, err := authn.Login(req.Username, req.Password)
+	if err != nil {
+		writeAuthError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// 用刷新令牌换取一对新的令牌，访问令牌过期后不需要重新输入密码
+func refreshHandler(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	tokens, err := authn.Refresh(req.RefreshToken)
+	if err != nil {
+		writeAuthError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// 认证失败的响应是 401，缺少权限是 403
+func writeAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	var denied *authz.DeniedError
+	switch {
+	case errors.As(err, &denied):
+		problem.Write(w, r, problem.Forbidden, denied.Permission)
+	case errors.Is(err, auth.ErrNoCredentials):
+		problem.Write(w, r, problem.AuthRequired)
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		problem.Write(w, r, problem.InvalidCredentials)
+	case errors.Is(err, auth.ErrTokenExpired):
+		problem.Write(w, r, problem.TokenExpired)
+	case errors.Is(err, auth.ErrInvalidAPIKey):
+		problem.Write(w, r, problem.InvalidAPIKey)
+	case errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrTokenRevoked):
+		problem.Write(w, r, problem.InvalidToken)
 	default:
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+		problem.WriteError(w, r, err)
 	}
 }
 
-// 获取特定用户
-func userDetailHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+// 获取用户列表
+func listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+	all, err := users.List(r.Context())
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+	page, total, err := q.Apply(all)
+	if errors.Is(err, store.ErrInvalidCursor) {
+		problem.Write(w, r, problem.InvalidCursor, q.After)
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if link := paginationLinks(r.URL, q, page, total); link != "" {
+		w.Header().Set("Link", link)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// 创建用户
+func createUserHandler(w http.ResponseWriter, r *http.Request) {
+	newUser, ok := readUser(w, r)
+	if !ok {
+		return
+	}
+
+	// 新用户默认是 viewer，指定其他角色需要 users:assign_role
+	if newUser.Role == "" {
+		newUser.Role = store.RoleViewer
+	} else if newUser.Role != store.RoleViewer {
+		if err := authz.Require(r.Context(), authz.UsersAssignRole); err != nil {
+			writeAuthError(w, r, err)
+			return
+		}
+	}
+
+	// ID 由存储生成，不接受客户端指定
+	var errs validate.Errors
+	if newUser.ID != 0 {
+		errs = append(errs, validate.NewFieldError("id", "readonly", ""))
+	}
+	if err := validate.Struct(newUser); err != nil {
+		errs = append(errs, err.(validate.Errors)...)
+	}
+	if len(errs) > 0 {
+		problem.WriteError(w, r, errs)
+		return
+	}
+
+	newUser, err := users.Create(r.Context(), newUser)
+	if err != nil {
+		problem.WriteError(w, r, err)
 		return
 	}
 
-	// 从 URL 中提取用户 ID
-	idStr := r.URL.Path[len("/users/"):]
+	w.Header().Set("Location", fmt.Sprintf("/users/%d", newUser.ID))
+	writeJSON(w, http.StatusCreated, newUser)
+}
+
+// 每页默认和最多返回的用户数
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// parseListQuery 解析 GET /users 的查询参数：
+// ?page=&page_size= 或 ?after= 分页，?name= 子串过滤，?min_age=&max_age= 年龄过滤，?sort=age,-name 排序。
+func parseListQuery(v url.Values) (store.Query, error) {
+	q := store.Query{Name: v.Get("name"), PageSize: defaultPageSize}
+
+	intParam := func(name string, min int) (int, bool, error) {
+		s := v.Get(name)
+		if s == "" {
+			return 0, false, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min {
+			return 0, false, problem.New(problem.InvalidParameter, name, min)
+		}
+		return n, true, nil
+	}
+
+	var err error
+	if q.Page, _, err = intParam("page", 1); err != nil {
+		return q, err
+	}
+	if q.After, _, err = intParam("after", 1); err != nil {
+		return q, err
+	}
+	if q.Page != 0 && q.After != 0 {
+		return q, problem.New(problem.ConflictingParameters, "page", "after")
+	}
+	if n, ok, err := intParam("page_size", 1); err != nil {
+		return q, err
+	} else if ok {
+		q.PageSize = min(n, maxPageSize)
+	}
+
+	if n, ok, err := intParam("min_age", 0); err != nil {
+		return q, err
+	} else if ok {
+		q.MinAge = &n
+	}
+	if n, ok, err := intParam("max_age", 0); err != nil {
+		return q, err
+	} else if ok {
+		q.MaxAge = &n
+	}
+
+	if q.Sort, err = store.ParseSort(v.Get("sort")); err != nil {
+		return q, problem.New(problem.InvalidSort, v.Get("sort"))
+	}
+	return q, nil
+}
+
+// paginationLinks 生成 RFC 8288 格式的 Link 头，客户端沿着 rel="next" 就能遍历所有结果。
+func paginationLinks(u *url.URL, q store.Query, page []User, total int) string {
+	link := func(rel string, set func(v url.Values)) string {
+		v := u.Query()
+		v.Del("page")
+		v.Del("after")
+		v.Set("page_size", strconv.Itoa(q.PageSize))
+		set(v)
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, u.Path, v.Encode(), rel)
+	}
+	var links []string
+
+	if q.After != 0 {
+		// 游标分页只能向后翻，本页满了就说明可能还有下一页
+		if len(page) == q.PageSize {
+			last := page[len(page)-1].ID
+			links = append(links, link("next", func(v url.Values) { v.Set("after", strconv.Itoa(last)) }))
+		}
+		return strings.Join(links, ", ")
+	}
+
+	current := max(q.Page, 1)
+	lastPage := max((total+q.PageSize-1)/q.PageSize, 1)
+	pageLink := func(rel string, n int) {
+		links = append(links, link(rel, func(v url.Values) { v.Set("page", strconv.Itoa(n)) }))
+	}
+	pageLink("first", 1)
+	if current > 1 {
+		pageLink("prev", min(current-1, lastPage))
+	}
+	if current < lastPage {
+		pageLink("next", current+1)
+	}
+	pageLink("last", lastPage)
+	return strings.Join(links, ", ")
+}
+
+// userID 读取路径参数中的用户 ID，无效时已经输出了错误响应，ok 为 false
+func userID(w http.ResponseWriter, r *http.Request) (id int, ok bool) {
+	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
+	if err != nil || id < 1 { // ID 从 1 开始分配
+		problem.Write(w, r, problem.InvalidUserID, idStr)
+		return 0, false
+	}
+	return id, true
+}
+
+// 获取特定用户
+func getUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+	user, err := users.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, id)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// 整体替换用户：请求体就是完整的新用户
+func replaceUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+	user, ok := readUser(w, r)
+	if !ok {
+		return
+	}
+	current, err := users.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, id)
+		return
+	}
+	replaceUser(w, r, current, user)
+}
+
+// 部分更新用户：JSON Merge Patch (RFC 7386)，只修改请求体中出现的字段，值为 null 表示清空
+func patchUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+		problem.Write(w, r, problem.UnsupportedMediaType, "PATCH", "application/merge-patch+json")
+		return
+	}
+	var patch any
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&patch); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	current, err := users.Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, id)
+		return
+	}
+	user, err := applyMergePatch(current, patch)
+	if err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	replaceUser(w, r, current, user)
+}
+
+// 删除用户
+func deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+	if err := users.Delete(r.Context(), id); err != nil {
+		writeStoreError(w, r, err, id)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 用户头像：根据名字的第一个字生成一张 SVG 图片
+func avatarHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(w, r)
+	if !ok {
+		return
+	}
+	user, err := users.Get(r.Context(), id)
 	if err != nil {
-		http.Error(w, "无效的用户 ID", http.StatusBadRequest)
+		writeStoreError(w, r, err, id)
 		return
 	}
 
-	// 查找用户
-	for _, user := range users {
-		if user.ID == id {
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(user)
+	initial := "?"
+	for _, c := range user.Name {
+		initial = string(c)
+		break
+	}
+	hue := user.ID * 137 % 360 // 相邻的 ID 颜色差别大一些
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "max-age=300")
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="64" height="64" viewBox="0 0 64 64">`+
+		`<circle cx="32" cy="32" r="32" fill="hsl(%d,60%%,55%%)"/>`+
+		`<text x="32" y="42" font-size="28" text-anchor="middle" fill="#fff" font-family="sans-serif">%s</text></svg>`,
+		hue, html.EscapeString(initial))
+}
+
+// replaceUser 用 user 替换 current。请求体里的 ID 与 URL 不一致时返回 409，
+// 省略 ID 则以 URL 为准；省略角色则保持不变，修改角色需要 users:assign_role。
+func replaceUser(w http.ResponseWriter, r *http.Request, current, user User) {
+	id := current.ID
+	if user.ID != 0 && user.ID != id {
+		problem.Write(w, r, problem.IDConflict, user.ID, id)
+		return
+	}
+	user.ID = id
+	if user.Role == "" {
+		user.Role = current.Role
+	} else if user.Role != current.Role {
+		if err := authz.Require(r.Context(), authz.UsersAssignRole); err != nil {
+			writeAuthError(w, r, err)
 			return
 		}
 	}
+	if err := validate.Struct(user); err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	user, err := users.Update(r.Context(), user)
+	if err != nil {
+		writeStoreError(w, r, err, id)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
 
-	http.NotFound(w, r)
+// applyMergePatch 把合并补丁应用到用户上：先把用户转成 JSON 对象，合并后再转回来。
+func applyMergePatch(user User, patch any) (User, error) {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return User{}, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return User{}, err
+	}
+
+	data, err = json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return User{}, err
+	}
+	return decodeUser(data)
 }
 
-// 获取服务器时间
-func timeHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
+// mergePatch 按 RFC 7386 的算法合并：补丁不是对象时直接替换目标，
+// 是对象时逐个字段递归合并，字段值为 null 时删除该字段。
+func mergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+		} else {
+			targetObj[k] = mergePatch(targetObj[k], v)
+		}
+	}
+	return targetObj
+}
+
+// 请求体最大 1MB
+const maxBodyBytes = 1 << 20
+
+// readUser 读取并解析请求体中的用户，失败时已经输出了错误响应，ok 为 false，调用方直接返回即可。
+func readUser(w http.ResponseWriter, r *http.Request) (user User, ok bool) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err == nil {
+		user, err = decodeUser(data)
+	}
+	if err != nil {
+		writeDecodeError(w, r, err)
+		return User{}, false
+	}
+	return user, true
+}
+
+// readJSON 解析请求体中的 JSON 并校验，失败时已经输出了错误响应，返回 false
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeDecodeError(w, r, err)
+		return false
+	}
+	if err := validate.Struct(v); err != nil {
+		problem.WriteError(w, r, err)
+		return false
+	}
+	return true
+}
+
+// decodeUser 严格地解析用户 JSON：未知字段和类型不匹配转换成 validate.Errors。
+func decodeUser(data []byte) (User, error) {
+	var user User
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&user)
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		return User{}, validate.Errors{validate.NewFieldError(typeErr.Field, "type", typeErr.Type.String())}
+	case err != nil && strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return User{}, validate.Errors{validate.NewFieldError(field, "unknown", "")}
+	case err != nil:
+		return User{}, err
+	}
+	if dec.More() {
+		return User{}, errors.New("请求体只能包含一个 JSON 对象")
+	}
+	return user, nil
+}
+
+// 解析请求体出错时的响应：过大返回 413，未知字段或类型不匹配返回 422，其他情况视为 JSON 格式错误返回 400
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	var errs validate.Errors
+	switch {
+	case errors.As(err, &tooLarge):
+		problem.Write(w, r, problem.BodyTooLarge, tooLarge.Limit)
+	case errors.As(err, &errs):
+		problem.WriteError(w, r, errs)
+	default:
+		problem.Write(w, r, problem.InvalidJSON)
+	}
+}
+
+// 存储返回的错误转换成错误响应
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error, id int) {
+	if errors.Is(err, store.ErrNotFound) {
+		problem.Write(w, r, problem.UserNotFound, id)
 		return
 	}
+	problem.WriteError(w, r, err)
+}
 
+// 以 JSON 格式返回数据
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// 获取服务器时间
+func timeHandler(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"timestamp": time.Now().Unix(),
 		"datetime":  time.Now().Format("2006-01-02 15:04:05"),
@@ -157,11 +1071,6 @@ func timeHandler(w http.ResponseWriter, r *http.Request) {
 
 // 健康检查
 func healthHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		http.Error(w, "方法不允许", http.StatusMethodNotAllowed)
-		return
-	}
-
 	response := map[string]string{
 		"status":    "healthy",
 		"timestamp": time.Now().Format(time.RFC3339),
@@ -171,3 +1080,20 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// 缓存命中统计
+func cacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if userCache == nil {
+		writeJSON(w, http.StatusOK, map[string]any{"enabled": false})
+		return
+	}
+	stats := userCache.Stats()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"enabled":  true,
+		"hits":     stats.Hits,
+		"misses":   stats.Misses,
+		"errors":   stats.Errors,
+		"shared":   stats.Shared,
+		"hit_rate": stats.HitRate(),
+	})
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"example.com/web/auth"
+	"example.com/web/authz"
+	"example.com/web/client"
+	"example.com/web/config"
+	"example.com/web/store"
+)
+
+const (
+	testEditorKey = "editor-key-0123456789"
+	testViewerKey = "viewer-key-0123456789"
+)
+
+// newTestServer 用默认配置启动完整的服务器（中间件、认证、授权都和 main 中一样），用户数据是初始的两个用户
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Auth.Secret = strings.Repeat("s", 32)
+	cfg.Auth.Accounts = "admin:admin123:1"
+	cfg.Auth.APIKeys = "editor:" + testEditorKey + ",viewer:" + testViewerKey + ":viewer"
+
+	var err error
+	if users, err = store.Open("memory", ""); err != nil {
+		t.Fatal(err)
+	}
+	userCache = nil
+	if authn, err = newAuthenticator(cfg.Auth); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newHandler(&cfg, nil, slog.New(slog.DiscardHandler)))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// recorder 记录客户端发出的每个请求的方法和路径
+type recorder struct {
+	mu   sync.Mutex
+	reqs []string
+}
+
+func (rec *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec.mu.Lock()
+	rec.reqs = append(rec.reqs, req.Method+" "+req.URL.Path)
+	rec.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (rec *recorder) count(req string) int {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	n := 0
+	for _, r := range rec.reqs {
+		if r == req {
+			n++
+		}
+	}
+	return n
+}
+
+func newTestClient(t *testing.T, srv *httptest.Server, opts ...client.Option) (*client.Client, *recorder) {
+	t.Helper()
+	rec := &recorder{}
+	opts = append([]client.Option{client.WithHTTPClient(&http.Client{Transport: rec})}, opts...)
+	c, err := client.New(srv.URL, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, rec
+}
+
+func TestClientLogin(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	c, rec := newTestClient(t, srv, client.WithLogin("admin", "admin123"))
+
+	// 读请求可以匿名访问，不会为它登录
+	if _, err := c.GetUser(ctx, 1); err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if n := rec.count("POST /login"); n != 0 {
+		t.Fatalf("读请求之前登录了 %d 次", n)
+	}
+
+	// 第一次写请求前登录
+	u, err := c.CreateUser(ctx, client.User{Name: "王五", Age: 28})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if u.ID == 0 || u.Name != "王五" {
+		t.Fatalf("CreateUser 返回 %+v", u)
+	}
+	if n := rec.count("POST /login"); n != 1 {
+		t.Fatalf("登录了 %d 次，应该是 1 次", n)
+	}
+
+	// 刷新得到的访问令牌可以继续使用
+	tokens, err := c.Refresh(ctx)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.TokenType != "Bearer" {
+		t.Fatalf("Refresh 返回 %+v", tokens)
+	}
+	if err := c.DeleteUser(ctx, u.ID); err != nil {
+		t.Fatalf("刷新之后 DeleteUser: %v", err)
+	}
+	if n := rec.count("POST /login"); n != 1 {
+		t.Fatalf("刷新之后又登录了，一共 %d 次", n)
+	}
+}
+
+// postRefresh 直接调用 /login/refresh，返回状态码
+func postRefresh(t *testing.T, srv *httptest.Server, refreshToken string) int {
+	t.Helper()
+	resp, err := http.Post(srv.URL+"/login/refresh", "application/json",
+		strings.NewReader(`{"refresh_token":"`+refreshToken+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	c, _ := newTestClient(t, srv)
+
+	first, err := c.Login(ctx, "admin", "admin123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Refresh(ctx)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("刷新之后应该返回新的刷新令牌")
+	}
+
+	// 用过的刷新令牌作废，新的可以继续使用
+	if status := postRefresh(t, srv, first.RefreshToken); status != http.StatusUnauthorized {
+		t.Fatalf("再次使用旧的刷新令牌返回 %d，应该是 401", status)
+	}
+	if _, err := c.Refresh(ctx); err != nil {
+		t.Fatalf("用新的刷新令牌 Refresh: %v", err)
+	}
+
+	// 同一个刷新令牌的并发请求只有一个成功
+	tokens, err := c.Login(ctx, "admin", "admin123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ok := 0
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if postRefresh(t, srv, tokens.RefreshToken) == http.StatusOK {
+				mu.Lock()
+				ok++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if ok != 1 {
+		t.Fatalf("10 个并发请求中有 %d 个刷新成功，应该只有 1 个", ok)
+	}
+}
+
+func TestClientRetriesAfterUnauthorized(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	c, rec := newTestClient(t, srv, client.WithToken("expired"), client.WithLogin("admin", "admin123"))
+
+	// 已有的令牌被拒绝时登录后重试一次
+	if _, err := c.PatchUser(ctx, 2, map[string]any{"age": 31}); err != nil {
+		t.Fatalf("PatchUser: %v", err)
+	}
+	if n := rec.count("PATCH /users/2"); n != 2 {
+		t.Fatalf("PATCH 发送了 %d 次，应该是 2 次", n)
+	}
+	if n := rec.count("POST /login"); n != 1 {
+		t.Fatalf("登录了 %d 次，应该是 1 次", n)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	anonymous, _ := newTestClient(t, srv)
+	editor, _ := newTestClient(t, srv, client.WithAPIKey(testEditorKey))
+	viewer, _ := newTestClient(t, srv, client.WithAPIKey(testViewerKey))
+	badKey, _ := newTestClient(t, srv, client.WithAPIKey("wrong-key-0123456789"))
+
+	tests := []struct {
+		name   string
+		call   func() error
+		target error
+		status int
+		code   string
+	}{
+		{"用户不存在", func() error { _, err := anonymous.GetUser(ctx, 999); return err },
+			client.ErrNotFound, http.StatusNotFound, "user_not_found"},
+		{"没有认证", func() error { _, err := anonymous.CreateUser(ctx, client.User{Name: "王五", Age: 28}); return err },
+			client.ErrUnauthorized, http.StatusUnauthorized, "authentication_required"},
+		{"API key 错误", func() error { return badKey.DeleteUser(ctx, 2) },
+			client.ErrUnauthorized, http.StatusUnauthorized, "invalid_api_key"},
+		{"密码错误", func() error { _, err := anonymous.Login(ctx, "admin", "wrong"); return err },
+			client.ErrUnauthorized, http.StatusUnauthorized, "invalid_credentials"},
+		{"权限不足", func() error { return viewer.DeleteUser(ctx, 2) },
+			client.ErrForbidden, http.StatusForbidden, "forbidden"},
+		{"校验失败", func() error { _, err := editor.CreateUser(ctx, client.User{Name: "", Age: -1}); return err },
+			client.ErrValidation, http.StatusUnprocessableEntity, "validation_failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if !errors.Is(err, tt.target) {
+				t.Fatalf("错误 %v 不满足 errors.Is(err, %v)", err, tt.target)
+			}
+			var apiErr *client.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("错误 %T 不是 *client.APIError", err)
+			}
+			if apiErr.Status != tt.status || apiErr.Code != tt.code {
+				t.Fatalf("状态码和错误码是 %d %s，应该是 %d %s", apiErr.Status, apiErr.Code, tt.status, tt.code)
+			}
+		})
+	}
+
+	t.Run("字段错误", func(t *testing.T) {
+		_, err := editor.CreateUser(ctx, client.User{Name: "", Age: 28})
+		var apiErr *client.APIError
+		if !errors.As(err, &apiErr) || len(apiErr.Errors) != 1 || apiErr.Errors[0].Field != "name" {
+			t.Fatalf("错误 %#v 应该只有 name 字段的错误", err)
+		}
+	})
+	t.Run("缺少的权限", func(t *testing.T) {
+		err := viewer.DeleteUser(ctx, 2)
+		var apiErr *client.APIError
+		if !errors.As(err, &apiErr) || apiErr.Permission != "users:delete" {
+			t.Fatalf("错误 %#v 应该说明缺少 users:delete", err)
+		}
+	})
+}
+
+func TestClientPagination(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	c, rec := newTestClient(t, srv, client.WithAPIKey(testEditorKey))
+	for i := range 5 {
+		if _, err := c.CreateUser(ctx, client.User{Name: "用户" + string(rune('A'+i)), Age: 20 + i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := c.ListUsers(ctx, client.ListOptions{PageSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Users) != 3 || p.Total != 7 || p.Next == "" {
+		t.Fatalf("第一页有 %d 个用户，总数 %d，下一页 %q", len(p.Users), p.Total, p.Next)
+	}
+
+	for _, opts := range []client.ListOptions{{PageSize: 3}, {PageSize: 3, After: 1}} {
+		before := rec.count("GET /users")
+		var ids []int
+		for u, err := range c.AllUsers(ctx, opts) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, u.ID)
+		}
+		want := 7 - opts.After
+		if len(ids) != want {
+			t.Fatalf("%+v: 得到 %d 个用户 %v，应该是 %d 个", opts, len(ids), ids, want)
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] <= ids[i-1] {
+				t.Fatalf("%+v: 用户重复或者顺序不对 %v", opts, ids)
+			}
+		}
+		if pages := rec.count("GET /users") - before; pages != (want+2)/3 && pages != (want+2)/3+1 {
+			t.Fatalf("%+v: 请求了 %d 页", opts, pages)
+		}
+	}
+}
+
+func TestClientRejectsForeignLink(t *testing.T) {
+	var leaked sync.Map
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked.Store(r.Header.Get("X-API-Key")+r.Header.Get("Authorization"), true)
+		io.WriteString(w, "[]")
+	}))
+	defer foreign.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "<"+foreign.URL+`/users?page=2>; rel="next"`)
+		io.WriteString(w, `[{"id":1,"name":"张三","age":25}]`)
+	}))
+	defer srv.Close()
+
+	c, err := client.New(srv.URL, client.WithAPIKey(testEditorKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	var lastErr error
+	for _, err := range c.AllUsers(context.Background(), client.ListOptions{}) {
+		if err != nil {
+			lastErr = err
+			break
+		}
+		n++
+	}
+	if n != 1 || lastErr == nil {
+		t.Fatalf("得到 %d 个用户，错误 %v；应该在第二页之前出错", n, lastErr)
+	}
+	leaked.Range(func(key, _ any) bool {
+		t.Fatalf("别的服务器收到了请求，认证信息 %q", key)
+		return false
+	})
+}
+
+func TestAPIDoc(t *testing.T) {
+	first := newTestServer(t)
+	newTestServer(t) // 每个服务器的文档由自己的路由器生成，再创建一个不影响前一个
+
+	resp, err := http.Get(first.URL + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var doc struct {
+		Paths map[string]any `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/users", "/users/{id}", "/login/refresh"} {
+		if doc.Paths[path] == nil {
+			t.Errorf("文档中没有 %s", path)
+		}
+	}
+
+	resp, err = http.Get(first.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("首页返回 %d %s", resp.StatusCode, ct)
+	}
+}
+
+var update = flag.Bool("update", false, "用当前的输出更新 testdata 中的 golden 文件")
+
+// apiSpec 生成的文档和 testdata/openapi.json 一致，修改路由或文档之后用 go test -run TestAPISpec -update 更新，
+// 在 diff 中检查文档的变化
+func TestAPISpec(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+	golden := filepath.Join("testdata", "openapi.json")
+	if *update {
+		if err := os.WriteFile(golden, rec.Body.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Body.String(); got != string(want) {
+		t.Fatalf("文档和 %s 不一致，确认修改无误后用 -update 更新:\n%s", golden, got)
+	}
+}
+
+// server.go 中的 policy 和 userRole：用户可以修改自己，被删除之后就没有任何权限了
+func TestPolicy(t *testing.T) {
+	newTestServer(t)
+	ctx := context.Background()
+	viewer, err := users.Create(ctx, store.User{Name: "王五", Age: 28, Role: store.RoleViewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := func(id int) auth.Principal { return auth.Principal{Subject: "u", UserID: id, Method: auth.MethodJWT} }
+	patch := func(id int) *http.Request {
+		r := httptest.NewRequest(http.MethodPatch, "/users/"+strconv.Itoa(id), nil)
+		r.Pattern = "/users/{id}"
+		r.SetPathValue("id", strconv.Itoa(id))
+		return r
+	}
+	check := func(p auth.Principal, r *http.Request) error {
+		role, err := userRole(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return policy.Check(r, authz.Subject{Principal: p, Role: role})
+	}
+
+	tests := []struct {
+		name    string
+		p       auth.Principal
+		target  int
+		allowed bool
+	}{
+		{"viewer 修改自己", login(viewer.ID), viewer.ID, true},
+		{"viewer 修改别人", login(viewer.ID), 2, false},
+		{"editor 修改别人", login(2), viewer.ID, true},
+		{"viewer API key 修改用户", auth.Principal{Subject: "viewer", Method: auth.MethodAPIKey, Role: store.RoleViewer}, viewer.ID, false},
+	}
+	for _, tt := range tests {
+		if err := check(tt.p, patch(tt.target)); (err == nil) != tt.allowed {
+			t.Errorf("%s: Check 返回 %v", tt.name, err)
+		}
+	}
+
+	if err := users.Delete(ctx, viewer.ID); err != nil {
+		t.Fatal(err)
+	}
+	if role, err := userRole(ctx, login(viewer.ID)); role != "" || err != nil {
+		t.Fatalf("删除之后 userRole 返回 %q, %v", role, err)
+	}
+	var denied *authz.DeniedError
+	if err := check(login(viewer.ID), patch(viewer.ID)); !errors.As(err, &denied) || denied.Permission != authz.UsersUpdate {
+		t.Fatalf("删除之后修改自己返回 %v", err)
+	}
+}
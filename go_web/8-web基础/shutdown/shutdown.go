@@ -0,0 +1,93 @@
+// shutdown 包负责让 HTTP 服务器优雅地退出：收到 SIGINT/SIGTERM 后不再接受新连接，
+// 等待正在处理的请求完成，再执行清理函数（例如把数据写回磁盘），最后返回一个有意义的退出码。
+//
+// 推荐的用法是让 main 只负责调用 os.Exit，这样其他函数中的 defer 都能正常执行（见 63-退出）：
+//
+//	func main() {
+//		os.Exit(shutdown.Run(srv, 10*time.Second, flush))
+//	}
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// 退出码
+const (
+	ExitOK      = 0 // 收到信号后正常退出
+	ExitServe   = 1 // 服务器没能启动或意外停止
+	ExitTimeout = 2 // 在期限内没有处理完所有请求，剩余连接被强制关闭
+	ExitHook    = 3 // 清理函数返回了错误
+)
+
+// Hook 是退出前执行的清理函数，ctx 在期限到达时取消。
+type Hook func(ctx context.Context) error
+
+// Signals 是触发优雅退出的信号。
+var Signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// Run 启动 srv 并阻塞，直到收到退出信号或服务器出错。之后在 timeout 内关闭服务器，
+// 再用 RunHooks 按顺序执行 hooks（每个各有 timeout 的期限），返回退出码。
+// 等待期间再收到一次信号会恢复默认行为，进程立即退出。
+func Run(srv *http.Server, timeout time.Duration, hooks ...Hook) int {
+	ctx, stop := signal.NotifyContext(context.Background(), Signals...)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+
+	code := ExitOK
+	select {
+	case err := <-errc:
+		slog.Error("服务器停止", "error", err)
+		code = ExitServe
+	case <-ctx.Done():
+		stop() // 不再拦截信号，再按一次 Ctrl+C 就直接退出
+		slog.Info("收到退出信号，开始优雅关闭", "timeout", timeout.String())
+	}
+
+	if err := Server(srv, timeout); err != nil {
+		slog.Error("关闭服务器超时，强制断开剩余连接", "error", err)
+		code = max(code, ExitTimeout)
+	}
+	if err := RunHooks(timeout, hooks...); err != nil {
+		slog.Error("执行清理函数失败", "error", err)
+		code = max(code, ExitHook)
+	}
+	return code
+}
+
+// Server 在 timeout 内优雅关闭 srv，超时后强制关闭剩余连接并返回错误。
+func Server(srv *http.Server, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+		return err
+	}
+	return nil
+}
+
+// RunHooks 按顺序执行所有清理函数，每个各有 timeout 的期限，一个超时或失败不影响后面的执行，返回所有错误。
+func RunHooks(timeout time.Duration, hooks ...Hook) error {
+	var errs []error
+	for _, h := range hooks {
+		errs = append(errs, runHook(h, timeout))
+	}
+	return errors.Join(errs...)
+}
+
+func runHook(h Hook, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return h(ctx)
+}
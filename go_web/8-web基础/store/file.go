@@ -0,0 +1,155 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// FileStore 在内存存储的基础上，每次写操作后把全部用户写入一个 JSON 文件，重启后从文件恢复。
+// 文件中同时记录最后分配的 ID，重启后也不会重复使用已删除用户的 ID。
+type FileStore struct {
+	*MemoryStore
+	path string
+
+	// writeMu 串行化写操作，保证后写入文件的一定是较新的数据
+	writeMu sync.Mutex
+}
+
+// NewFileStore 从 path 加载用户；文件不存在时使用初始数据并立即创建文件。
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		s.MemoryStore = NewMemoryStore(seedUsers()...)
+		if err := s.save(seedUsers(), s.ids.Last()); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		var f fileData
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+			err = json.Unmarshal(data, &f.Users) // 早期版本的文件只有用户数组
+		} else {
+			err = json.Unmarshal(data, &f)
+		}
+		if err != nil {
+			return nil, err
+		}
+		upgradeRoles(f.Users)
+		s.MemoryStore = NewMemoryStore(f.Users...)
+		s.ids.Observe(f.LastID)
+	}
+	return s, nil
+}
+
+// upgradeRoles 给早期版本文件中没有角色的用户补上角色：ID 为 1 的用户对应默认账号 admin，
+// 设为 admin，其他用户设为 viewer。和 9-数据库 中给 users 表加 role 列的迁移一致。
+func upgradeRoles(users []User) {
+	for i := range users {
+		if users[i].Role != "" {
+			continue
+		}
+		users[i].Role = RoleViewer
+		if users[i].ID == 1 {
+			users[i].Role = RoleAdmin
+		}
+	}
+}
+
+// 数据文件的格式
+type fileData struct {
+	LastID int    `json:"last_id"`
+	Users  []User `json:"users"`
+}
+
+// 写操作先在副本上修改并写入文件，写入成功后才替换内存中的数据。
+// 写文件失败时内存保持原样，返回的错误之外，调用方看不到这次修改。
+
+func (s *FileStore) Create(ctx context.Context, u User) (User, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	users, _ := s.MemoryStore.List(ctx)
+	u.ID = s.ids.Last() + 1
+	if err := s.commit(append(users, u), u.ID); err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+func (s *FileStore) Update(ctx context.Context, u User) (User, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	users, _ := s.MemoryStore.List(ctx)
+	i := slices.IndexFunc(users, func(x User) bool { return x.ID == u.ID })
+	if i < 0 {
+		return User{}, ErrNotFound
+	}
+	users[i] = u
+	if err := s.commit(users, s.ids.Last()); err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, id int) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	users, _ := s.MemoryStore.List(ctx)
+	i := slices.IndexFunc(users, func(x User) bool { return x.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	return s.commit(slices.Delete(users, i, i+1), s.ids.Last())
+}
+
+// Close 把数据最后写一次文件。每次写操作后已经保存过，这里保证退出前文件一定是最新的。
+func (s *FileStore) Close() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	users, _ := s.MemoryStore.List(context.Background())
+	return s.save(users, s.ids.Last())
+}
+
+// commit 把修改后的全部用户写入文件，成功后替换内存中的数据。调用前需要持有 writeMu。
+func (s *FileStore) commit(users []User, lastID int) error {
+	if err := s.save(users, lastID); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.users = users
+	s.mu.Unlock()
+	s.ids.Observe(lastID)
+	return nil
+}
+
+// save 先写临时文件再重命名，避免写到一半崩溃留下损坏的文件。
+func (s *FileStore) save(users []User, lastID int) error {
+	data, err := json.MarshalIndent(fileData{LastID: lastID, Users: users}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
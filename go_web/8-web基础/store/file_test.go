@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.json")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wang, err := s.Create(ctx, User{Name: "王五", Age: 28, Role: RoleViewer})
+	if err != nil || wang.ID != 3 {
+		t.Fatalf("Create 返回 %v, %v", wang, err)
+	}
+	if err := s.Delete(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开后数据和 ID 都从文件恢复，删除的 ID 不会重复使用
+	s, err = NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, _ := s.List(ctx)
+	if want := []User{seedUsers()[0], wang}; !slices.Equal(users, want) {
+		t.Fatalf("重新打开后是 %v，应该是 %v", users, want)
+	}
+	if u, _ := s.Create(ctx, User{Name: "赵六", Role: RoleViewer}); u.ID != 4 {
+		t.Fatalf("重新打开后分配的 ID 是 %d，应该是 4", u.ID)
+	}
+}
+
+// 写文件失败时内存中的数据不变，之后成功的写入也不会把失败的修改带进文件
+func TestFileStoreSaveFails(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "data")
+	os.Mkdir(dir, 0o755)
+	path := filepath.Join(dir, "users.json")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := s.List(ctx)
+
+	os.RemoveAll(dir) // 临时文件建不出来，save 失败
+	if u, err := s.Create(ctx, User{Name: "王五", Role: RoleViewer}); err == nil || u != (User{}) {
+		t.Fatalf("写文件失败时 Create 返回 %v, %v", u, err)
+	}
+	if _, err := s.Update(ctx, User{ID: 1, Name: "张三", Age: 99, Role: RoleAdmin}); err == nil {
+		t.Fatal("写文件失败时 Update 应该返回错误")
+	}
+	if err := s.Delete(ctx, 2); err == nil {
+		t.Fatal("写文件失败时 Delete 应该返回错误")
+	}
+	if after, _ := s.List(ctx); !slices.Equal(after, before) {
+		t.Fatalf("写文件失败后内存中是 %v，应该保持 %v", after, before)
+	}
+
+	os.Mkdir(dir, 0o755)
+	u, err := s.Create(ctx, User{Name: "赵六", Role: RoleViewer})
+	if err != nil || u.ID != 3 {
+		t.Fatalf("恢复后 Create 返回 %v, %v", u, err)
+	}
+	s, err = NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users, _ := s.List(ctx); !slices.Equal(users, append(before, u)) {
+		t.Fatalf("文件中是 %v，失败的修改不应该被保存", users)
+	}
+}
@@ -0,0 +1,29 @@
+package store
+
+import "sync/atomic"
+
+// IDAllocator 是单调递增的 ID 分配器，用原子操作实现（参考 36-原子计数器），
+// 可以在多个 Go 协程中同时使用。删除用户后它的 ID 不会被再次分配。
+type IDAllocator struct {
+	last atomic.Int64
+}
+
+// Next 返回一个新的 ID。
+func (a *IDAllocator) Next() int {
+	return int(a.last.Add(1))
+}
+
+// Observe 保证之后分配的 ID 都大于 id，加载已有数据时使用。
+func (a *IDAllocator) Observe(id int) {
+	for {
+		cur := a.last.Load()
+		if int64(id) <= cur || a.last.CompareAndSwap(cur, int64(id)) {
+			return
+		}
+	}
+}
+
+// Last 返回最近分配（或观察到）的 ID。
+func (a *IDAllocator) Last() int {
+	return int(a.last.Load())
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore 把用户保存在内存切片中，用读写锁保护（参考 37-互斥锁），重启后数据丢失。
+type MemoryStore struct {
+	mu    sync.RWMutex
+	users []User
+	ids   IDAllocator
+}
+
+// NewMemoryStore 创建内存存储，可以传入初始用户。
+func NewMemoryStore(users ...User) *MemoryStore {
+	s := &MemoryStore{users: append([]User(nil), users...)}
+	for _, u := range users {
+		s.ids.Observe(u.ID)
+	}
+	return s
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]User(nil), s.users...), nil // 返回副本，调用方修改不会影响存储
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id int) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if i := s.index(id); i >= 0 {
+		return s.users[i], nil
+	}
+	return User{}, ErrNotFound
+}
+
+func (s *MemoryStore) Create(ctx context.Context, u User) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u.ID = s.ids.Next()
+	s.users = append(s.users, u)
+	return u, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, u User) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(u.ID)
+	if i < 0 {
+		return User{}, ErrNotFound
+	}
+	s.users[i] = u
+	return u, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.users = append(s.users[:i], s.users[i+1:]...)
+	return nil
+}
+
+// index 返回 id 对应的下标，找不到返回 -1。调用前需要持有锁。
+func (s *MemoryStore) index(id int) int {
+	for i, u := range s.users {
+		if u.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SortField 描述一个排序字段，Desc 为 true 时降序。
+type SortField struct {
+	Name string
+	Desc bool
+}
+
+// Query 描述列表查询的过滤、排序和分页条件，零值表示返回全部用户。
+type Query struct {
+	Name   string // 名字包含的子串
+	MinAge *int
+	MaxAge *int
+
+	Sort []SortField // 依次比较，全部相等时按 ID 升序
+
+	// 分页有两种方式：Page 从 1 开始的页码；After 为上一页最后一个用户的 ID（游标）。
+	// PageSize 为 0 表示不分页。
+	Page     int
+	After    int
+	PageSize int
+}
+
+// ErrInvalidCursor 表示游标指向的用户不在过滤后的结果中。
+var ErrInvalidCursor = errors.New("无效的游标")
+
+// 允许排序的字段
+var sortKeys = map[string]func(a, b User) int{
+	"id":   func(a, b User) int { return cmp.Compare(a.ID, b.ID) },
+	"name": func(a, b User) int { return strings.Compare(a.Name, b.Name) },
+	"age":  func(a, b User) int { return cmp.Compare(a.Age, b.Age) },
+}
+
+// ParseSort 解析形如 "age,-name" 的排序参数，字段前的 "-" 表示降序。
+func ParseSort(s string) ([]SortField, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var fields []SortField
+	for _, part := range strings.Split(s, ",") {
+		f := SortField{Name: strings.TrimSpace(part)}
+		if rest, ok := strings.CutPrefix(f.Name, "-"); ok {
+			f.Name, f.Desc = rest, true
+		}
+		if _, ok := sortKeys[f.Name]; !ok {
+			return nil, fmt.Errorf("不支持按 %q 排序", f.Name)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// Apply 对 users 执行查询，返回当前页的用户和过滤后的总数。
+func (q Query) Apply(users []User) ([]User, int, error) {
+	matched := make([]User, 0, len(users))
+	for _, u := range users {
+		if q.Name != "" && !strings.Contains(u.Name, q.Name) {
+			continue
+		}
+		if q.MinAge != nil && u.Age < *q.MinAge {
+			continue
+		}
+		if q.MaxAge != nil && u.Age > *q.MaxAge {
+			continue
+		}
+		matched = append(matched, u)
+	}
+
+	slices.SortStableFunc(matched, func(a, b User) int {
+		for _, f := range q.Sort {
+			c := sortKeys[f.Name](a, b)
+			if f.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	total := len(matched)
+
+	start := 0
+	switch {
+	case q.After != 0:
+		i := slices.IndexFunc(matched, func(u User) bool { return u.ID == q.After })
+		if i < 0 {
+			return nil, total, ErrInvalidCursor
+		}
+		start = i + 1
+	case q.Page > 1 && q.PageSize > 0:
+		start = min((q.Page-1)*q.PageSize, total)
+	}
+	end := total
+	if q.PageSize > 0 {
+		end = min(start+q.PageSize, total)
+	}
+	return matched[start:end], total, nil
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// StatefulStore 使用 38-go状态协程 中的方法：用户数据只属于一个 Go 协程，
+// 其他协程通过通道发送 readOp / writeOp 请求，再从 resp 通道接收结果，因此不需要加锁。
+type StatefulStore struct {
+	reads     chan readOp
+	writes    chan writeOp
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// ErrClosed 表示存储已经关闭。
+var ErrClosed = errors.New("用户存储已关闭")
+
+type readOp struct {
+	all  bool // 读取全部用户，这时不使用 id
+	id   int
+	resp chan opResult
+}
+
+type writeKind int
+
+const (
+	opCreate writeKind = iota
+	opUpdate
+	opDelete
+)
+
+type writeOp struct {
+	kind writeKind
+	user User // 删除时只使用 user.ID
+	resp chan opResult
+}
+
+type opResult struct {
+	users []User
+	err   error
+}
+
+// NewStatefulStore 创建存储并启动拥有数据的 Go 协程，不再使用时调用 Close 让它退出。
+func NewStatefulStore(users ...User) *StatefulStore {
+	s := &StatefulStore{
+		reads:  make(chan readOp),
+		writes: make(chan writeOp),
+		done:   make(chan struct{}),
+	}
+	go s.loop(append([]User(nil), users...))
+	return s
+}
+
+// loop 是拥有数据的那个 Go 协程，依次响应到达的请求。
+func (s *StatefulStore) loop(users []User) {
+	var ids IDAllocator
+	for _, u := range users {
+		ids.Observe(u.ID)
+	}
+	index := func(id int) int {
+		for i, u := range users {
+			if u.ID == id {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for {
+		select {
+		case read := <-s.reads:
+			if read.all {
+				read.resp <- opResult{users: append([]User(nil), users...)}
+			} else if i := index(read.id); i >= 0 {
+				read.resp <- opResult{users: []User{users[i]}}
+			} else {
+				read.resp <- opResult{err: ErrNotFound}
+			}
+		case write := <-s.writes:
+			u := write.user
+			i := index(u.ID)
+			switch {
+			case write.kind == opCreate:
+				u.ID = ids.Next()
+				users = append(users, u)
+			case i < 0:
+				write.resp <- opResult{err: ErrNotFound}
+				continue
+			case write.kind == opUpdate:
+				users[i] = u
+			case write.kind == opDelete:
+				users = append(users[:i], users[i+1:]...)
+			}
+			write.resp <- opResult{users: []User{u}}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Close 停止拥有数据的 Go 协程，之后的所有操作都返回 ErrClosed。
+func (s *StatefulStore) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return nil
+}
+
+// read 和 write 发送请求并等待回复。resp 带一个缓冲，调用方因 ctx 取消提前返回时拥有协程也不会阻塞。
+func (s *StatefulStore) read(ctx context.Context, op readOp) (opResult, error) {
+	op.resp = make(chan opResult, 1)
+	select {
+	case s.reads <- op:
+	case <-s.done:
+		return opResult{}, ErrClosed
+	case <-ctx.Done():
+		return opResult{}, ctx.Err()
+	}
+	return s.wait(ctx, op.resp)
+}
+
+func (s *StatefulStore) write(ctx context.Context, kind writeKind, u User) (opResult, error) {
+	op := writeOp{kind: kind, user: u, resp: make(chan opResult, 1)}
+	select {
+	case s.writes <- op:
+	case <-s.done:
+		return opResult{}, ErrClosed
+	case <-ctx.Done():
+		return opResult{}, ctx.Err()
+	}
+	return s.wait(ctx, op.resp)
+}
+
+func (s *StatefulStore) wait(ctx context.Context, resp chan opResult) (opResult, error) {
+	select {
+	case res := <-resp:
+		return res, res.err
+	case <-ctx.Done():
+		return opResult{}, ctx.Err()
+	}
+}
+
+func (s *StatefulStore) List(ctx context.Context) ([]User, error) {
+	res, err := s.read(ctx, readOp{all: true})
+	return res.users, err
+}
+
+func (s *StatefulStore) Get(ctx context.Context, id int) (User, error) {
+	if id < 1 {
+		return User{}, ErrNotFound // ID 从 1 开始分配
+	}
+	res, err := s.read(ctx, readOp{id: id})
+	if err != nil {
+		return User{}, err
+	}
+	return res.users[0], nil
+}
+
+func (s *StatefulStore) Create(ctx context.Context, u User) (User, error) {
+	res, err := s.write(ctx, opCreate, u)
+	if err != nil {
+		return User{}, err
+	}
+	return res.users[0], nil
+}
+
+func (s *StatefulStore) Update(ctx context.Context, u User) (User, error) {
+	res, err := s.write(ctx, opUpdate, u)
+	if err != nil {
+		return User{}, err
+	}
+	return res.users[0], nil
+}
+
+func (s *StatefulStore) Delete(ctx context.Context, id int) error {
+	_, err := s.write(ctx, opDelete, User{ID: id})
+	return err
+}
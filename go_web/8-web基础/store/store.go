@@ -0,0 +1,64 @@
+// store 包定义了用户数据的存储接口，以及内存和文件两种实现。
+
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// User 用户结构体
+type User struct {
+	ID   int    `json:"id"`
+	Name string `json:"name" validate:"required,max=50"`
+	Age  int    `json:"age" validate:"min=0,max=150"`
+	Role string `json:"role" validate:"oneof=admin editor viewer"`
+}
+
+// 用户的角色，决定能做哪些操作，见 authz 包。新用户默认是 viewer。
+const (
+	RoleAdmin  = "admin"  // 管理所有用户，包括修改角色和删除
+	RoleEditor = "editor" // 创建和修改用户
+	RoleViewer = "viewer" // 只能查看，以及修改自己的资料
+)
+
+// ValidRole 判断 role 是不是上面三种角色之一
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleEditor || role == RoleViewer
+}
+
+// ErrNotFound 表示要操作的用户不存在。
+var ErrNotFound = errors.New("用户不存在")
+
+// UserRepository 是用户存储的统一接口，处理函数只依赖这个接口，不关心数据放在哪里。
+type UserRepository interface {
+	List(ctx context.Context) ([]User, error)
+	Get(ctx context.Context, id int) (User, error)
+	Create(ctx context.Context, u User) (User, error) // 由存储分配 ID，返回带 ID 的用户
+	Update(ctx context.Context, u User) (User, error) // 按 u.ID 整体替换
+	Delete(ctx context.Context, id int) error
+}
+
+// 初始数据，和最早的“模拟数据库”保持一致
+func seedUsers() []User {
+	return []User{
+		{ID: 1, Name: "张三", Age: 25, Role: RoleAdmin}, // 对应默认账号 admin
+		{ID: 2, Name: "李四", Age: 30, Role: RoleEditor},
+	}
+}
+
+// Open 根据名字选择存储实现：memory 为加锁的内存存储，stateful 为由单个 Go 协程拥有数据的内存存储，
+// file 为保存到 path 的 JSON 文件存储。所有实现都可以被多个 Go 协程同时使用。
+func Open(kind, path string) (UserRepository, error) {
+	switch kind {
+	case "memory", "":
+		return NewMemoryStore(seedUsers()...), nil
+	case "stateful":
+		return NewStatefulStore(seedUsers()...), nil
+	case "file":
+		return NewFileStore(path)
+	default:
+		return nil, fmt.Errorf("未知的存储类型: %q", kind)
+	}
+}
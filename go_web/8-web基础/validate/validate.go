@@ -0,0 +1,258 @@
+// validate 包根据结构体字段上的 validate 标签校验数据，例如：
+//
+//	type User struct {
+//		Name string `json:"name" validate:"required,max=50"`
+//		Age  int    `json:"age" validate:"min=0,max=150"`
+//	}
+//
+// 支持的规则：
+//
+//	required  不能是零值（字符串去掉首尾空白后不能为空）
+//	min=N     数字不小于 N；字符串、切片的长度不小于 N
+//	max=N     数字不大于 N；字符串、切片的长度不大于 N
+//	oneof=a b 值必须是列出的几个之一
+//
+// 多个规则用逗号分隔，标签为 "-" 的字段不校验，嵌套的结构体会递归校验。
+// 指针字段校验指向的值；nil 表示没有提供，只有 required 不通过，其他规则跳过。
+// 错误说明默认是中文，可以用 FieldError.Localize 换成其他语言。
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError 描述一个字段没有通过的规则。
+type FieldError struct {
+	Field   string `json:"field"` // 字段名，优先使用 json 标签中的名字，嵌套字段用 "." 连接
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+
+	key string // 错误说明在 messages 中的键，为空时使用 Rule
+}
+
+// NewFieldError 创建一个字段错误，用于校验规则以外的检查（例如只读字段、未知字段），
+// rule 需要是 messages 中已有的键。
+func NewFieldError(field, rule, param string) FieldError {
+	return newFieldError(field, rule, param, rule)
+}
+
+// NewLenError 创建字符串长度不符合 min 或 max 的错误。
+func NewLenError(field, rule, param string) FieldError {
+	return newFieldError(field, rule, param, rule+".len")
+}
+
+func newFieldError(field, rule, param, key string) FieldError {
+	fe := FieldError{Field: field, Rule: rule, Param: param, key: key}
+	return fe.Localize(DefaultLang)
+}
+
+// Localize 返回使用 lang 语言说明的错误副本，不支持的语言使用 DefaultLang。
+func (e FieldError) Localize(lang string) FieldError {
+	msgs, ok := messages[lang]
+	if !ok {
+		lang, msgs = DefaultLang, messages[DefaultLang]
+	}
+	key := e.key
+	if key == "" {
+		key = e.Rule
+	}
+	format, ok := msgs[key]
+	if !ok {
+		return e
+	}
+
+	param := e.Param
+	if e.Rule == "oneof" {
+		sep := "、"
+		if lang == "en" {
+			sep = ", "
+		}
+		param = strings.Join(strings.Fields(param), sep)
+	}
+	if strings.Contains(format, "%s") {
+		e.Message = fmt.Sprintf(format, param)
+	} else {
+		e.Message = format
+	}
+	return e
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors 是一组字段错误，Struct 校验失败时返回这个类型。
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Localize 把所有错误说明换成 lang 语言。
+func (e Errors) Localize(lang string) Errors {
+	out := make(Errors, len(e))
+	for i, fe := range e {
+		out[i] = fe.Localize(lang)
+	}
+	return out
+}
+
+// DefaultLang 是默认的错误说明语言。
+const DefaultLang = "zh"
+
+// 各语言的错误说明，键为规则名，长度相关的规则加 ".len" 后缀
+var messages = map[string]map[string]string{
+	"zh": {
+		"required": "不能为空",
+		"min":      "不能小于 %s",
+		"min.len":  "长度不能小于 %s",
+		"max":      "不能大于 %s",
+		"max.len":  "长度不能大于 %s",
+		"oneof":    "必须是 %s 之一",
+		"readonly": "由服务器生成，不能指定",
+		"type":     "类型应为 %s",
+		"unknown":  "未知字段",
+		"url":      "必须是 http:// 或 https:// 开头的地址",
+	},
+	"en": {
+		"required": "must not be empty",
+		"min":      "must be at least %s",
+		"min.len":  "must be at least %s characters long",
+		"max":      "must be at most %s",
+		"max.len":  "must be at most %s characters long",
+		"oneof":    "must be one of %s",
+		"readonly": "is generated by the server and cannot be set",
+		"type":     "must be of type %s",
+		"unknown":  "is not a known field",
+		"url":      "must be an http:// or https:// URL",
+	},
+}
+
+// Struct 校验结构体（或结构体指针）v，全部通过时返回 nil，否则返回 Errors。
+// 标签写错属于程序错误，会直接 panic。
+func Struct(v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: 需要结构体，得到 %T", v))
+	}
+	var errs Errors
+	checkStruct(rv, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func checkStruct(rv reflect.Value, prefix string, errs *Errors) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		tag := sf.Tag.Get("validate")
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+		name := prefix + FieldName(sf)
+		fv := rv.Field(i)
+
+		if tag != "" {
+			for _, rule := range strings.Split(tag, ",") {
+				rule, param, _ := strings.Cut(rule, "=")
+				if key := check(fv, rule, param); key != "" {
+					*errs = append(*errs, newFieldError(name, rule, param, key))
+				}
+			}
+		}
+		if ev := deref(fv); ev.Kind() == reflect.Struct {
+			checkStruct(ev, name+".", errs)
+		}
+	}
+}
+
+// FieldName 返回字段在 JSON 中的名字，没有 json 标签时使用 Go 字段名。
+// 和 encoding/json 一样，json:"-," 的名字是 "-"。
+func FieldName(sf reflect.StructField) string {
+	tag := sf.Tag.Get("json")
+	if name, _, _ := strings.Cut(tag, ","); name != "" && tag != "-" {
+		return name
+	}
+	return sf.Name
+}
+
+// deref 返回指针最终指向的值，遇到 nil 指针时返回无效的 reflect.Value。
+func deref(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// check 检查单个规则，通过时返回空字符串，否则返回错误说明在 messages 中的键。
+func check(v reflect.Value, rule, param string) string {
+	v = deref(v)
+	switch rule {
+	case "required":
+		if !v.IsValid() || v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "" || v.IsZero() {
+			return rule
+		}
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validate: 规则 %s 的参数 %q 不是数字", rule, param))
+		}
+		if !v.IsValid() {
+			return ""
+		}
+		n, isLen := measure(v)
+		if rule == "min" && n >= limit || rule == "max" && n <= limit {
+			return ""
+		}
+		if isLen {
+			return rule + ".len"
+		}
+		return rule
+	case "oneof":
+		if !v.IsValid() {
+			return ""
+		}
+		s := fmt.Sprint(v.Interface())
+		for _, opt := range strings.Fields(param) {
+			if s == opt {
+				return ""
+			}
+		}
+		return rule
+	default:
+		panic(fmt.Sprintf("validate: 未知的规则 %q", rule))
+	}
+	return ""
+}
+
+// measure 返回数字的值，或者字符串、切片、map 的长度（此时 isLen 为 true）。
+func measure(v reflect.Value) (n float64, isLen bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	default:
+		panic(fmt.Sprintf("validate: %s 类型不支持 min/max", v.Type()))
+	}
+}
@@ -0,0 +1,146 @@
+package validate
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type user struct {
+	Name     string            `json:"name" validate:"required,max=5"`
+	Age      int               `json:"age" validate:"min=0,max=150"`
+	Score    float64           `validate:"max=100"`
+	Role     string            `json:"role" validate:"oneof=admin editor viewer"`
+	Level    uint              `json:"level" validate:"oneof=1 2 3"`
+	Tags     []string          `json:"tags" validate:"min=1,max=2"`
+	Labels   map[string]string `json:"labels,omitempty" validate:"max=1"`
+	Nick     *string           `json:"nick" validate:"min=2,max=5"`
+	Email    *string           `json:"email" validate:"required"`
+	Rank     *int              `json:"rank" validate:"min=1,oneof=1 2"`
+	Address  address           `json:"address"`
+	Previous *address          `json:"previous"`
+	Ignored  string            `validate:"-"`
+	internal string            `validate:"required"`
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// valid 返回一个全部通过的 user，测试用例在此基础上修改
+func valid() user {
+	return user{
+		Name: "张三", Age: 25, Role: "admin", Level: 1, Tags: []string{"a"},
+		Email: ptr("a@example.com"), Address: address{City: "北京"},
+	}
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(u *user)
+		want   []string // 字段:规则
+	}{
+		{"全部通过", func(u *user) {}, nil},
+		{"required", func(u *user) { u.Name = "  " }, []string{"name:required"}},
+		// 按字符数计算长度
+		{"max 长度", func(u *user) { u.Name = "一二三四五六" }, []string{"name:max"}},
+		{"max 边界", func(u *user) { u.Name = "一二三四五"; u.Age = 150; u.Score = 100 }, nil},
+		{"min 数字", func(u *user) { u.Age = -1 }, []string{"age:min"}},
+		{"max 数字", func(u *user) { u.Age = 151; u.Score = 100.5 }, []string{"age:max", "Score:max"}},
+		{"切片和 map 的长度", func(u *user) { u.Tags = nil; u.Labels = map[string]string{"a": "", "b": ""} }, []string{"tags:min", "labels:max"}},
+		{"oneof", func(u *user) { u.Role = "root"; u.Level = 4 }, []string{"role:oneof", "level:oneof"}},
+		// nil 指针只有 required 不通过，非 nil 指针校验指向的值
+		{"nil 指针", func(u *user) { u.Email = nil }, []string{"email:required"}},
+		{"指针指向空字符串", func(u *user) { u.Email = ptr("") }, []string{"email:required"}},
+		{"指针 min/max", func(u *user) { u.Nick = ptr("一") }, []string{"nick:min"}},
+		{"指针 oneof", func(u *user) { u.Rank = ptr(3) }, []string{"rank:oneof"}},
+		{"指针通过", func(u *user) { u.Nick = ptr("一二"); u.Rank = ptr(2) }, nil},
+		{"嵌套结构体", func(u *user) { u.Address.City = "" }, []string{"address.city:required"}},
+		{"嵌套结构体指针", func(u *user) { u.Previous = &address{} }, []string{"previous.city:required"}},
+		{"不校验的字段", func(u *user) { u.Ignored = ""; u.internal = "" }, nil},
+	}
+	for _, tt := range tests {
+		u := valid()
+		tt.modify(&u)
+		err := Struct(&u)
+		var errs Errors
+		if err != nil && !errors.As(err, &errs) {
+			t.Fatalf("%s: Struct 返回 %T", tt.name, err)
+		}
+		var got []string
+		for _, fe := range errs {
+			got = append(got, fe.Field+":"+fe.Rule)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: 得到 %v，应该是 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	u := valid()
+	u.Name, u.Age, u.Role, u.Nick = "", 200, "root", ptr("一二三四五六")
+	var errs Errors
+	if !errors.As(Struct(u), &errs) {
+		t.Fatal("Struct 应该返回 Errors")
+	}
+	errs = append(errs, NewFieldError("id", "readonly", ""), NewLenError("secret", "min", "32"))
+
+	zh := []string{
+		"name: 不能为空",
+		"age: 不能大于 150",
+		"role: 必须是 admin、editor、viewer 之一",
+		"nick: 长度不能大于 5",
+		"id: 由服务器生成，不能指定",
+		"secret: 长度不能小于 32",
+	}
+	en := []string{
+		"name: must not be empty",
+		"age: must be at most 150",
+		"role: must be one of admin, editor, viewer",
+		"nick: must be at most 5 characters long",
+		"id: is generated by the server and cannot be set",
+		"secret: must be at least 32 characters long",
+	}
+	for lang, want := range map[string][]string{"zh": zh, "en": en, "fr": zh} {
+		var got []string
+		for _, fe := range errs.Localize(lang) {
+			got = append(got, fe.Error())
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: 得到 %q，应该是 %q", lang, got, want)
+		}
+	}
+	// Localize 不修改原来的错误
+	if errs[0].Message != "不能为空" {
+		t.Fatalf("Localize 修改了原来的错误: %q", errs[0].Message)
+	}
+}
+
+func TestBadTag(t *testing.T) {
+	tests := []any{
+		struct {
+			A int `validate:"min=abc"`
+		}{},
+		struct {
+			A int `validate:"email"`
+		}{},
+		struct {
+			A bool `validate:"max=1"`
+		}{},
+		42,
+	}
+	for _, v := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Struct(%#v) 应该 panic", v)
+				}
+			}()
+			Struct(v)
+		}()
+	}
+}
@@ -0,0 +1,183 @@
+// migrate 管理 MySQL 的表结构版本：
+//
+//	go run ./cmd/migrate -dsn "user:password@tcp(127.0.0.1:3306)/test" up       执行所有未执行的迁移
+//	go run ./cmd/migrate -dsn ... up 1                                           只执行下一个迁移
+//	go run ./cmd/migrate -dsn ... down                                           回滚最近一个迁移，down all 全部回滚
+//	go run ./cmd/migrate -dsn ... status                                         查看每个迁移是否已执行
+//	go run ./cmd/migrate create add_user_email                                   在 migrations 目录中创建新的迁移文件
+//
+// 默认使用编译进程序的 migrations 目录，-dir 可以改为从磁盘读取。DSN 也可以用环境变量 WEB_MYSQL_DSN 提供。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"example.com/database"
+	"example.com/database/migrate"
+)
+
+// 退出码，约定同 go_example/63-退出
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dsn := fs.String("dsn", os.Getenv("WEB_MYSQL_DSN"), "MySQL DSN，默认读取环境变量 WEB_MYSQL_DSN")
+	dir := fs.String("dir", "", "迁移文件目录，为空时使用内置的迁移（create 默认写入 migrations）")
+	table := fs.String("table", migrate.DefaultTable, "记录已执行版本的表")
+	lockTimeout := fs.Duration("lock-timeout", 10*time.Second, "等待其他进程释放迁移锁的时间")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: migrate [flags] up [N|all] | down [N|all] | status | create NAME")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+
+	if cmd == "create" {
+		if len(rest) != 1 {
+			fmt.Fprintln(os.Stderr, "用法: migrate create NAME")
+			return exitUsage
+		}
+		target := *dir
+		if target == "" {
+			target = "migrations"
+		}
+		paths, err := migrate.Create(target, rest[0])
+		for _, p := range paths {
+			fmt.Println("创建", p)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "创建迁移失败:", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	var n int
+	switch cmd {
+	case "up", "down":
+		var err error
+		if n, err = parseCount(cmd, rest); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+	case "status":
+		if len(rest) != 0 {
+			fmt.Fprintln(os.Stderr, "用法: migrate status")
+			return exitUsage
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "未知命令 %q\n", cmd)
+		fs.Usage()
+		return exitUsage
+	}
+	if *dsn == "" {
+		fmt.Fprintln(os.Stderr, "需要 -dsn 或环境变量 WEB_MYSQL_DSN")
+		return exitUsage
+	}
+
+	migrations, err := loadMigrations(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "读取迁移文件失败:", err)
+		return exitError
+	}
+	db, err := database.OpenMySQL(*dsn, database.PoolOptions{MaxOpenConns: 2}, 5*time.Second)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	defer db.Close()
+
+	m := migrate.New(db, migrations)
+	m.Table = *table
+	m.LockTimeout = *lockTimeout
+	m.Log = os.Stdout
+
+	// Ctrl+C 时取消正在执行的语句，迁移锁随连接一起释放
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	switch cmd {
+	case "up":
+		done, err := m.Up(ctx, n)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "升级失败:", err)
+			return exitError
+		}
+		if len(done) == 0 {
+			fmt.Println("已经是最新版本")
+		}
+	case "down":
+		done, err := m.Down(ctx, n)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "回滚失败:", err)
+			return exitError
+		}
+		if len(done) == 0 {
+			fmt.Println("没有可以回滚的迁移")
+		}
+	case "status":
+		list, err := m.Status(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "查询状态失败:", err)
+			return exitError
+		}
+		for _, s := range list {
+			state := "未执行"
+			if s.Applied {
+				state = "已执行 " + s.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Printf("%-40s %s\n", s.Migration, state)
+		}
+	}
+	return exitOK
+}
+
+// up 默认执行全部，down 默认只回滚一个；all 表示全部
+func parseCount(cmd string, rest []string) (int, error) {
+	switch {
+	case len(rest) == 0 && cmd == "up":
+		return 0, nil
+	case len(rest) == 0:
+		return 1, nil
+	case len(rest) == 1 && rest[0] == "all":
+		return 0, nil
+	case len(rest) == 1:
+		n, err := strconv.Atoi(rest[0])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("迁移数量 %q 必须是正整数或 all", rest[0])
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("用法: migrate %s [N|all]", cmd)
+}
+
+func loadMigrations(dir string) ([]migrate.Migration, error) {
+	if dir == "" {
+		return database.Migrations()
+	}
+	return migrate.Load(os.DirFS(dir))
+}
@@ -0,0 +1,57 @@
+// mysql-server 启动 mysqlserver 包中的内存 MySQL 兼容服务器，没有安装 MySQL 时用于本地开发：
+//
+//	go run ./cmd/mysql-server -addr 127.0.0.1:3306 -db test
+//	go run ./cmd/migrate -dsn "root@tcp(127.0.0.1:3306)/test" up
+//	cd ../8-web基础 && go run server.go -store mysql -dsn "root@tcp(127.0.0.1:3306)/test?parseTime=true"
+//
+// 数据只保存在内存中，进程退出后丢失。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"example.com/database/mysqlserver"
+)
+
+// 退出码，约定同 go_example/63-退出
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet("mysql-server", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:3306", "监听地址")
+	db := fs.String("db", "test", "启动时创建的数据库")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	srv, err := mysqlserver.Start(*addr, *db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Printf("MySQL 兼容服务器监听在 %s，DSN 为 %s，按 Ctrl+C 退出\n", srv.Addr(), srv.DSN())
+	<-ctx.Done()
+	srv.Close()
+	fmt.Println("服务器已退出")
+	return exitOK
+}
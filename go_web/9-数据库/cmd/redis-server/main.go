@@ -0,0 +1,67 @@
+// redis-server 启动 redisserver 包中的内存 Redis 兼容服务器，没有安装 Redis 时用于本地开发：
+//
+//	go run ./cmd/redis-server -addr 127.0.0.1:6379
+//	redis-cli -p 6379 set name 张三
+//
+// 数据只保存在内存中，进程退出后丢失。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"example.com/database/redisserver"
+	"example.com/database/resp"
+)
+
+// 退出码，约定同 go_example/63-退出
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet("redis-server", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:6379", "监听地址")
+	password := fs.String("requirepass", os.Getenv("REDIS_PASSWORD"), "客户端需要提供的密码，默认读取环境变量 REDIS_PASSWORD")
+	maxBulkLen := fs.Int("proto-max-bulk-len", resp.DefaultMaxBulkLen, "客户端发来的单个字符串的长度上限（字节）")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	srv := redisserver.New(redisserver.Options{Password: *password, MaxBulkLen: *maxBulkLen})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	fmt.Printf("Redis 兼容服务器监听在 %s，按 Ctrl+C 退出\n", ln.Addr())
+	if err := srv.Serve(ln); !errors.Is(err, redisserver.ErrServerClosed) {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	fmt.Println("服务器已退出")
+	return exitOK
+}
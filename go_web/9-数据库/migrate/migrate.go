@@ -0,0 +1,412 @@
+// Package migrate 管理数据库结构的版本。每个版本由一对 SQL 文件描述：
+//
+//	0001_create_users.up.sql    升级
+//	0001_create_users.down.sql  回滚
+//
+// 已经执行过的版本记录在 schema_migrations 表中，同时保存 up 文件的 SHA-256，
+// 文件在执行后被修改会被发现。执行期间用 MySQL 的 GET_LOCK 加锁，两个进程不能同时迁移。
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultTable 是记录已执行版本的表名
+const DefaultTable = "schema_migrations"
+
+var (
+	ErrLocked   = errors.New("另一个进程正在执行迁移")
+	ErrChecksum = errors.New("已执行的迁移文件被修改")
+	ErrNoDown   = errors.New("迁移没有 down 文件，不能回滚")
+)
+
+// Migration 是一个版本的升级和回滚语句
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string // 为空表示不能回滚
+	Checksum string // Up 的 SHA-256
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+var fileRe = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load 从 fsys 的根目录读取迁移文件，按版本号排序。
+// 每个版本必须有 up 文件，down 文件可选；其他文件被忽略。
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		m := fileRe.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: 版本号不合法: %w", e.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("版本 %d 有两个名字: %s 和 %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(data)
+			sum := sha256.Sum256(data)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Checksum == "" {
+			return nil, fmt.Errorf("迁移 %s 缺少 up 文件", mig)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Create 在 dir 中创建下一个版本的空 up/down 文件，返回创建的文件路径。
+func Create(dir, name string) ([]string, error) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), "_"))
+	if !regexp.MustCompile(`^[a-z0-9_]+$`).MatchString(name) {
+		return nil, fmt.Errorf("迁移名 %q 只能包含字母、数字和下划线", name)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	existing, err := Load(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+	var next int64 = 1
+	if len(existing) > 0 {
+		next = existing[len(existing)-1].Version + 1
+	}
+
+	base := Migration{Version: next, Name: name}.String()
+	var paths []string
+	for _, kind := range []string{"up", "down"} {
+		path := filepath.Join(dir, base+"."+kind+".sql")
+		content := fmt.Sprintf("-- %s: %s\n", base, kind)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644) // 不覆盖已有文件
+		if err != nil {
+			return paths, err
+		}
+		_, err = io.WriteString(f, content)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// Status 是一个版本的执行情况
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator 在一个 MySQL 数据库上执行迁移
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+
+	Table       string        // 版本表名，默认 DefaultTable
+	LockTimeout time.Duration // 等待其他进程释放锁的最长时间，默认 10 秒
+	Log         io.Writer     // 输出执行进度，为 nil 时不输出
+}
+
+// New 创建 Migrator，migrations 通常来自 Load。
+func New(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{
+		db:          db,
+		migrations:  migrations,
+		Table:       DefaultTable,
+		LockTimeout: 10 * time.Second,
+	}
+}
+
+// applied 是版本表中的一行
+type applied struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// Up 按版本顺序执行还没执行过的迁移，n <= 0 表示全部执行，返回执行了的迁移。
+func (m *Migrator) Up(ctx context.Context, n int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, records map[int64]applied) error {
+		for _, mig := range m.migrations {
+			if n > 0 && len(done) == n {
+				break
+			}
+			if _, ok := records[mig.Version]; ok {
+				continue
+			}
+			m.logf("升级 %s\n", mig)
+			if err := m.run(ctx, conn, mig.Up); err != nil {
+				return fmt.Errorf("升级 %s: %w", mig, err)
+			}
+			_, err := conn.ExecContext(ctx,
+				"INSERT INTO "+m.Table+" (version, name, checksum) VALUES (?, ?, ?)",
+				mig.Version, mig.Name, mig.Checksum)
+			if err != nil {
+				return fmt.Errorf("记录 %s: %w", mig, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down 从最新的版本开始回滚 n 个已执行的迁移，n <= 0 表示全部回滚，返回回滚了的迁移。
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, records map[int64]applied) error {
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if n > 0 && len(done) == n {
+				break
+			}
+			if _, ok := records[mig.Version]; !ok {
+				continue
+			}
+			if strings.TrimSpace(mig.Down) == "" {
+				return fmt.Errorf("%s: %w", mig, ErrNoDown)
+			}
+			m.logf("回滚 %s\n", mig)
+			if err := m.run(ctx, conn, mig.Down); err != nil {
+				return fmt.Errorf("回滚 %s: %w", mig, err)
+			}
+			if _, err := conn.ExecContext(ctx, "DELETE FROM "+m.Table+" WHERE version = ?", mig.Version); err != nil {
+				return fmt.Errorf("删除记录 %s: %w", mig, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status 返回每个版本是否已执行。它同样会校验已执行迁移的 checksum。
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var list []Status
+	err := m.locked(ctx, func(conn *sql.Conn, records map[int64]applied) error {
+		for _, mig := range m.migrations {
+			rec, ok := records[mig.Version]
+			list = append(list, Status{Migration: mig, Applied: ok, AppliedAt: rec.appliedAt})
+		}
+		return nil
+	})
+	return list, err
+}
+
+// locked 拿到一个专用连接并加锁，确保版本表存在、校验 checksum 后执行 fn。
+// GET_LOCK 的锁属于会话，所以加锁、迁移和解锁都必须用同一个连接。
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, records map[int64]applied) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	lockName := "migrate:" + m.Table
+	var got sql.NullInt64
+	timeout := int(m.LockTimeout / time.Second)
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, timeout).Scan(&got); err != nil {
+		return fmt.Errorf("加锁: %w", err)
+	}
+	if got.Int64 != 1 {
+		return ErrLocked
+	}
+	defer func() {
+		// 不使用 ctx：即使 ctx 已经取消也要释放锁
+		var released sql.NullInt64
+		rerr := conn.QueryRowContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName).Scan(&released)
+		if rerr != nil && err == nil {
+			err = fmt.Errorf("解锁: %w", rerr)
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+m.Table+` (
+		version    BIGINT       NOT NULL PRIMARY KEY,
+		name       VARCHAR(255) NOT NULL,
+		checksum   CHAR(64)     NOT NULL,
+		applied_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("创建版本表: %w", err)
+	}
+
+	records, err := m.records(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if err := m.verify(records); err != nil {
+		return err
+	}
+	return fn(conn, records)
+}
+
+// records 读取版本表
+func (m *Migrator) records(ctx context.Context, conn *sql.Conn) (map[int64]applied, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, checksum, UNIX_TIMESTAMP(applied_at) FROM "+m.Table)
+	if err != nil {
+		return nil, fmt.Errorf("读取版本表: %w", err)
+	}
+	defer rows.Close()
+
+	records := make(map[int64]applied)
+	for rows.Next() {
+		var (
+			version int64
+			rec     applied
+			unix    int64
+		)
+		if err := rows.Scan(&version, &rec.checksum, &unix); err != nil {
+			return nil, err
+		}
+		rec.appliedAt = time.Unix(unix, 0)
+		records[version] = rec
+	}
+	return records, rows.Err()
+}
+
+// verify 检查已执行的迁移都还有对应文件，并且文件没有被修改
+func (m *Migrator) verify(records map[int64]applied) error {
+	known := make(map[int64]bool, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		rec, ok := records[mig.Version]
+		if ok && rec.checksum != mig.Checksum {
+			return fmt.Errorf("%s: %w", mig, ErrChecksum)
+		}
+	}
+	for version := range records {
+		if !known[version] {
+			return fmt.Errorf("数据库中的版本 %d 没有对应的迁移文件", version)
+		}
+	}
+	return nil
+}
+
+// run 逐条执行 script 中的语句。MySQL 的 DDL 会隐式提交，放进事务也无法回滚，
+// 所以这里不使用事务，一个迁移文件最好只做一件事。
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, stmt := range Split(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) logf(format string, args ...any) {
+	if m.Log != nil {
+		fmt.Fprintf(m.Log, format, args...)
+	}
+}
+
+// Split 按分号把脚本拆成单条语句。引号中的内容原样保留，其中的分号、-- 和 # 都不起作用；
+// 注释的规则同 MySQL：-- 后面必须跟空白才是注释（1--1 是减法），/* */ 中的分号不拆分。
+// 驱动默认不允许一次执行多条语句 (multiStatements=false)，所以需要拆开。
+func Split(script string) []string {
+	var (
+		stmts []string
+		cur   strings.Builder
+		quote rune // 当前所在引号，0 表示不在引号中
+	)
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		cur.Reset()
+	}
+
+	runes := []rune(script)
+	next := func(i int) rune {
+		if i+1 < len(runes) {
+			return runes[i+1]
+		}
+		return 0
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			// 引号中的 '' 是转义的引号，和关闭之后马上打开的效果一样，不需要特殊处理
+			cur.WriteRune(r)
+			if r == '\\' && quote != '`' && i+1 < len(runes) {
+				i++
+				cur.WriteRune(runes[i])
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+			cur.WriteRune(r)
+		case r == '#' || r == '-' && next(i) == '-' && (i+2 == len(runes) || unicode.IsSpace(runes[i+2])):
+			for i < len(runes) && runes[i] != '\n' { // 跳过注释直到行尾
+				i++
+			}
+			cur.WriteRune('\n')
+		case r == '/' && next(i) == '*':
+			// 块注释原样保留（/*! ... */ 在 MySQL 中会被执行），其中的分号不拆分
+			j := i + 2
+			for j+1 < len(runes) && !(runes[j] == '*' && runes[j+1] == '/') {
+				j++
+			}
+			j = min(j+2, len(runes))
+			cur.WriteString(string(runes[i:j]))
+			i = j - 1
+		case r == ';':
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return stmts
+}
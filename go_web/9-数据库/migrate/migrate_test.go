@@ -0,0 +1,35 @@
+package migrate
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		script string
+		want   []string
+	}{
+		{"CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);", []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}},
+		{"-- 注释; 不是语句\nSELECT 1; # 行尾注释\n", []string{"SELECT 1"}},
+		// 引号中的 --、#、分号都是字符串的一部分
+		{"INSERT INTO t VALUES ('a -- b; c'); SELECT 2", []string{"INSERT INTO t VALUES ('a -- b; c')", "SELECT 2"}},
+		{`INSERT INTO t VALUES ("# 不是注释;")`, []string{`INSERT INTO t VALUES ("# 不是注释;")`}},
+		{"SELECT `a;--b` FROM t", []string{"SELECT `a;--b` FROM t"}},
+		// 转义的引号不会结束字符串
+		{`SELECT 'it\'s -- x; y'; SELECT 3`, []string{`SELECT 'it\'s -- x; y'`, "SELECT 3"}},
+		{"SELECT 'it''s -- x; y'; SELECT 4", []string{"SELECT 'it''s -- x; y'", "SELECT 4"}},
+		// -- 后面没有空白时是两个减号
+		{"SELECT 1--1;", []string{"SELECT 1--1"}},
+		{"SELECT 1 --", []string{"SELECT 1"}},
+		// 块注释中的分号不拆分
+		{"/* a; b */ SELECT 5; /*!40101 SET NAMES utf8mb4 */;", []string{"/* a; b */ SELECT 5", "/*!40101 SET NAMES utf8mb4 */"}},
+		{"SELECT 6 /* 没有结束", []string{"SELECT 6 /* 没有结束"}},
+		{" ; \n;", nil},
+	}
+	for _, tt := range tests {
+		if got := Split(tt.script); !slices.Equal(got, tt.want) {
+			t.Errorf("Split(%q) = %q，应该是 %q", tt.script, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package database
+
+import (
+	"embed"
+	"io/fs"
+
+	"example.com/database/migrate"
+)
+
+// migrations 目录中的 SQL 文件编译进程序，部署时不需要额外拷贝
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations 返回内置的迁移，按版本号排序
+func Migrations() ([]migrate.Migration, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.Load(sub)
+}
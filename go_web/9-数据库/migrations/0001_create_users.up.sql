@@ -0,0 +1,7 @@
+-- 8-web基础 服务器使用的用户表，见 mysql.go
+CREATE TABLE users (
+    id   INT         NOT NULL AUTO_INCREMENT,
+    name VARCHAR(50) NOT NULL,
+    age  INT         NOT NULL DEFAULT 0,
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
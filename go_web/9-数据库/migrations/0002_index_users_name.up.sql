@@ -0,0 +1,2 @@
+-- GET /users?name= 按姓名过滤
+CREATE INDEX idx_users_name ON users (name);
@@ -0,0 +1,4 @@
+-- 用户角色 admin/editor/viewer，见 8-web基础 的 authz 包。
+-- 已有的用户设为 viewer，ID 为 1 的用户对应默认账号 admin，设为 admin。
+ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'viewer';
+UPDATE users SET role = 'admin' WHERE id = 1;
@@ -0,0 +1,3 @@
+-- 0002 为 GET /users?name= 建的索引没有用上：按名字过滤是在 Go 中做子串匹配，
+-- 即使改成 SQL 的 LIKE '%...%'，前导通配符也用不上 B-tree 索引，只会拖慢写入
+DROP INDEX idx_users_name ON users;
@@ -0,0 +1,219 @@
+// mysql.go 使用 database/sql 和 go-sql-driver/mysql 实现 8-web基础 中的 store.UserRepository 接口，
+// 服务器用 -store mysql -dsn "user:password@tcp(127.0.0.1:3306)/test?parseTime=true" 启动就会把用户保存到 MySQL。
+//
+// 用到的 users 表由 migrations 目录中的迁移创建和修改，启动服务器之前先执行迁移：
+//
+//	go run ./cmd/migrate -dsn "user:password@tcp(127.0.0.1:3306)/test" up
+//
+// 没有 MySQL 时可以用 mysqlserver 包在进程内启动一个兼容的服务器，或者 go run ./cmd/mysql-server。
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"example.com/web/store"
+)
+
+// PoolOptions 是连接池的配置，零值表示使用 database/sql 的默认值。
+type PoolOptions struct {
+	MaxOpenConns    int           // 最大打开连接数
+	MaxIdleConns    int           // 最大空闲连接数
+	ConnMaxLifetime time.Duration // 连接最大存活时间，应小于 MySQL 的 wait_timeout
+	ConnMaxIdleTime time.Duration // 空闲连接最长保留时间
+}
+
+// OpenMySQL 打开数据库、配置连接池，并在 timeout 内用 Ping 验证连接。
+func OpenMySQL(dsn string, pool PoolOptions, timeout time.Duration) (*sql.DB, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if pool.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("连接数据库失败: %w", err)
+	}
+	return db, nil
+}
+
+// MySQLStore 把用户保存在 MySQL 的 users 表中。所有语句在创建时预处理，每次操作都有超时。
+type MySQLStore struct {
+	db      *sql.DB
+	timeout time.Duration
+
+	list, get, insert, update, del *sql.Stmt
+}
+
+// NewMySQLStore 预处理所有语句。timeout 是每次操作的最长时间，为 0 时只受调用方 ctx 的限制。
+func NewMySQLStore(ctx context.Context, db *sql.DB, timeout time.Duration) (*MySQLStore, error) {
+	s := &MySQLStore{db: db, timeout: timeout}
+	stmts := []struct {
+		dst   **sql.Stmt
+		query string
+	}{
+		{&s.list, "SELECT id, name, age, role FROM users ORDER BY id"},
+		{&s.get, "SELECT id, name, age, role FROM users WHERE id = ?"},
+		{&s.insert, "INSERT INTO users (name, age, role) VALUES (?, ?, ?)"},
+		{&s.update, "UPDATE users SET name = ?, age = ?, role = ? WHERE id = ?"},
+		{&s.del, "DELETE FROM users WHERE id = ?"},
+	}
+	for _, st := range stmts {
+		stmt, err := db.PrepareContext(ctx, st.query)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("预处理语句 %q: %w", st.query, err)
+		}
+		*st.dst = stmt
+	}
+	return s, nil
+}
+
+// withTimeout 给每次操作加上超时
+func (s *MySQLStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+func (s *MySQLStore) List(ctx context.Context) ([]store.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.list.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []store.User{}
+	for rows.Next() {
+		var u store.User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Age, &u.Role); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *MySQLStore) Get(ctx context.Context, id int) (store.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var u store.User
+	err := s.get.QueryRowContext(ctx, id).Scan(&u.ID, &u.Name, &u.Age, &u.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.User{}, store.ErrNotFound
+	}
+	return u, err
+}
+
+func (s *MySQLStore) Create(ctx context.Context, u store.User) (store.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.insert.ExecContext(ctx, u.Name, u.Age, u.Role)
+	if err != nil {
+		return store.User{}, err
+	}
+	id, err := result.LastInsertId() // AUTO_INCREMENT 保证 ID 单调递增，删除后也不会重复
+	if err != nil {
+		return store.User{}, err
+	}
+	u.ID = int(id)
+	return u, nil
+}
+
+// Update 在事务中先锁住这一行再更新。MySQL 的 RowsAffected 在新旧值相同时返回 0，
+// 不能用来判断用户是否存在，所以先 SELECT ... FOR UPDATE 确认。
+func (s *MySQLStore) Update(ctx context.Context, u store.User) (store.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var id int
+		err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = ? FOR UPDATE", u.ID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return store.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.StmtContext(ctx, s.update).ExecContext(ctx, u.Name, u.Age, u.Role, u.ID)
+		return err
+	})
+	if err != nil {
+		return store.User{}, err
+	}
+	return u, nil
+}
+
+func (s *MySQLStore) Delete(ctx context.Context, id int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.del.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// inTx 在事务中执行 fn：fn 返回错误（或 panic）时回滚，否则提交。
+func (s *MySQLStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+	return fn(tx)
+}
+
+// Close 关闭预处理语句和数据库连接池。
+func (s *MySQLStore) Close() error {
+	for _, stmt := range []*sql.Stmt{s.list, s.get, s.insert, s.update, s.del} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"example.com/database/migrate"
+	"example.com/database/mysqlserver"
+	"example.com/web/store"
+)
+
+// newTestMySQLStore 在进程内的 MySQL 兼容服务器上执行所有迁移，返回使用它的 MySQLStore
+func newTestMySQLStore(t *testing.T) (*MySQLStore, *migrate.Migrator) {
+	t.Helper()
+	srv, err := mysqlserver.Start("127.0.0.1:0", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	ctx := context.Background()
+	db, err := OpenMySQL(srv.DSN(), PoolOptions{MaxOpenConns: 4}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := migrate.New(db, migrations)
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("执行迁移: %v", err)
+	}
+	s, err := NewMySQLStore(ctx, db, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, m
+}
+
+func TestMySQLStore(t *testing.T) {
+	s, _ := newTestMySQLStore(t)
+	ctx := context.Background()
+
+	zhang, err := s.Create(ctx, store.User{Name: "张三", Age: 25, Role: store.RoleAdmin})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	li, err := s.Create(ctx, store.User{Name: "李四", Age: 30, Role: store.RoleEditor})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if zhang.ID < 1 || li.ID <= zhang.ID {
+		t.Fatalf("ID 应该从 1 开始递增，得到 %d 和 %d", zhang.ID, li.ID)
+	}
+
+	list, err := s.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if want := []store.User{zhang, li}; !slices.Equal(list, want) {
+		t.Fatalf("List 返回 %v，应该是 %v", list, want)
+	}
+
+	got, err := s.Get(ctx, li.ID)
+	if err != nil || got != li {
+		t.Fatalf("Get(%d) 返回 %v, %v，应该是 %v", li.ID, got, err, li)
+	}
+	if _, err := s.Get(ctx, 999); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Get 不存在的用户返回 %v，应该是 ErrNotFound", err)
+	}
+
+	li.Age = 31
+	if _, err := s.Update(ctx, li); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	// 新旧值相同时 RowsAffected 为 0，用户仍然存在，不能返回 ErrNotFound
+	if _, err := s.Update(ctx, li); err != nil {
+		t.Fatalf("Update 相同的值: %v", err)
+	}
+	if got, _ := s.Get(ctx, li.ID); got.Age != 31 {
+		t.Fatalf("Update 之后年龄是 %d，应该是 31", got.Age)
+	}
+	if _, err := s.Update(ctx, store.User{ID: 999, Name: "王五", Role: store.RoleViewer}); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Update 不存在的用户返回 %v，应该是 ErrNotFound", err)
+	}
+
+	if err := s.Delete(ctx, zhang.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete(ctx, zhang.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("重复 Delete 返回 %v，应该是 ErrNotFound", err)
+	}
+	// AUTO_INCREMENT 不会重用删除的 ID
+	wang, err := s.Create(ctx, store.User{Name: "王五", Age: 28, Role: store.RoleViewer})
+	if err != nil || wang.ID <= li.ID {
+		t.Fatalf("删除之后 Create 返回 %v, %v", wang, err)
+	}
+}
+
+func TestMigrationsDown(t *testing.T) {
+	_, m := newTestMySQLStore(t)
+	ctx := context.Background()
+
+	undone, err := m.Down(ctx, 0)
+	if err != nil {
+		t.Fatalf("回滚迁移: %v", err)
+	}
+	migrations, _ := Migrations()
+	if len(undone) != len(migrations) {
+		t.Fatalf("回滚了 %d 个迁移，应该是 %d 个", len(undone), len(migrations))
+	}
+	// 回滚之后可以重新执行
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("重新执行迁移: %v", err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range status {
+		if !st.Applied {
+			t.Fatalf("迁移 %v 没有执行", st)
+		}
+	}
+}
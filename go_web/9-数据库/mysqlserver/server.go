@@ -0,0 +1,51 @@
+// Package mysqlserver 在进程内启动一个 MySQL 兼容服务器，用来在没有 MySQL 的环境中测试和开发。
+// 它使用 dolthub/go-mysql-server 的内存存储引擎，和 redisserver 一样，数据只保存在内存中：
+//
+//	srv, err := mysqlserver.Start("127.0.0.1:0", "test")
+//	defer srv.Close()
+//	db, err := database.OpenMySQL(srv.DSN(), database.PoolOptions{}, 5*time.Second)
+//
+// 它能执行 migrations 中的迁移和 MySQLStore 的所有语句，但不是真正的 MySQL，
+// 事务隔离和行锁与 InnoDB 不完全一致，并发相关的行为仍然要在 MySQL 上验证。
+package mysqlserver
+
+import (
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql"
+)
+
+// Server 是 MySQL 兼容服务器，用 root 用户、不需要密码连接
+type Server struct {
+	srv *server.Server
+	db  string
+}
+
+// Start 创建空的数据库 dbName，在 addr 上监听并在后台接受连接。
+// addr 为 "127.0.0.1:0" 时随机选择端口，用 Addr 查看。
+func Start(addr, dbName string) (*Server, error) {
+	pro := memory.NewDBProvider(memory.NewDatabase(dbName))
+	engine := sqle.NewDefault(pro)
+	srv, err := server.NewServer(server.Config{Protocol: "tcp", Address: addr}, engine, sql.NewContext, memory.NewSessionBuilder(pro), nil)
+	if err != nil {
+		return nil, err
+	}
+	go srv.Start()
+	return &Server{srv: srv, db: dbName}, nil
+}
+
+// Addr 返回监听地址
+func (s *Server) Addr() string {
+	return s.srv.Listener.Addr().String()
+}
+
+// DSN 返回 go-sql-driver/mysql 连接 Start 时创建的数据库使用的 DSN
+func (s *Server) DSN() string {
+	return "root@tcp(" + s.Addr() + ")/" + s.db + "?parseTime=true"
+}
+
+// Close 关闭监听和所有连接，数据随之丢失
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
@@ -1 +1,668 @@
-package main
+// redis.go 是一个不依赖第三方库的 Redis 客户端，协议的编解码在 resp 包中。
+// 支持 RESP2 和 RESP3、连接池、流水线和发布订阅，所有命令都接受 context，取消时立即返回。
+//
+//	r := database.NewRedis(database.RedisOptions{Addr: "127.0.0.1:6379"})
+//	defer r.Close()
+//	r.Set(ctx, "name", "张三", time.Minute)
+//	name, err := r.Get(ctx, "name")
+//
+// 没有 Redis 时可以用 redisserver 包在进程内启动一个兼容的服务器，或者 go run ./cmd/redis-server。
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"example.com/database/resp"
+)
+
+var (
+	// ErrNil 表示 key 或字段不存在，对应 Redis 返回的 null
+	ErrNil         = errors.New("redis: 键不存在")
+	ErrRedisClosed = errors.New("redis: 客户端已关闭")
+	// ErrTxFailed 表示 WATCH 的 key 在 EXEC 之前被修改了，事务中的命令都没有执行
+	ErrTxFailed = errors.New("redis: WATCH 的 key 已被修改，事务没有执行")
+)
+
+// RedisOptions 是客户端的配置，零值字段使用默认值
+type RedisOptions struct {
+	Addr     string // 默认 localhost:6379
+	Username string // Redis 6 的 ACL 用户名，为空时只用密码认证
+	Password string
+	DB       int
+	Protocol int // 2 或 3，默认 2；为 3 时用 HELLO 3 切换到 RESP3
+
+	PoolSize    int           // 最多同时打开的连接数，默认 10，不包括订阅使用的连接
+	DialTimeout time.Duration // 建立连接的超时时间，默认 5 秒
+	IdleTimeout time.Duration // 空闲超过这个时间的连接不再复用，默认 5 分钟
+}
+
+// Redis 是并发安全的客户端，内部维护一个连接池
+type Redis struct {
+	opts RedisOptions
+
+	slots chan struct{}   // 每个正在使用或空闲的连接占一个位置，限制连接总数
+	idle  chan *redisConn // 空闲连接
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewRedis 创建客户端，连接在第一次使用时才建立，可以用 Ping 检查服务器是否可用。
+func NewRedis(opts RedisOptions) *Redis {
+	if opts.Addr == "" {
+		opts.Addr = "localhost:6379"
+	}
+	if opts.Protocol == 0 {
+		opts.Protocol = 2
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 10
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = 5 * time.Minute
+	}
+	return &Redis{
+		opts:  opts,
+		slots: make(chan struct{}, opts.PoolSize),
+		idle:  make(chan *redisConn, opts.PoolSize),
+	}
+}
+
+// Do 执行任意命令，参数可以是字符串、[]byte、整数、浮点数或布尔值。
+// 服务器返回错误时，err 是 *resp.ReplyError。
+func (r *Redis) Do(ctx context.Context, args ...any) (resp.Value, error) {
+	vals, err := r.exec(ctx, [][]string{redisArgs(args)})
+	if err != nil {
+		return resp.Value{}, err
+	}
+	return vals[0], vals[0].Err()
+}
+
+// Pipeline 收集多条命令，Exec 时一次发送，再依次读取回复，只需要一次网络往返。
+type Pipeline struct {
+	r    *Redis
+	cmds [][]string
+}
+
+func (r *Redis) Pipeline() *Pipeline {
+	return &Pipeline{r: r}
+}
+
+// Do 把命令加入流水线
+func (p *Pipeline) Do(args ...any) {
+	p.cmds = append(p.cmds, redisArgs(args))
+}
+
+// Len 返回还没执行的命令数
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+// Exec 执行流水线中的命令并清空流水线。回复按命令顺序返回；
+// 单条命令的错误回复不影响其他命令，用回复的 Err 方法检查。err 只表示网络等整体错误。
+func (p *Pipeline) Exec(ctx context.Context) ([]resp.Value, error) {
+	cmds := p.cmds
+	p.cmds = nil
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+	return p.r.exec(ctx, cmds)
+}
+
+// Tx 是 Watch 占用的连接，WATCH、读取和 MULTI/EXEC 必须在同一个连接上执行。
+type Tx struct {
+	c    *redisConn
+	done bool // 已经执行过 EXEC，WATCH 随之取消
+}
+
+// Watch 从连接池取一个连接，WATCH keys 之后调用 fn，fn 用 tx.Do 读取数据、用 tx.Exec 提交修改。
+// keys 在 WATCH 之后被修改时 tx.Exec 返回 ErrTxFailed，通常的做法是重新执行整个 Watch：
+//
+//	for {
+//		err := r.Watch(ctx, func(tx *database.Tx) error {
+//			v, err := tx.Do(ctx, "GET", key)
+//			if err != nil {
+//				return err
+//			}
+//			n, _ := strconv.Atoi(v.Text()) // key 不存在时是 0
+//			_, err = tx.Exec(ctx, []any{"SET", key, n + 1})
+//			return err
+//		}, key)
+//		if !errors.Is(err, database.ErrTxFailed) {
+//			return err
+//		}
+//	}
+func (r *Redis) Watch(ctx context.Context, fn func(tx *Tx) error, keys ...string) error {
+	c, err := r.get(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.put(c)
+	vals, err := c.roundTrip(ctx, [][]string{append([]string{"WATCH"}, keys...)})
+	if err == nil {
+		err = vals[0].Err()
+	}
+	if err != nil {
+		return err
+	}
+
+	tx := &Tx{c: c}
+	err = fn(tx)
+	if !tx.done && !c.broken { // 没有执行 EXEC，取消 WATCH 之后连接才能给别人用
+		if vals, uerr := c.roundTrip(ctx, [][]string{{"UNWATCH"}}); uerr != nil || vals[0].Err() != nil {
+			c.broken = true
+		}
+	}
+	return err
+}
+
+// Do 在 WATCH 所在的连接上执行一条命令
+func (tx *Tx) Do(ctx context.Context, args ...any) (resp.Value, error) {
+	vals, err := tx.c.roundTrip(ctx, [][]string{redisArgs(args)})
+	if err != nil {
+		return resp.Value{}, err
+	}
+	return vals[0], vals[0].Err()
+}
+
+// Exec 把 cmds 放在 MULTI 和 EXEC 之间一次发送，原子地执行，返回每条命令的回复。
+// WATCH 的 key 已被修改时返回 ErrTxFailed。
+func (tx *Tx) Exec(ctx context.Context, cmds ...[]any) ([]resp.Value, error) {
+	tx.done = true
+	batch := make([][]string, 0, len(cmds)+2)
+	batch = append(batch, []string{"MULTI"})
+	for _, cmd := range cmds {
+		batch = append(batch, redisArgs(cmd))
+	}
+	batch = append(batch, []string{"EXEC"})
+
+	vals, err := tx.c.roundTrip(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	// 排队时的错误（例如参数个数不对）会让 EXEC 返回 EXECABORT，用它作为错误就够了
+	exec := vals[len(vals)-1]
+	switch {
+	case exec.Err() != nil:
+		return nil, exec.Err()
+	case exec.Nil():
+		return nil, ErrTxFailed
+	}
+	return exec.Elems, nil
+}
+
+// 常用命令
+
+func (r *Redis) Ping(ctx context.Context) error {
+	_, err := r.Do(ctx, "PING")
+	return err
+}
+
+// Get 读取字符串，key 不存在时返回 ErrNil
+func (r *Redis) Get(ctx context.Context, key string) (string, error) {
+	return stringReply(r.Do(ctx, "GET", key))
+}
+
+// Set 写入字符串，ttl 大于 0 时同时设置过期时间
+func (r *Redis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	args := []any{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", ttl.Milliseconds())
+	}
+	_, err := r.Do(ctx, args...)
+	return err
+}
+
+// Del 删除 key，返回实际删除的数量
+func (r *Redis) Del(ctx context.Context, keys ...string) (int64, error) {
+	return intReply(r.Do(ctx, append([]any{"DEL"}, stringsToAny(keys)...)...))
+}
+
+// Expire 设置过期时间，key 不存在时返回 false
+func (r *Redis) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	n, err := intReply(r.Do(ctx, "PEXPIRE", key, ttl.Milliseconds()))
+	return n == 1, err
+}
+
+// Incr 把 key 的值加一并返回新值，key 不存在时从 0 开始
+func (r *Redis) Incr(ctx context.Context, key string) (int64, error) {
+	return intReply(r.Do(ctx, "INCR", key))
+}
+
+// IncrBy 把 key 的值加上 n 并返回新值
+func (r *Redis) IncrBy(ctx context.Context, key string, n int64) (int64, error) {
+	return intReply(r.Do(ctx, "INCRBY", key, n))
+}
+
+// HSet 设置哈希的字段，fieldValues 是 field1, value1, field2, value2...，返回新增字段的数量
+func (r *Redis) HSet(ctx context.Context, key string, fieldValues ...string) (int64, error) {
+	if len(fieldValues) == 0 || len(fieldValues)%2 != 0 {
+		return 0, errors.New("redis: HSet 需要成对的字段和值")
+	}
+	return intReply(r.Do(ctx, append([]any{"HSET", key}, stringsToAny(fieldValues)...)...))
+}
+
+// HGet 读取哈希的一个字段，字段不存在时返回 ErrNil
+func (r *Redis) HGet(ctx context.Context, key, field string) (string, error) {
+	return stringReply(r.Do(ctx, "HGET", key, field))
+}
+
+// HGetAll 读取哈希的所有字段，key 不存在时返回空 map
+func (r *Redis) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	v, err := r.Do(ctx, "HGETALL", key)
+	if err != nil {
+		return nil, err
+	}
+	// RESP2 返回 field、value 交替的数组，RESP3 返回 Map，两者的 Elems 排列相同
+	m := make(map[string]string, len(v.Elems)/2)
+	for i := 0; i+1 < len(v.Elems); i += 2 {
+		m[v.Elems[i].Text()] = v.Elems[i+1].Text()
+	}
+	return m, nil
+}
+
+// LPush 把值依次插入列表头部，返回列表的新长度
+func (r *Redis) LPush(ctx context.Context, key string, values ...string) (int64, error) {
+	return intReply(r.Do(ctx, append([]any{"LPUSH", key}, stringsToAny(values)...)...))
+}
+
+// LRange 返回列表中 [start, stop] 范围的元素，负数表示从尾部数起
+func (r *Redis) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	v, err := r.Do(ctx, "LRANGE", key, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]string, len(v.Elems))
+	for i, e := range v.Elems {
+		items[i] = e.Text()
+	}
+	return items, nil
+}
+
+// Publish 向频道发送消息，返回收到消息的订阅者数量
+func (r *Redis) Publish(ctx context.Context, channel, message string) (int64, error) {
+	return intReply(r.Do(ctx, "PUBLISH", channel, message))
+}
+
+// Close 关闭空闲连接，之后的命令返回 ErrRedisClosed；正在使用的连接在归还时关闭。
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	for {
+		select {
+		case c := <-r.idle:
+			c.nc.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// exec 从连接池取一个连接执行命令，再把连接放回去
+func (r *Redis) exec(ctx context.Context, cmds [][]string) ([]resp.Value, error) {
+	c, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := c.roundTrip(ctx, cmds)
+	r.put(c)
+	return vals, err
+}
+
+// get 取一个空闲连接，没有时新建；连接数达到 PoolSize 时等待其他连接归还或 ctx 取消。
+func (r *Redis) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case r.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if r.isClosed() {
+		<-r.slots
+		return nil, ErrRedisClosed
+	}
+
+	for {
+		select {
+		case c := <-r.idle:
+			if time.Since(c.lastUsed) > r.opts.IdleTimeout {
+				c.nc.Close() // 服务器可能已经关闭了这个连接
+				continue
+			}
+			return c, nil
+		default:
+			c, err := r.dial(ctx)
+			if err != nil {
+				<-r.slots
+				return nil, err
+			}
+			return c, nil
+		}
+	}
+}
+
+// put 归还连接，出错的连接直接关闭
+func (r *Redis) put(c *redisConn) {
+	defer func() { <-r.slots }()
+	if c.broken || r.isClosed() {
+		c.nc.Close()
+		return
+	}
+	c.lastUsed = time.Now()
+	select {
+	case r.idle <- c:
+	default:
+		c.nc.Close()
+	}
+}
+
+func (r *Redis) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+// dial 建立连接并完成握手：切换协议版本、认证、选择数据库
+func (r *Redis) dial(ctx context.Context) (*redisConn, error) {
+	d := net.Dialer{Timeout: r.opts.DialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", r.opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{nc: nc, r: resp.NewReader(nc), w: resp.NewWriter(nc)}
+
+	var hello [][]string
+	if r.opts.Protocol >= 3 {
+		cmd := []string{"HELLO", "3"}
+		if r.opts.Password != "" {
+			cmd = append(cmd, "AUTH", orDefault(r.opts.Username, "default"), r.opts.Password)
+		}
+		hello = append(hello, cmd)
+	} else if r.opts.Password != "" {
+		cmd := []string{"AUTH", r.opts.Password}
+		if r.opts.Username != "" {
+			cmd = []string{"AUTH", r.opts.Username, r.opts.Password}
+		}
+		hello = append(hello, cmd)
+	}
+	if r.opts.DB != 0 {
+		hello = append(hello, []string{"SELECT", strconv.Itoa(r.opts.DB)})
+	}
+	if len(hello) == 0 {
+		return c, nil
+	}
+
+	vals, err := c.roundTrip(ctx, hello)
+	if err == nil {
+		for _, v := range vals {
+			if err = v.Err(); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("redis: 握手失败: %w", err)
+	}
+	return c, nil
+}
+
+// redisConn 是一个到服务器的连接，同一时间只被一个 goroutine 使用
+type redisConn struct {
+	nc       net.Conn
+	r        *resp.Reader
+	w        *resp.Writer
+	lastUsed time.Time
+	broken   bool // 读写出错或被取消后，连接上可能还有没读完的回复，不能再复用
+}
+
+// roundTrip 发送所有命令，再按顺序读取同样数量的回复。
+// ctx 的截止时间设置为连接的读写超时，ctx 被取消时把超时改成过去的时间，让阻塞的读写立即返回。
+func (c *redisConn) roundTrip(ctx context.Context, cmds [][]string) ([]resp.Value, error) {
+	c.nc.SetDeadline(deadlineOf(ctx)) // 没有截止时间时是零值，表示不超时
+	stop := context.AfterFunc(ctx, func() {
+		c.nc.SetDeadline(time.Unix(1, 0))
+	})
+	defer func() {
+		if !stop() && ctx.Err() != nil {
+			c.broken = true // AfterFunc 已经执行，连接的超时被改掉了
+		}
+	}()
+
+	for _, cmd := range cmds {
+		c.w.WriteCommand(cmd...)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, c.fail(ctx, err)
+	}
+
+	vals := make([]resp.Value, len(cmds))
+	for i := range vals {
+		v, err := c.r.ReadValue()
+		for err == nil && v.Type == resp.Push { // RESP3 中服务器主动推送的消息与命令无关，跳过
+			v, err = c.r.ReadValue()
+		}
+		if err != nil {
+			return nil, c.fail(ctx, err)
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+func (c *redisConn) fail(ctx context.Context, err error) error {
+	c.broken = true
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	// 连接的超时可能比 ctx 的计时器先触发一点
+	if deadline, ok := ctx.Deadline(); ok && errors.Is(err, os.ErrDeadlineExceeded) && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// Message 是订阅收到的消息
+type Message struct {
+	Channel string
+	Payload string
+}
+
+// Subscription 是一个订阅。订阅使用单独的连接，不占用连接池。
+type Subscription struct {
+	c    *redisConn
+	ch   chan Message
+	done chan struct{}
+
+	closeOnce sync.Once
+
+	mu   sync.Mutex
+	stop func() bool // 取消 ctx 的 AfterFunc
+	err  error
+}
+
+// Subscribe 订阅频道，收到的消息从 Channel() 读取。ctx 被取消或调用 Close 时订阅结束。
+func (r *Redis) Subscribe(ctx context.Context, channels ...string) (*Subscription, error) {
+	if len(channels) == 0 {
+		return nil, errors.New("redis: 至少需要订阅一个频道")
+	}
+	if r.isClosed() {
+		return nil, ErrRedisClosed
+	}
+	c, err := r.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 和 roundTrip 一样，ctx 的截止时间作为握手的超时，ctx 被取消时让阻塞的读写立即返回
+	c.nc.SetDeadline(deadlineOf(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		c.nc.SetDeadline(time.Unix(1, 0))
+	})
+
+	// 每个频道都会收到一条 subscribe 确认，它们和流水线中的回复一样按顺序读取
+	c.w.WriteCommand(append([]string{"SUBSCRIBE"}, channels...)...)
+	err = c.w.Flush()
+	for i := 0; err == nil && i < len(channels); i++ {
+		var v resp.Value
+		if v, err = c.r.ReadValue(); err == nil {
+			err = v.Err()
+		}
+	}
+	if !stop() && ctx.Err() != nil {
+		err = ctx.Err() // AfterFunc 已经执行，连接的超时被改掉了
+	}
+	if err != nil {
+		c.nc.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("redis: 订阅失败: %w", err)
+	}
+	c.nc.SetDeadline(time.Time{}) // 订阅期间等待消息没有超时
+
+	// 订阅完全建立之后才注册 AfterFunc：ctx 已经取消时它会立即在另一个协程中调用 Close，
+	// Close 通过 mu 等到 stop 赋值之后再读取
+	s := &Subscription{c: c, ch: make(chan Message, 64), done: make(chan struct{})}
+	go s.receive()
+	s.mu.Lock()
+	s.stop = context.AfterFunc(ctx, func() { s.Close() })
+	s.mu.Unlock()
+	return s, nil
+}
+
+// Channel 返回接收消息的通道，订阅结束后通道被关闭
+func (s *Subscription) Channel() <-chan Message {
+	return s.ch
+}
+
+// Err 返回导致订阅意外结束的错误，正常关闭时返回 nil
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close 结束订阅并关闭连接
+func (s *Subscription) Close() error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		stop := s.stop
+		s.mu.Unlock()
+		stop()
+		close(s.done)
+		s.c.nc.Close()
+	})
+	return nil
+}
+
+func (s *Subscription) receive() {
+	defer close(s.ch)
+	for {
+		v, err := s.c.r.ReadValue()
+		if err != nil {
+			select {
+			case <-s.done: // 连接是 Close 关闭的，不是错误
+			default:
+				s.mu.Lock()
+				s.err = err
+				s.mu.Unlock()
+			}
+			return
+		}
+		// RESP2 中消息是数组，RESP3 中是推送类型，内容都是 ["message", 频道, 内容]
+		if len(v.Elems) != 3 || v.Elems[0].Str != "message" {
+			continue
+		}
+		select {
+		case s.ch <- Message{Channel: v.Elems[1].Str, Payload: v.Elems[2].Str}:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// redisArgs 把参数转换成字符串
+func redisArgs(args []any) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		switch a := a.(type) {
+		case string:
+			out[i] = a
+		case []byte:
+			out[i] = string(a)
+		case int:
+			out[i] = strconv.Itoa(a)
+		case int64:
+			out[i] = strconv.FormatInt(a, 10)
+		case float64:
+			out[i] = strconv.FormatFloat(a, 'f', -1, 64)
+		case bool:
+			if a {
+				out[i] = "1"
+			} else {
+				out[i] = "0"
+			}
+		default:
+			out[i] = fmt.Sprint(a)
+		}
+	}
+	return out
+}
+
+func stringsToAny(items []string) []any {
+	out := make([]any, len(items))
+	for i, s := range items {
+		out[i] = s
+	}
+	return out
+}
+
+func stringReply(v resp.Value, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	if v.Nil() {
+		return "", ErrNil
+	}
+	return v.Text(), nil
+}
+
+func intReply(v resp.Value, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch v.Type {
+	case resp.Integer:
+		return v.Int, nil
+	case resp.Boolean:
+		if v.Bool {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("redis: 期望整数回复，收到 %v", v)
+}
+
+func deadlineOf(ctx context.Context) time.Time {
+	deadline, _ := ctx.Deadline()
+	return deadline
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
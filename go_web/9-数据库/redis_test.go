@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/database/redisserver"
+)
+
+// newTestRedis 启动进程内的 Redis 兼容服务器，返回连接它的客户端
+func newTestRedis(t *testing.T) (*Redis, *redisserver.Server) {
+	t.Helper()
+	srv, err := redisserver.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	r := NewRedis(RedisOptions{Addr: srv.Addr()})
+	t.Cleanup(func() { r.Close() })
+	return r, srv
+}
+
+// receive 在一秒内从订阅读取一条消息
+func receive(t *testing.T, sub *Subscription) (Message, bool) {
+	t.Helper()
+	select {
+	case msg, ok := <-sub.Channel():
+		return msg, ok
+	case <-time.After(time.Second):
+		t.Fatal("一秒内没有收到消息，订阅也没有结束")
+		return Message{}, false
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	for _, proto := range []int{2, 3} {
+		r, srv := newTestRedis(t)
+		r.opts.Protocol = proto
+		ctx := context.Background()
+
+		sub, err := r.Subscribe(ctx, "news", "sports")
+		if err != nil {
+			t.Fatalf("RESP%d: Subscribe: %v", proto, err)
+		}
+		if n, err := r.Publish(ctx, "sports", "进球了"); err != nil || n != 1 {
+			t.Fatalf("RESP%d: Publish 返回 %d, %v，应该有 1 个订阅者", proto, n, err)
+		}
+		msg, ok := receive(t, sub)
+		if !ok || msg != (Message{Channel: "sports", Payload: "进球了"}) {
+			t.Fatalf("RESP%d: 收到 %+v", proto, msg)
+		}
+
+		sub.Close()
+		if _, ok := receive(t, sub); ok {
+			t.Fatalf("RESP%d: Close 之后通道没有关闭", proto)
+		}
+		if err := sub.Err(); err != nil {
+			t.Fatalf("RESP%d: Close 之后 Err 返回 %v", proto, err)
+		}
+		// 服务器发现连接关闭需要一点时间
+		deadline := time.Now().Add(time.Second)
+		for srv.Do("PUBLISH", "sports", "x").Int != 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("RESP%d: Close 之后服务器上还有订阅者", proto)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestSubscribeContextCanceled(t *testing.T) {
+	r, _ := newTestRedis(t)
+
+	// 订阅建立之后取消 ctx，订阅结束，不是错误
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := r.Subscribe(ctx, "news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, ok := receive(t, sub); ok {
+		t.Fatal("取消 ctx 之后通道没有关闭")
+	}
+	if err := sub.Err(); err != nil {
+		t.Fatalf("取消 ctx 之后 Err 返回 %v", err)
+	}
+	sub.Close() // 重复 Close 没有影响
+
+	// ctx 在订阅过程中的任何时刻被取消，要么返回错误，要么返回很快结束的订阅，都不能 panic
+	for i := range 200 {
+		ctx, cancel := context.WithCancel(context.Background())
+		go cancel()
+		sub, err := r.Subscribe(ctx, "news")
+		if err != nil {
+			continue
+		}
+		if _, ok := receive(t, sub); ok {
+			t.Fatalf("第 %d 次：取消 ctx 之后通道没有关闭", i)
+		}
+	}
+}
+
+func TestSubscribeServerClosed(t *testing.T) {
+	r, srv := newTestRedis(t)
+	sub, err := r.Subscribe(context.Background(), "news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+	if _, ok := receive(t, sub); ok {
+		t.Fatal("服务器关闭之后通道没有关闭")
+	}
+	if sub.Err() == nil {
+		t.Fatal("服务器关闭导致订阅结束，Err 应该返回错误")
+	}
+	sub.Close()
+}
+
+func TestSubscribeWithoutChannels(t *testing.T) {
+	r, _ := newTestRedis(t)
+	if _, err := r.Subscribe(context.Background()); err == nil {
+		t.Fatal("没有频道时 Subscribe 应该返回错误")
+	}
+}
@@ -0,0 +1,924 @@
+package redisserver
+
+import (
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/database/resp"
+)
+
+// command 描述一条命令。arity 包括命令名本身，负数表示至少 -arity 个参数。
+type command struct {
+	fn     func(st *state, c *client, args []string) resp.Value
+	arity  int
+	noAuth bool // 认证之前也可以执行
+	pubsub bool // RESP2 订阅模式下可以执行
+	tx     bool // MULTI 中直接执行而不是排队
+
+	// keys 返回写命令修改的 key，执行后让 WATCH 这些 key 的事务失败；读命令为 nil。
+	// 和 Redis 不同，命令出错或者实际没有修改时也算修改，只会让事务多重试一次。
+	keys func(args []string) []string
+}
+
+// 写命令的 key 在参数中的位置
+func firstKey(args []string) []string { return args[1:2] }
+func allKeys(args []string) []string  { return args[1:] }
+
+func pairKeys(args []string) []string { // MSET key value [key value ...]
+	var keys []string
+	for i := 1; i < len(args); i += 2 {
+		keys = append(keys, args[i])
+	}
+	return keys
+}
+
+var commands map[string]command
+
+// 在 init 中赋值，因为 EXEC 的实现会引用 commands 本身
+func init() {
+	commands = map[string]command{
+		// 连接
+		"PING":    {fn: cmdPing, arity: -1, pubsub: true},
+		"ECHO":    {fn: cmdEcho, arity: 2},
+		"HELLO":   {fn: cmdHello, arity: -1, noAuth: true},
+		"AUTH":    {fn: cmdAuth, arity: -2, noAuth: true},
+		"SELECT":  {fn: cmdSelect, arity: 2},
+		"QUIT":    {fn: cmdQuit, arity: 1, noAuth: true, pubsub: true, tx: true},
+		"COMMAND": {fn: cmdCommand, arity: -1},
+
+		// key
+		"DEL":      {fn: cmdDel, arity: -2, keys: allKeys},
+		"EXISTS":   {fn: cmdExists, arity: -2},
+		"TYPE":     {fn: cmdType, arity: 2},
+		"KEYS":     {fn: cmdKeys, arity: 2},
+		"EXPIRE":   {fn: cmdExpire(time.Second), arity: 3, keys: firstKey},
+		"PEXPIRE":  {fn: cmdExpire(time.Millisecond), arity: 3, keys: firstKey},
+		"TTL":      {fn: cmdTTL(time.Second), arity: 2},
+		"PTTL":     {fn: cmdTTL(time.Millisecond), arity: 2},
+		"PERSIST":  {fn: cmdPersist, arity: 2, keys: firstKey},
+		"DBSIZE":   {fn: cmdDBSize, arity: 1},
+		"FLUSHDB":  {fn: cmdFlush, arity: -1},
+		"FLUSHALL": {fn: cmdFlush, arity: -1},
+
+		// 字符串
+		"GET":    {fn: cmdGet, arity: 2},
+		"SET":    {fn: cmdSet, arity: -3, keys: firstKey},
+		"MGET":   {fn: cmdMGet, arity: -2},
+		"MSET":   {fn: cmdMSet, arity: -3, keys: pairKeys},
+		"INCR":   {fn: cmdIncrBy(1), arity: 2, keys: firstKey},
+		"DECR":   {fn: cmdIncrBy(-1), arity: 2, keys: firstKey},
+		"INCRBY": {fn: cmdIncrBy(0), arity: 3, keys: firstKey},
+		"DECRBY": {fn: cmdIncrBy(0), arity: 3, keys: firstKey},
+		"APPEND": {fn: cmdAppend, arity: 3, keys: firstKey},
+		"STRLEN": {fn: cmdStrlen, arity: 2},
+
+		// 哈希
+		"HSET":    {fn: cmdHSet, arity: -4, keys: firstKey},
+		"HGET":    {fn: cmdHGet, arity: 3},
+		"HDEL":    {fn: cmdHDel, arity: -3, keys: firstKey},
+		"HEXISTS": {fn: cmdHExists, arity: 3},
+		"HLEN":    {fn: cmdHLen, arity: 2},
+		"HGETALL": {fn: cmdHGetAll, arity: 2},
+		"HKEYS":   {fn: cmdHKeys, arity: 2},
+		"HVALS":   {fn: cmdHVals, arity: 2},
+		"HINCRBY": {fn: cmdHIncrBy, arity: 4, keys: firstKey},
+
+		// 列表
+		"LPUSH":  {fn: cmdPush(true), arity: -3, keys: firstKey},
+		"RPUSH":  {fn: cmdPush(false), arity: -3, keys: firstKey},
+		"LPOP":   {fn: cmdPop(true), arity: 2, keys: firstKey},
+		"RPOP":   {fn: cmdPop(false), arity: 2, keys: firstKey},
+		"LLEN":   {fn: cmdLLen, arity: 2},
+		"LRANGE": {fn: cmdLRange, arity: 4},
+		"LINDEX": {fn: cmdLIndex, arity: 3},
+
+		// 发布订阅
+		"SUBSCRIBE":   {fn: cmdSubscribe, arity: -2, pubsub: true},
+		"UNSUBSCRIBE": {fn: cmdUnsubscribe, arity: -1, pubsub: true},
+		"PUBLISH":     {fn: cmdPublish, arity: 3},
+
+		// 事务
+		"MULTI":   {fn: cmdMulti, arity: 1, tx: true},
+		"EXEC":    {fn: cmdExec, arity: 1, tx: true},
+		"DISCARD": {fn: cmdDiscard, arity: 1, tx: true},
+		"WATCH":   {fn: cmdWatch, arity: -2, tx: true},
+		"UNWATCH": {fn: cmdUnwatch, arity: 1},
+	}
+}
+
+// 常用的回复
+var (
+	okReply       = resp.SimpleStringValue("OK")
+	wrongType     = resp.ErrorValue("WRONGTYPE Operation against a key holding the wrong kind of value")
+	notInteger    = resp.ErrorValue("ERR value is not an integer or out of range")
+	syntaxError   = resp.ErrorValue("ERR syntax error")
+	invalidExpire = resp.ErrorValue("ERR invalid expire time")
+)
+
+func boolInt(b bool) resp.Value {
+	if b {
+		return resp.IntegerValue(1)
+	}
+	return resp.IntegerValue(0)
+}
+
+// 连接
+
+func cmdPing(st *state, c *client, args []string) resp.Value {
+	if len(c.subs) > 0 && c.proto < 3 { // 订阅模式下 PING 的回复格式不同
+		msg := ""
+		if len(args) > 1 {
+			msg = args[1]
+		}
+		return resp.BulkArray("pong", msg)
+	}
+	if len(args) > 1 {
+		return resp.BulkValue(args[1])
+	}
+	return resp.SimpleStringValue("PONG")
+}
+
+func cmdEcho(st *state, c *client, args []string) resp.Value {
+	return resp.BulkValue(args[1])
+}
+
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+func cmdHello(st *state, c *client, args []string) resp.Value {
+	proto := c.proto
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 2 || n > 3 {
+			return resp.ErrorValue("NOPROTO unsupported protocol version")
+		}
+		proto = n
+	}
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "AUTH":
+			if i+2 >= len(args) {
+				return syntaxError
+			}
+			if v := st.auth(c, args[i+2]); v.Type == resp.Error {
+				return v
+			}
+			i += 2
+		case "SETNAME":
+			if i+1 >= len(args) {
+				return syntaxError
+			}
+			i++
+		default:
+			return syntaxError
+		}
+	}
+	if st.s.opts.Password != "" && !c.authed {
+		return resp.ErrorValue("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+	}
+
+	c.proto = proto
+	return resp.Value{Type: resp.Map, Elems: []resp.Value{
+		resp.BulkValue("server"), resp.BulkValue("redis"),
+		resp.BulkValue("version"), resp.BulkValue("7.0.0"),
+		resp.BulkValue("proto"), resp.IntegerValue(int64(proto)),
+		resp.BulkValue("mode"), resp.BulkValue("standalone"),
+		resp.BulkValue("role"), resp.BulkValue("master"),
+		resp.BulkValue("modules"), resp.ArrayValue(),
+	}}
+}
+
+// AUTH [username] password，只有一个用户，用户名被忽略
+func cmdAuth(st *state, c *client, args []string) resp.Value {
+	if len(args) > 3 {
+		return syntaxError
+	}
+	return st.auth(c, args[len(args)-1])
+}
+
+func (st *state) auth(c *client, password string) resp.Value {
+	if st.s.opts.Password == "" {
+		return resp.ErrorValue("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+	}
+	if password != st.s.opts.Password {
+		return resp.ErrorValue("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	c.authed = true
+	return okReply
+}
+
+// 只有一个数据库
+func cmdSelect(st *state, c *client, args []string) resp.Value {
+	if args[1] != "0" {
+		return resp.ErrorValue("ERR DB index is out of range")
+	}
+	return okReply
+}
+
+func cmdQuit(st *state, c *client, args []string) resp.Value {
+	st.reply(c, okReply)
+	st.disconnect(c) // 写协程发完 OK 之后关闭连接
+	return noReply
+}
+
+// redis-cli 启动时会发送 COMMAND DOCS，返回空数组即可
+func cmdCommand(st *state, c *client, args []string) resp.Value {
+	return resp.ArrayValue()
+}
+
+// key
+
+func cmdDel(st *state, c *client, args []string) resp.Value {
+	var n int64
+	for _, key := range args[1:] {
+		if st.lookup(key) != nil {
+			delete(st.data, key)
+			n++
+		}
+	}
+	return resp.IntegerValue(n)
+}
+
+func cmdExists(st *state, c *client, args []string) resp.Value {
+	var n int64
+	for _, key := range args[1:] {
+		if st.lookup(key) != nil {
+			n++
+		}
+	}
+	return resp.IntegerValue(n)
+}
+
+func cmdType(st *state, c *client, args []string) resp.Value {
+	e := st.lookup(args[1])
+	if e == nil {
+		return resp.SimpleStringValue("none")
+	}
+	return resp.SimpleStringValue(e.kind)
+}
+
+// KEYS pattern，pattern 的语法和 path.Match 相同，和 Redis 的 glob 基本一致
+func cmdKeys(st *state, c *client, args []string) resp.Value {
+	var keys []string
+	for key := range st.data {
+		if st.lookup(key) == nil {
+			continue
+		}
+		if matched, _ := path.Match(args[1], key); matched {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return resp.BulkArray(keys...)
+}
+
+func cmdExpire(unit time.Duration) func(*state, *client, []string) resp.Value {
+	return func(st *state, c *client, args []string) resp.Value {
+		n, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return notInteger
+		}
+		if n > math.MaxInt64/int64(unit) {
+			return invalidExpire
+		}
+		e := st.lookup(args[1])
+		if e == nil {
+			return resp.IntegerValue(0)
+		}
+		if n <= 0 { // 过期时间已经过去，和 Redis 一样直接删除
+			delete(st.data, args[1])
+			return resp.IntegerValue(1)
+		}
+		e.expireAt = time.Now().Add(time.Duration(n) * unit)
+		return resp.IntegerValue(1)
+	}
+}
+
+// TTL 在 key 不存在时返回 -2，没有过期时间时返回 -1
+func cmdTTL(unit time.Duration) func(*state, *client, []string) resp.Value {
+	return func(st *state, c *client, args []string) resp.Value {
+		e := st.lookup(args[1])
+		switch {
+		case e == nil:
+			return resp.IntegerValue(-2)
+		case e.expireAt.IsZero():
+			return resp.IntegerValue(-1)
+		}
+		left := time.Until(e.expireAt)
+		return resp.IntegerValue(int64((left + unit - 1) / unit)) // 向上取整
+	}
+}
+
+func cmdPersist(st *state, c *client, args []string) resp.Value {
+	e := st.lookup(args[1])
+	if e == nil || e.expireAt.IsZero() {
+		return resp.IntegerValue(0)
+	}
+	e.expireAt = time.Time{}
+	return resp.IntegerValue(1)
+}
+
+func cmdDBSize(st *state, c *client, args []string) resp.Value {
+	var n int64
+	for key := range st.data {
+		if st.lookup(key) != nil {
+			n++
+		}
+	}
+	return resp.IntegerValue(n)
+}
+
+func cmdFlush(st *state, c *client, args []string) resp.Value {
+	for key := range st.watchers {
+		st.touch(key)
+	}
+	clear(st.data)
+	return okReply
+}
+
+// 字符串
+
+// getString 返回字符串类型的值；key 不存在时 e 为 nil，类型不对时返回 WRONGTYPE 错误
+func (st *state) getString(key string) (*entry, resp.Value) {
+	e := st.lookup(key)
+	if e != nil && e.kind != typeString {
+		return nil, wrongType
+	}
+	return e, noReply
+}
+
+func cmdGet(st *state, c *client, args []string) resp.Value {
+	e, errv := st.getString(args[1])
+	if errv.Type != 0 {
+		return errv
+	}
+	if e == nil {
+		return resp.NullBulk()
+	}
+	return resp.BulkValue(e.str)
+}
+
+// SET key value [EX seconds | PX milliseconds] [NX | XX] [KEEPTTL] [GET]
+func cmdSet(st *state, c *client, args []string) resp.Value {
+	key, value := args[1], args[2]
+	var (
+		ttl               time.Duration
+		nx, xx, keep, get bool
+	)
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); opt {
+		case "EX", "PX":
+			if ttl > 0 || keep || i+1 >= len(args) {
+				return syntaxError
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return notInteger
+			}
+			unit := time.Second
+			if opt == "PX" {
+				unit = time.Millisecond
+			}
+			if n <= 0 || n > math.MaxInt64/int64(unit) {
+				return resp.ErrorValue("ERR invalid expire time in 'set' command")
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "KEEPTTL":
+			if ttl > 0 {
+				return syntaxError
+			}
+			keep = true
+		case "GET":
+			get = true
+		default:
+			return syntaxError
+		}
+	}
+	if nx && xx {
+		return syntaxError
+	}
+
+	old := st.lookup(key)
+	reply := okReply
+	if get {
+		switch {
+		case old == nil:
+			reply = resp.NullBulk()
+		case old.kind != typeString:
+			return wrongType
+		default:
+			reply = resp.BulkValue(old.str)
+		}
+	}
+	if (nx && old != nil) || (xx && old == nil) {
+		if get {
+			return reply
+		}
+		return resp.NullBulk()
+	}
+
+	e := &entry{kind: typeString, str: value}
+	if ttl > 0 {
+		e.expireAt = time.Now().Add(ttl)
+	} else if keep && old != nil {
+		e.expireAt = old.expireAt
+	}
+	st.data[key] = e
+	return reply
+}
+
+func cmdMGet(st *state, c *client, args []string) resp.Value {
+	elems := make([]resp.Value, 0, len(args)-1)
+	for _, key := range args[1:] {
+		e := st.lookup(key)
+		if e == nil || e.kind != typeString {
+			elems = append(elems, resp.NullBulk())
+		} else {
+			elems = append(elems, resp.BulkValue(e.str))
+		}
+	}
+	return resp.ArrayValue(elems...)
+}
+
+func cmdMSet(st *state, c *client, args []string) resp.Value {
+	if len(args)%2 != 1 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'mset' command")
+	}
+	for i := 1; i < len(args); i += 2 {
+		st.data[args[i]] = &entry{kind: typeString, str: args[i+1]}
+	}
+	return okReply
+}
+
+// cmdIncrBy 实现 INCR、DECR（delta 为 ±1）和 INCRBY、DECRBY（delta 为 0，从参数读取）
+func cmdIncrBy(delta int64) func(*state, *client, []string) resp.Value {
+	return func(st *state, c *client, args []string) resp.Value {
+		d := delta
+		if d == 0 {
+			n, err := strconv.ParseInt(args[2], 10, 64)
+			if err != nil {
+				return notInteger
+			}
+			d = n
+			if strings.EqualFold(args[0], "DECRBY") {
+				if n == math.MinInt64 {
+					return resp.ErrorValue("ERR decrement would overflow")
+				}
+				d = -n
+			}
+		}
+
+		e, errv := st.getString(args[1])
+		if errv.Type != 0 {
+			return errv
+		}
+		var cur int64
+		if e != nil {
+			n, err := strconv.ParseInt(e.str, 10, 64)
+			if err != nil {
+				return notInteger
+			}
+			cur = n
+		}
+		if (d > 0 && cur > math.MaxInt64-d) || (d < 0 && cur < math.MinInt64-d) {
+			return resp.ErrorValue("ERR increment or decrement would overflow")
+		}
+		cur += d
+		if e == nil {
+			st.data[args[1]] = &entry{kind: typeString, str: strconv.FormatInt(cur, 10)}
+		} else {
+			e.str = strconv.FormatInt(cur, 10) // 保留原来的过期时间
+		}
+		return resp.IntegerValue(cur)
+	}
+}
+
+func cmdAppend(st *state, c *client, args []string) resp.Value {
+	e, errv := st.getString(args[1])
+	if errv.Type != 0 {
+		return errv
+	}
+	if e == nil {
+		e = &entry{kind: typeString}
+		st.data[args[1]] = e
+	}
+	e.str += args[2]
+	return resp.IntegerValue(int64(len(e.str)))
+}
+
+func cmdStrlen(st *state, c *client, args []string) resp.Value {
+	e, errv := st.getString(args[1])
+	if errv.Type != 0 {
+		return errv
+	}
+	if e == nil {
+		return resp.IntegerValue(0)
+	}
+	return resp.IntegerValue(int64(len(e.str)))
+}
+
+// 哈希
+
+func (st *state) getHash(key string, create bool) (*entry, resp.Value) {
+	e := st.lookup(key)
+	if e != nil && e.kind != typeHash {
+		return nil, wrongType
+	}
+	if e == nil && create {
+		e = &entry{kind: typeHash, hash: make(map[string]string)}
+		st.data[key] = e
+	}
+	return e, noReply
+}
+
+func cmdHSet(st *state, c *client, args []string) resp.Value {
+	if len(args)%2 != 0 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'hset' command")
+	}
+	e, errv := st.getHash(args[1], true)
+	if errv.Type != 0 {
+		return errv
+	}
+	var added int64
+	for i := 2; i < len(args); i += 2 {
+		if _, ok := e.hash[args[i]]; !ok {
+			added++
+		}
+		e.hash[args[i]] = args[i+1]
+	}
+	return resp.IntegerValue(added)
+}
+
+func cmdHGet(st *state, c *client, args []string) resp.Value {
+	e, errv := st.getHash(args[1], false)
+	if errv.Type != 0 {
+		return errv
+	}
+	if e == nil {
+		return resp.NullBulk()
+	}
+	v, ok := e.hash[args[2]]
+	if !ok {
+		return resp.NullBulk()
+	}
+	return resp.BulkValue(v)
+}
+
+func cmdHDel(st *state, c *client, args []string) resp.Value {
+	e, errv := st.getHash(args[1], false)
+	if errv.Type != 0 || e == nil {
+		return orZero(errv)
+	}
+	var n int64
+	for _, f := range args[2:] {
+		if _, ok := e.hash[f]; ok {
+			delete(e.hash, f)
+			n++
+		}
+	}
+	if len(e.hash) == 0 { // 和 Redis 一样，空的哈希和列表会被删除
+		delete(st.data, args[1])
+	}
+	return resp.IntegerValue(n)
+}
+
+func cmdHExists(st *state, c *client, args []string) resp.Value {
+	e, errv := st.getHash(args[1], false)
+	if errv.Type != 0 || e == nil {
+		return orZero(errv)
+	}
+	_, ok := e.hash[args[2]]
+	return boolInt(ok)
+}
+
+func cmdHLen(st *state, c *client, args []string) resp.Value {
+	e, errv := st.getHash(args[1], false)
+	if errv.Type != 0 || e == nil {
+		return orZero(errv)
+	}
+	return resp.IntegerValue(int64(len(e.hash)))
+}
+
+// HGETALL 在 RESP3 中返回 Map，RESP2 的连接由 Writer 转换成数组
+func cmdHGetAll(st *state, c *client, args []string) resp.Value {
+	e, errv := st.getHash(args[1], false)
+	if errv.Type != 0 {
+		return errv
+	}
+	v := resp.Value{Type: resp.Map, Elems: []resp.Value{}}
+	if e == nil {
+		return v
+	}
+	for _, f := range sortedFields(e.hash) {
+		v.Elems = append(v.Elems, resp.BulkValue(f), resp.BulkValue(e.hash[f]))
+	}
+	return v
+}
+
+func cmdHKeys(st *state, c *client, args []string) resp.Value {
+	e, errv := st.getHash(args[1], false)
+	if errv.Type != 0 {
+		return errv
+	}
+	if e == nil {
+		return resp.ArrayValue()
+	}
+	return resp.BulkArray(sortedFields(e.hash)...)
+}
+
+func cmdHVals(st *state, c *client, args []string) resp.Value {
+	e, errv := st.getHash(args[1], false)
+	if errv.Type != 0 {
+		return errv
+	}
+	if e == nil {
+		return resp.ArrayValue()
+	}
+	var vals []string
+	for _, f := range sortedFields(e.hash) {
+		vals = append(vals, e.hash[f])
+	}
+	return resp.BulkArray(vals...)
+}
+
+func cmdHIncrBy(st *state, c *client, args []string) resp.Value {
+	d, err := strconv.ParseInt(args[3], 10, 64)
+	if err != nil {
+		return notInteger
+	}
+	e, errv := st.getHash(args[1], true)
+	if errv.Type != 0 {
+		return errv
+	}
+	var cur int64
+	if s, ok := e.hash[args[2]]; ok {
+		if cur, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return resp.ErrorValue("ERR hash value is not an integer")
+		}
+	}
+	if (d > 0 && cur > math.MaxInt64-d) || (d < 0 && cur < math.MinInt64-d) {
+		return resp.ErrorValue("ERR increment or decrement would overflow")
+	}
+	cur += d
+	e.hash[args[2]] = strconv.FormatInt(cur, 10)
+	return resp.IntegerValue(cur)
+}
+
+// sortedFields 让输出的顺序固定，方便测试
+func sortedFields(m map[string]string) []string {
+	fields := make([]string, 0, len(m))
+	for f := range m {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// orZero 在没有错误时返回整数 0，用于 key 不存在的情况
+func orZero(errv resp.Value) resp.Value {
+	if errv.Type != 0 {
+		return errv
+	}
+	return resp.IntegerValue(0)
+}
+
+// 列表
+
+func (st *state) getList(key string, create bool) (*entry, resp.Value) {
+	e := st.lookup(key)
+	if e != nil && e.kind != typeList {
+		return nil, wrongType
+	}
+	if e == nil && create {
+		e = &entry{kind: typeList}
+		st.data[key] = e
+	}
+	return e, noReply
+}
+
+func cmdPush(left bool) func(*state, *client, []string) resp.Value {
+	return func(st *state, c *client, args []string) resp.Value {
+		e, errv := st.getList(args[1], true)
+		if errv.Type != 0 {
+			return errv
+		}
+		for _, v := range args[2:] {
+			if left {
+				e.list = append([]string{v}, e.list...)
+			} else {
+				e.list = append(e.list, v)
+			}
+		}
+		return resp.IntegerValue(int64(len(e.list)))
+	}
+}
+
+func cmdPop(left bool) func(*state, *client, []string) resp.Value {
+	return func(st *state, c *client, args []string) resp.Value {
+		e, errv := st.getList(args[1], false)
+		if errv.Type != 0 {
+			return errv
+		}
+		if e == nil {
+			return resp.NullBulk()
+		}
+		var v string
+		if left {
+			v, e.list = e.list[0], e.list[1:]
+		} else {
+			v, e.list = e.list[len(e.list)-1], e.list[:len(e.list)-1]
+		}
+		if len(e.list) == 0 {
+			delete(st.data, args[1])
+		}
+		return resp.BulkValue(v)
+	}
+}
+
+func cmdLLen(st *state, c *client, args []string) resp.Value {
+	e, errv := st.getList(args[1], false)
+	if errv.Type != 0 || e == nil {
+		return orZero(errv)
+	}
+	return resp.IntegerValue(int64(len(e.list)))
+}
+
+func cmdLRange(st *state, c *client, args []string) resp.Value {
+	start, err1 := strconv.Atoi(args[2])
+	stop, err2 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil {
+		return notInteger
+	}
+	e, errv := st.getList(args[1], false)
+	if errv.Type != 0 {
+		return errv
+	}
+	if e == nil {
+		return resp.ArrayValue()
+	}
+
+	n := len(e.list)
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if stop < 0 {
+		stop = n + stop
+	}
+	stop = min(stop, n-1)
+	if start > stop {
+		return resp.ArrayValue()
+	}
+	return resp.BulkArray(e.list[start : stop+1]...)
+}
+
+func cmdLIndex(st *state, c *client, args []string) resp.Value {
+	i, err := strconv.Atoi(args[2])
+	if err != nil {
+		return notInteger
+	}
+	e, errv := st.getList(args[1], false)
+	if errv.Type != 0 {
+		return errv
+	}
+	if e == nil {
+		return resp.NullBulk()
+	}
+	if i < 0 {
+		i += len(e.list)
+	}
+	if i < 0 || i >= len(e.list) {
+		return resp.NullBulk()
+	}
+	return resp.BulkValue(e.list[i])
+}
+
+// 发布订阅
+
+// pushValue 是订阅相关的推送消息，RESP3 中是 Push 类型，RESP2 的连接会把它写成数组
+func pushValue(kind, channel string, v resp.Value) resp.Value {
+	return resp.Value{Type: resp.Push, Elems: []resp.Value{resp.BulkValue(kind), resp.BulkValue(channel), v}}
+}
+
+// SUBSCRIBE 对每个频道回复一条确认，内容是当前订阅的频道数
+func cmdSubscribe(st *state, c *client, args []string) resp.Value {
+	if c.subs == nil {
+		c.subs = make(map[string]bool)
+	}
+	for _, ch := range args[1:] {
+		if !c.subs[ch] {
+			c.subs[ch] = true
+			if st.subs[ch] == nil {
+				st.subs[ch] = make(map[*client]bool)
+			}
+			st.subs[ch][c] = true
+		}
+		st.reply(c, pushValue("subscribe", ch, resp.IntegerValue(int64(len(c.subs)))))
+	}
+	return noReply
+}
+
+// UNSUBSCRIBE 没有参数时取消所有订阅
+func cmdUnsubscribe(st *state, c *client, args []string) resp.Value {
+	channels := args[1:]
+	if len(channels) == 0 {
+		for ch := range c.subs {
+			channels = append(channels, ch)
+		}
+		sort.Strings(channels)
+	}
+	if len(channels) == 0 {
+		st.reply(c, resp.Value{Type: resp.Push, Elems: []resp.Value{resp.BulkValue("unsubscribe"), resp.NullBulk(), resp.IntegerValue(0)}})
+		return noReply
+	}
+	for _, ch := range channels {
+		st.unsubscribe(c, ch)
+		st.reply(c, pushValue("unsubscribe", ch, resp.IntegerValue(int64(len(c.subs)))))
+	}
+	return noReply
+}
+
+// PUBLISH 把消息放进每个订阅者的发送队列，返回订阅者数量
+func cmdPublish(st *state, c *client, args []string) resp.Value {
+	subs := st.subs[args[1]]
+	n := int64(len(subs))
+	msg := pushValue("message", args[1], resp.BulkValue(args[2]))
+	for sub := range subs {
+		st.reply(sub, msg) // 太慢的订阅者会在这里被断开
+	}
+	return resp.IntegerValue(n)
+}
+
+// 事务
+
+func cmdMulti(st *state, c *client, args []string) resp.Value {
+	if c.multi {
+		return resp.ErrorValue("ERR MULTI calls can not be nested")
+	}
+	c.multi = true
+	c.queued = nil
+	c.multiErr = false
+	return okReply
+}
+
+// EXEC 依次执行排队的命令。状态协程在执行期间不会处理其他连接的命令，所以整个事务是原子的。
+func cmdExec(st *state, c *client, args []string) resp.Value {
+	if !c.multi {
+		return resp.ErrorValue("ERR EXEC without MULTI")
+	}
+	queued, failed, dirty := c.queued, c.multiErr, c.dirty
+	c.multi, c.queued, c.multiErr = false, nil, false
+	st.unwatch(c)
+	if failed {
+		return resp.ErrorValue("EXECABORT Transaction discarded because of previous errors.")
+	}
+	if dirty { // WATCH 的 key 被修改了，不执行任何命令，回复空数组
+		return resp.Value{Type: resp.Array, IsNull: true}
+	}
+
+	// 和 Redis 一样，单条命令执行出错不会回滚其他命令，错误作为该命令的回复返回
+	replies := make([]resp.Value, len(queued))
+	for i, args := range queued {
+		replies[i] = st.handle(c, args)
+	}
+	return resp.ArrayValue(replies...)
+}
+
+func cmdDiscard(st *state, c *client, args []string) resp.Value {
+	if !c.multi {
+		return resp.ErrorValue("ERR DISCARD without MULTI")
+	}
+	c.multi, c.queued, c.multiErr = false, nil, false
+	st.unwatch(c)
+	return okReply
+}
+
+// WATCH key [key ...]，之后到 EXEC 之前这些 key 被修改或者过期，EXEC 就放弃事务
+func cmdWatch(st *state, c *client, args []string) resp.Value {
+	if c.multi {
+		return resp.ErrorValue("ERR WATCH inside MULTI is not allowed")
+	}
+	if c.watched == nil {
+		c.watched = make(map[string]bool)
+	}
+	for _, key := range args[1:] {
+		st.lookup(key) // 先删除已经过期的 key，不然它在 WATCH 之后被删除会让事务失败
+		c.watched[key] = true
+		if st.watchers[key] == nil {
+			st.watchers[key] = make(map[*client]bool)
+		}
+		st.watchers[key][c] = true
+	}
+	return okReply
+}
+
+func cmdUnwatch(st *state, c *client, args []string) resp.Value {
+	st.unwatch(c)
+	return okReply
+}
@@ -0,0 +1,260 @@
+// Package redisserver 是一个在进程内运行的 Redis 兼容服务器，用来在没有 Redis 的环境中测试和开发。
+// 它支持字符串、哈希、列表、过期时间、发布订阅和 MULTI/EXEC/WATCH，数据只保存在内存中。
+//
+// 和 38-go状态协程 中的例子一样，所有数据只属于一个状态协程：
+// 每个连接的读协程把命令通过通道发给状态协程，状态协程依次执行并把回复放进连接的发送队列，
+// 再由连接的写协程发送出去。命令是一条一条执行的，所以不需要加锁，MULTI/EXEC 天然是原子的。
+//
+//	srv, err := redisserver.Start("127.0.0.1:0")
+//	defer srv.Close()
+//	r := database.NewRedis(database.RedisOptions{Addr: srv.Addr()})
+package redisserver
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"example.com/database/resp"
+)
+
+// ErrServerClosed 是 Serve 在 Close 之后返回的错误
+var ErrServerClosed = errors.New("redisserver: 服务器已关闭")
+
+// Options 是服务器的配置
+type Options struct {
+	Password string // 不为空时客户端需要先用 AUTH 或 HELLO 认证
+
+	// OutputBuffer 是每个连接等待发送的回复数上限，超过时断开连接，
+	// 防止一个读得很慢的订阅者拖住状态协程。默认 1024。
+	OutputBuffer int
+
+	// MaxBulkLen 是客户端发来的单个字符串的长度上限，超过时断开连接，同 Redis 的 proto-max-bulk-len。
+	// 默认 resp.DefaultMaxBulkLen。
+	MaxBulkLen int
+}
+
+// Server 是 Redis 兼容服务器。零值不可用，使用 New 或 Start 创建。
+type Server struct {
+	opts Options
+	reqs chan request
+	quit chan struct{}
+	done chan struct{} // 状态协程退出后关闭
+
+	mu        sync.Mutex
+	listeners []net.Listener
+	closed    bool
+	closeOnce sync.Once
+}
+
+// New 创建服务器并启动状态协程，之后可以用 Serve 接受连接，或者直接用 Do 执行命令。
+func New(opts Options) *Server {
+	if opts.OutputBuffer <= 0 {
+		opts.OutputBuffer = 1024
+	}
+	if opts.MaxBulkLen <= 0 {
+		opts.MaxBulkLen = resp.DefaultMaxBulkLen
+	}
+	s := &Server{
+		opts: opts,
+		reqs: make(chan request),
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// Start 在 addr 上监听并在后台接受连接，addr 为 "127.0.0.1:0" 时随机选择端口，用 Addr 查看。
+func Start(addr string) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := New(Options{})
+	s.addListener(ln)
+	go s.Serve(ln)
+	return s, nil
+}
+
+// Addr 返回第一个监听地址
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.listeners) == 0 {
+		return ""
+	}
+	return s.listeners[0].Addr().String()
+}
+
+// ListenAndServe 在 addr 上监听并接受连接，直到 Close
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve 接受 ln 上的连接，每个连接由一个读协程和一个写协程处理。Close 之后返回 ErrServerClosed。
+func (s *Server) Serve(ln net.Listener) error {
+	if !s.addListener(ln) {
+		ln.Close()
+		return ErrServerClosed
+	}
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-s.quit:
+				return ErrServerClosed
+			default:
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		c := &client{nc: nc, out: make(chan outMsg, s.opts.OutputBuffer), proto: 2}
+		if !s.send(request{kind: reqConnect, c: c}) {
+			nc.Close()
+			return ErrServerClosed
+		}
+		go c.writeLoop()
+		go s.readLoop(c)
+	}
+}
+
+// Do 在进程内直接执行一条命令，不经过网络，回复和 RESP2 客户端看到的一样。
+// 每次调用都像一个新连接，所以 MULTI、SUBSCRIBE 这类依赖连接状态的命令不适合用 Do。
+func (s *Server) Do(args ...string) resp.Value {
+	c := &client{out: make(chan outMsg, 1), proto: 2}
+	if len(args) == 0 || !s.send(request{kind: reqCommand, c: c, args: args}) {
+		return resp.ErrorValue("ERR server closed")
+	}
+	m, ok := <-c.out
+	if !ok {
+		return resp.ErrorValue("ERR server closed")
+	}
+	return m.v
+}
+
+// Close 停止接受连接，关闭所有连接并让状态协程退出
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.quit) // 先关闭 quit，Serve 才能把 Accept 的错误识别为正常关闭
+		s.mu.Lock()
+		s.closed = true
+		for _, ln := range s.listeners {
+			ln.Close()
+		}
+		s.mu.Unlock()
+		<-s.done
+	})
+	return nil
+}
+
+func (s *Server) addListener(ln net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	for _, l := range s.listeners {
+		if l == ln {
+			return true
+		}
+	}
+	s.listeners = append(s.listeners, ln)
+	return true
+}
+
+// send 把请求交给状态协程，服务器关闭后返回 false
+func (s *Server) send(req request) bool {
+	select {
+	case s.reqs <- req:
+		return true
+	case <-s.quit:
+		return false
+	}
+}
+
+// readLoop 读取命令并交给状态协程，不等待回复，所以客户端的流水线不会被阻塞。
+func (s *Server) readLoop(c *client) {
+	r := resp.NewReader(c.nc)
+	r.MaxBulkLen = s.opts.MaxBulkLen
+	for {
+		args, err := r.ReadCommand()
+		if err != nil {
+			s.send(request{kind: reqDisconnect, c: c})
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if !s.send(request{kind: reqCommand, c: c, args: args}) {
+			return
+		}
+	}
+}
+
+type reqKind int
+
+const (
+	reqConnect reqKind = iota
+	reqCommand
+	reqDisconnect
+)
+
+// request 是连接发给状态协程的请求
+type request struct {
+	kind reqKind
+	c    *client
+	args []string
+}
+
+// outMsg 是等待发送的回复，带上生成回复时连接使用的协议版本
+type outMsg struct {
+	v     resp.Value
+	proto int
+}
+
+// client 是一个连接。nc 和 out 在创建后不变，其余字段只由状态协程访问。
+type client struct {
+	nc  net.Conn
+	out chan outMsg // 状态协程写入，写协程读取；状态协程关闭它表示连接结束
+
+	proto  int
+	authed bool
+	closed bool
+
+	multi    bool
+	queued   [][]string
+	multiErr bool // MULTI 中有命令格式错误，EXEC 时放弃整个事务
+	watched  map[string]bool
+	dirty    bool // WATCH 的 key 被修改过，EXEC 时放弃整个事务
+
+	subs map[string]bool
+}
+
+// writeLoop 发送回复，队列暂时为空时才刷新缓冲区，这样流水线的回复可以合并发送。
+func (c *client) writeLoop() {
+	defer c.nc.Close()
+	w := resp.NewWriter(c.nc)
+	for m := range c.out {
+		w.Protocol = m.proto
+		w.WriteValue(m.v)
+		if len(c.out) == 0 {
+			if err := w.Flush(); err != nil {
+				c.nc.Close()
+				for range c.out { // 读协程会发现连接已关闭并通知状态协程
+				}
+				return
+			}
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,208 @@
+package redisserver
+
+import (
+	"strings"
+	"time"
+
+	"example.com/database/resp"
+)
+
+// 数据类型，TYPE 命令返回这些名字
+const (
+	typeString = "string"
+	typeHash   = "hash"
+	typeList   = "list"
+)
+
+// entry 是一个 key 的值，只使用和 kind 对应的字段
+type entry struct {
+	kind     string
+	str      string
+	hash     map[string]string
+	list     []string
+	expireAt time.Time // 零值表示不过期
+}
+
+// state 是状态协程私有的全部数据
+type state struct {
+	s       *Server
+	data    map[string]*entry
+	clients map[*client]bool
+	subs    map[string]map[*client]bool // 频道 → 订阅者
+
+	watchers map[string]map[*client]bool // key → WATCH 它的连接
+}
+
+// loop 就是拥有数据的状态协程，依次处理请求，并定期删除过期的 key。
+func (s *Server) loop() {
+	defer close(s.done)
+	st := &state{
+		s:       s,
+		data:    make(map[string]*entry),
+		clients: make(map[*client]bool),
+		subs:    make(map[string]map[*client]bool),
+
+		watchers: make(map[string]map[*client]bool),
+	}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case req := <-s.reqs:
+			switch req.kind {
+			case reqConnect:
+				st.clients[req.c] = true
+			case reqCommand:
+				if !req.c.closed {
+					st.reply(req.c, st.handle(req.c, req.args))
+				}
+			case reqDisconnect:
+				st.disconnect(req.c)
+			}
+		case now := <-ticker.C:
+			st.expireSome(now)
+		case <-s.quit:
+			for c := range st.clients {
+				st.disconnect(c)
+			}
+			return
+		}
+	}
+}
+
+// noReply 表示命令已经自己发送了回复，例如 SUBSCRIBE 对每个频道各回复一次
+var noReply = resp.Value{}
+
+// reply 把回复放进连接的发送队列。队列满了说明客户端读得太慢，直接断开，不能让状态协程等待。
+func (st *state) reply(c *client, v resp.Value) {
+	if v.Type == 0 || c.closed {
+		return
+	}
+	select {
+	case c.out <- outMsg{v: v, proto: c.proto}:
+	default:
+		st.disconnect(c)
+		if c.nc != nil {
+			c.nc.Close()
+		}
+	}
+}
+
+// disconnect 清理连接的状态，关闭发送队列让写协程在发完剩余回复后关闭连接
+func (st *state) disconnect(c *client) {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	for ch := range c.subs {
+		st.unsubscribe(c, ch)
+	}
+	st.unwatch(c)
+	delete(st.clients, c)
+	close(c.out)
+}
+
+// handle 执行一条命令：检查命令和参数个数、认证和连接所处的模式，再调用命令的实现。
+func (st *state) handle(c *client, args []string) resp.Value {
+	name := strings.ToUpper(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		if c.multi {
+			c.multiErr = true
+		}
+		return resp.ErrorValue("ERR unknown command '" + args[0] + "'")
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		if c.multi {
+			c.multiErr = true
+		}
+		return resp.ErrorValue("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+	}
+	if st.s.opts.Password != "" && !c.authed && !cmd.noAuth {
+		return resp.ErrorValue("NOAUTH Authentication required.")
+	}
+	// RESP2 的连接订阅之后只能执行订阅相关的命令，RESP3 的推送消息和回复可以区分，没有这个限制
+	if len(c.subs) > 0 && c.proto < 3 && !cmd.pubsub {
+		return resp.ErrorValue("ERR Can't execute '" + strings.ToLower(name) + "': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context")
+	}
+	if c.multi && !cmd.tx {
+		if cmd.pubsub && name != "PING" {
+			c.multiErr = true
+			return resp.ErrorValue("ERR Command not allowed inside a transaction")
+		}
+		c.queued = append(c.queued, args)
+		return resp.SimpleStringValue("QUEUED")
+	}
+	v := cmd.fn(st, c, args)
+	if cmd.keys != nil {
+		for _, key := range cmd.keys(args) {
+			st.touch(key)
+		}
+	}
+	return v
+}
+
+// lookup 返回 key 的值，过期的 key 在这里被删除
+func (st *state) lookup(key string) *entry {
+	e, ok := st.data[key]
+	if !ok {
+		return nil
+	}
+	if !e.expireAt.IsZero() && !time.Now().Before(e.expireAt) {
+		delete(st.data, key)
+		st.touch(key) // 和 Redis 一样，过期也算修改
+		return nil
+	}
+	return e
+}
+
+// expireSome 每次最多检查 20 个带过期时间的 key。
+// 访问时的检查已经保证读不到过期数据，这里只是回收没人再访问的 key 占用的内存。
+func (st *state) expireSome(now time.Time) {
+	checked := 0
+	for key, e := range st.data { // map 的遍历顺序是随机的，相当于随机抽样
+		if checked == 20 {
+			return
+		}
+		if e.expireAt.IsZero() {
+			continue
+		}
+		checked++
+		if !now.Before(e.expireAt) {
+			delete(st.data, key)
+			st.touch(key)
+		}
+	}
+}
+
+// unsubscribe 取消一个频道的订阅
+func (st *state) unsubscribe(c *client, ch string) {
+	delete(c.subs, ch)
+	if subs := st.subs[ch]; subs != nil {
+		delete(subs, c)
+		if len(subs) == 0 {
+			delete(st.subs, ch)
+		}
+	}
+}
+
+// touch 标记 key 被修改，WATCH 它的连接的事务在 EXEC 时失败
+func (st *state) touch(key string) {
+	for c := range st.watchers[key] {
+		c.dirty = true
+	}
+}
+
+// unwatch 取消连接 WATCH 的所有 key
+func (st *state) unwatch(c *client) {
+	for key := range c.watched {
+		if ws := st.watchers[key]; ws != nil {
+			delete(ws, c)
+			if len(ws) == 0 {
+				delete(st.watchers, key)
+			}
+		}
+	}
+	c.watched, c.dirty = nil, false
+}
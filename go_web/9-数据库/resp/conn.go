@@ -0,0 +1,317 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Reader 默认的单个字符串和数组的长度上限，和 Redis 的 proto-max-bulk-len 一样是 512MB
+const (
+	DefaultMaxBulkLen  = 512 << 20
+	DefaultMaxArrayLen = 1 << 20
+)
+
+// 每次最多按长度前缀预先分配这么多，剩下的随着实际读到的数据增长
+const (
+	bulkChunk  = 64 << 10
+	arrayChunk = 1024
+)
+
+// ErrProtocol 表示读到了不符合协议的数据
+var ErrProtocol = errors.New("resp: 协议错误")
+
+// Reader 从连接中读取 RESP 值
+type Reader struct {
+	r *bufio.Reader
+
+	// MaxBulkLen 和 MaxArrayLen 是单个字符串和数组的长度上限，超过时返回 ErrProtocol。
+	// 长度前缀是对方发来的，不能完全相信：即使在上限以内，内存也是随着实际收到的数据分配的。
+	MaxBulkLen  int
+	MaxArrayLen int
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), MaxBulkLen: DefaultMaxBulkLen, MaxArrayLen: DefaultMaxArrayLen}
+}
+
+// ReadValue 读取一个完整的值。Attribute 是附加信息，会被跳过，返回它后面的值。
+func (r *Reader) ReadValue() (Value, error) {
+	for {
+		v, err := r.read()
+		if err != nil || v.Type != Attribute {
+			return v, err
+		}
+	}
+}
+
+// ReadCommand 读取客户端发来的一条命令。除了字符串数组，还支持 telnet 中直接输入的 inline 命令，例如 PING\r\n。
+func (r *Reader) ReadCommand() ([]string, error) {
+	b, err := r.r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if Type(b[0]) != Array {
+		line, err := r.line()
+		if err != nil {
+			return nil, err
+		}
+		return strings.Fields(line), nil
+	}
+
+	v, err := r.ReadValue()
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, len(v.Elems))
+	for i, e := range v.Elems {
+		if e.Type != BulkString && e.Type != SimpleString {
+			return nil, fmt.Errorf("%w: 命令参数必须是字符串", ErrProtocol)
+		}
+		args[i] = e.Str
+	}
+	return args, nil
+}
+
+func (r *Reader) read() (Value, error) {
+	b, err := r.r.ReadByte()
+	if err != nil {
+		return Value{}, err
+	}
+	t := Type(b)
+	line, err := r.line()
+	if err != nil {
+		return Value{}, err
+	}
+
+	switch t {
+	case SimpleString, Error:
+		return Value{Type: t, Str: line}, nil
+	case Integer:
+		n, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return Value{}, fmt.Errorf("%w: 整数 %q", ErrProtocol, line)
+		}
+		return Value{Type: t, Int: n}, nil
+	case BigNumber:
+		return Value{Type: t, Str: line}, nil
+	case Null:
+		return Value{Type: t, IsNull: true}, nil
+	case Boolean:
+		if line != "t" && line != "f" {
+			return Value{}, fmt.Errorf("%w: 布尔值 %q", ErrProtocol, line)
+		}
+		return Value{Type: t, Bool: line == "t"}, nil
+	case Double:
+		f, err := strconv.ParseFloat(line, 64) // inf、-inf、nan 也能解析
+		if err != nil {
+			return Value{}, fmt.Errorf("%w: 浮点数 %q", ErrProtocol, line)
+		}
+		return Value{Type: t, Float: f}, nil
+	case BulkString, BulkError, VerbatimString:
+		n, err := length(line, r.MaxBulkLen)
+		if err != nil {
+			return Value{}, err
+		}
+		if n < 0 {
+			return Value{Type: t, IsNull: true}, nil
+		}
+		buf, err := r.bulk(n + 2)
+		if err != nil {
+			return Value{}, err
+		}
+		if buf[n] != '\r' || buf[n+1] != '\n' {
+			return Value{}, fmt.Errorf("%w: 字符串没有以 CRLF 结尾", ErrProtocol)
+		}
+		s := string(buf[:n])
+		if t == VerbatimString && len(s) >= 4 && s[3] == ':' {
+			s = s[4:] // 去掉 txt: 这样的格式前缀
+		}
+		return Value{Type: t, Str: s}, nil
+	case Array, Set, Push, Map, Attribute:
+		n, err := length(line, r.MaxArrayLen)
+		if err != nil {
+			return Value{}, err
+		}
+		if n < 0 {
+			return Value{Type: t, IsNull: true}, nil
+		}
+		if t == Map || t == Attribute {
+			n *= 2
+		}
+		elems := make([]Value, 0, min(n, arrayChunk))
+		for range n {
+			e, err := r.ReadValue()
+			if err != nil {
+				return Value{}, err
+			}
+			elems = append(elems, e)
+		}
+		return Value{Type: t, Elems: elems}, nil
+	}
+	return Value{}, fmt.Errorf("%w: 未知类型 %q", ErrProtocol, b)
+}
+
+// bulk 分块读取 n 个字节，对方声明了很长的字符串却不发送数据时，不会一开始就分配 n 个字节
+func (r *Reader) bulk(n int) ([]byte, error) {
+	buf := make([]byte, 0, min(n, bulkChunk))
+	for len(buf) < n {
+		k := min(n-len(buf), bulkChunk)
+		buf = slices.Grow(buf, k)
+		m, err := io.ReadFull(r.r, buf[len(buf):len(buf)+k])
+		buf = buf[:len(buf)+m]
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF // 已经读了长度前缀，这里的 EOF 说明数据不完整
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// line 读取一行并去掉结尾的 \r\n
+func (r *Reader) line() (string, error) {
+	line, err := r.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("%w: 行没有以 CRLF 结尾", ErrProtocol)
+	}
+	return line[:len(line)-2], nil
+}
+
+func length(s string, limit int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < -1 || n > limit {
+		return 0, fmt.Errorf("%w: 长度 %q", ErrProtocol, s)
+	}
+	return n, nil
+}
+
+// Writer 把 RESP 值写到连接中。写入的数据先放在缓冲区里，调用 Flush 才真正发送，
+// 这样流水线中的多条命令可以一次发出去。
+type Writer struct {
+	w *bufio.Writer
+
+	// Protocol 是对方使用的协议版本，默认是 2。
+	// 为 2 时 RESP3 特有的类型会被转换成 RESP2 中对应的类型，例如 Map 写成数组，null 写成 $-1。
+	Protocol int
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), Protocol: 2}
+}
+
+// WriteCommand 把命令写成字符串数组
+func (w *Writer) WriteCommand(args ...string) {
+	w.header(Array, len(args))
+	for _, a := range args {
+		w.bulk(BulkString, a)
+	}
+}
+
+// WriteValue 写入一个值
+func (w *Writer) WriteValue(v Value) error {
+	t := v.Type
+	if w.Protocol < 3 {
+		t = downgrade(v)
+	}
+
+	switch {
+	case v.Nil() && w.Protocol >= 3:
+		w.w.WriteString("_\r\n")
+		return nil
+	case v.Nil() && t == Array:
+		w.w.WriteString("*-1\r\n")
+		return nil
+	case v.Nil():
+		w.w.WriteString("$-1\r\n")
+		return nil
+	}
+
+	switch t {
+	case SimpleString, Error, BigNumber:
+		w.w.WriteByte(byte(t))
+		w.w.WriteString(v.Str + "\r\n")
+	case Integer:
+		w.w.WriteString(":" + v.Text() + "\r\n")
+	case Boolean:
+		if v.Bool {
+			w.w.WriteString("#t\r\n")
+		} else {
+			w.w.WriteString("#f\r\n")
+		}
+	case Double:
+		w.w.WriteString("," + formatDouble(v.Float) + "\r\n")
+	case BulkString, BulkError:
+		w.bulk(t, v.Text())
+	case VerbatimString:
+		w.bulk(t, "txt:"+v.Str)
+	case Array, Set, Push, Map, Attribute:
+		n := len(v.Elems)
+		if t == Map || t == Attribute {
+			n /= 2
+		}
+		w.header(t, n)
+		for _, e := range v.Elems {
+			if err := w.WriteValue(e); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: 未知类型 %q", ErrProtocol, byte(t))
+	}
+	return nil
+}
+
+// Flush 把缓冲区中的数据发送出去
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *Writer) header(t Type, n int) {
+	w.w.WriteByte(byte(t))
+	w.w.WriteString(strconv.Itoa(n) + "\r\n")
+}
+
+func (w *Writer) bulk(t Type, s string) {
+	w.header(t, len(s))
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+// downgrade 返回值在 RESP2 中的类型
+func downgrade(v Value) Type {
+	switch v.Type {
+	case Null:
+		return BulkString
+	case Boolean:
+		return Integer // 和 Redis 一样，true 写成 :1，false 写成 :0
+	case Double, BigNumber, VerbatimString:
+		return BulkString
+	case BulkError:
+		return Error
+	case Map, Set, Push:
+		return Array
+	}
+	return v.Type
+}
+
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
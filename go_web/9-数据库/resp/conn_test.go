@@ -0,0 +1,51 @@
+package resp
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReadBulk(t *testing.T) {
+	// 超过一块的字符串分几次读完
+	long := strings.Repeat("张三", bulkChunk)
+	r := NewReader(strings.NewReader("$" + strconv.Itoa(len(long)) + "\r\n" + long + "\r\n"))
+	v, err := r.ReadValue()
+	if err != nil || v.Str != long {
+		t.Fatalf("读取 %d 字节的字符串: %v", len(long), err)
+	}
+
+	// 超过上限的长度前缀直接拒绝
+	r = NewReader(strings.NewReader("$11\r\nhello world\r\n"))
+	r.MaxBulkLen = 10
+	if _, err := r.ReadValue(); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("超过 MaxBulkLen 返回 %v，应该是 ErrProtocol", err)
+	}
+	r = NewReader(strings.NewReader("*3\r\n:1\r\n:2\r\n:3\r\n"))
+	r.MaxArrayLen = 2
+	if _, err := r.ReadValue(); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("超过 MaxArrayLen 返回 %v，应该是 ErrProtocol", err)
+	}
+}
+
+func TestReadLengthNotTrusted(t *testing.T) {
+	// 声明了上限以内的最大长度，实际只发了几个字节：内存按收到的数据分配，不按长度前缀
+	for _, in := range []string{
+		"$" + strconv.Itoa(DefaultMaxBulkLen) + "\r\nhello",
+		"*" + strconv.Itoa(DefaultMaxArrayLen) + "\r\n:1\r\n",
+	} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := NewReader(strings.NewReader(in)).ReadValue()
+		runtime.ReadMemStats(&after)
+		if err != io.ErrUnexpectedEOF && err != io.EOF {
+			t.Fatalf("%.10q: 数据不完整时返回 %v", in, err)
+		}
+		if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+			t.Fatalf("%.10q: 读取时分配了 %d 字节，超过 1MB", in, n)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Package resp 实现 Redis 的通信协议 RESP，包括 RESP2 和 RESP3 的所有类型。
+// redis.go 中的客户端和 redisserver 中的服务器都用它读写数据。
+//
+// 每个值以一个类型字节开头，以 \r\n 结尾，例如：
+//
+//	+OK\r\n                        简单字符串
+//	-ERR unknown command\r\n       错误
+//	:42\r\n                        整数
+//	$5\r\nhello\r\n                二进制安全的字符串
+//	*2\r\n$3\r\nGET\r\n$1\r\nk\r\n 数组，客户端发送的命令就是字符串数组
+//	_\r\n                          RESP3 的 null
+package resp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Type 是值的类型，取值就是协议中的类型字节
+type Type byte
+
+const (
+	SimpleString Type = '+'
+	Error        Type = '-'
+	Integer      Type = ':'
+	BulkString   Type = '$'
+	Array        Type = '*'
+
+	// 以下是 RESP3 新增的类型
+	Null           Type = '_'
+	Boolean        Type = '#'
+	Double         Type = ','
+	BigNumber      Type = '('
+	BulkError      Type = '!'
+	VerbatimString Type = '='
+	Map            Type = '%'
+	Set            Type = '~'
+	Push           Type = '>'
+	Attribute      Type = '|'
+)
+
+// Value 是一个 RESP 值。
+// 字符串、错误、大数和 Verbatim 字符串保存在 Str 中，整数在 Int 中，浮点数在 Float 中，布尔值在 Bool 中；
+// 数组、集合和推送保存在 Elems 中，Map 按 key、value 交替保存在 Elems 中。
+// RESP2 的 $-1 和 *-1 读出来是 Type 为 BulkString/Array、IsNull 为 true 的值。
+type Value struct {
+	Type   Type
+	Str    string
+	Int    int64
+	Float  float64
+	Bool   bool
+	Elems  []Value
+	IsNull bool
+}
+
+// 常用值的构造函数
+
+func SimpleStringValue(s string) Value { return Value{Type: SimpleString, Str: s} }
+func ErrorValue(msg string) Value      { return Value{Type: Error, Str: msg} }
+func IntegerValue(n int64) Value       { return Value{Type: Integer, Int: n} }
+func BulkValue(s string) Value         { return Value{Type: BulkString, Str: s} }
+func NullBulk() Value                  { return Value{Type: BulkString, IsNull: true} }
+func ArrayValue(elems ...Value) Value  { return Value{Type: Array, Elems: elems} }
+
+// BulkArray 把字符串列表变成字符串数组
+func BulkArray(items ...string) Value {
+	elems := make([]Value, len(items))
+	for i, s := range items {
+		elems[i] = BulkValue(s)
+	}
+	return ArrayValue(elems...)
+}
+
+// Nil 表示值为 null，两个协议版本中的 null 都算
+func (v Value) Nil() bool {
+	return v.IsNull || v.Type == Null
+}
+
+// Err 在值是错误回复时返回 *ReplyError，否则返回 nil
+func (v Value) Err() error {
+	if v.Type == Error || v.Type == BulkError {
+		return ParseError(v.Str)
+	}
+	return nil
+}
+
+// Text 返回字符串形式的值，数字会被格式化
+func (v Value) Text() string {
+	switch v.Type {
+	case Integer:
+		return strconv.FormatInt(v.Int, 10)
+	case Double:
+		return strconv.FormatFloat(v.Float, 'g', -1, 64)
+	case Boolean:
+		if v.Bool {
+			return "1"
+		}
+		return "0"
+	}
+	return v.Str
+}
+
+// String 用于调试输出，格式接近 redis-cli
+func (v Value) String() string {
+	if v.Nil() {
+		return "(nil)"
+	}
+	switch v.Type {
+	case Error, BulkError:
+		return "(error) " + v.Str
+	case Integer:
+		return fmt.Sprintf("(integer) %d", v.Int)
+	case Array, Set, Push, Map, Attribute:
+		parts := make([]string, len(v.Elems))
+		for i, e := range v.Elems {
+			parts[i] = e.String()
+		}
+		return "[" + strings.Join(parts, " ") + "]"
+	}
+	return strconv.Quote(v.Text())
+}
+
+// ReplyError 是服务器返回的错误回复。Prefix 是第一个单词，例如 ERR、WRONGTYPE
+type ReplyError struct {
+	Prefix  string
+	Message string
+}
+
+// ParseError 把错误回复的文本拆成前缀和消息
+func ParseError(s string) *ReplyError {
+	prefix, msg, ok := strings.Cut(s, " ")
+	if !ok || prefix != strings.ToUpper(prefix) {
+		return &ReplyError{Message: s}
+	}
+	return &ReplyError{Prefix: prefix, Message: msg}
+}
+
+func (e *ReplyError) Error() string {
+	if e.Prefix == "" {
+		return e.Message
+	}
+	return e.Prefix + " " + e.Message
+}